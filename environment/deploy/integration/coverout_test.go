@@ -25,7 +25,8 @@ func Test_I_Coverout(t *testing.T) {
 			"registry":        os.Getenv("REGISTRY"),
 			"claim-name":      os.Getenv("CLAIM_NAME"),
 			"pvc-access-mode": "ReadWriteOnce", // don't need to scale (+ not possible with kind in CI)
-			"expose":          "true",          // make API externally reachable
+			"expose":          "NodePort",      // make API externally reachable
+			"node-address":    Server,          // kind node address
 			"harden":          "true",          // we test Romeo in a hardened env -> need the netpol
 		},
 		Secrets: map[string]string{
@@ -36,10 +37,9 @@ func Test_I_Coverout(t *testing.T) {
 		},
 		ExtraRuntimeValidation: func(t *testing.T, stack integration.RuntimeValidationStackInfo) {
 			// Issue API call
-			server := fmt.Sprintf("%s:%0.f", Server, stack.Outputs["port"])
 			req, _ := http.NewRequest( //nolint:gosec //#gosec G704 -- FP, we are in integration test
 				http.MethodGet,
-				fmt.Sprintf("http://%s/api/v1/coverout", server),
+				fmt.Sprintf("%s/api/v1/coverout", stack.Outputs["url"]),
 				nil,
 			)
 			res, err := http.DefaultClient.Do(req) //nolint:gosec //#gosec G704 -- FP, we are in integration test
@@ -0,0 +1,265 @@
+// The Romeo Pulumi component provider serves the Romeo components to all
+// Pulumi languages, as described by its schema.
+package main
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/ctfer-io/romeo/sdk"
+	"github.com/pkg/errors"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi/pkg/v3/resource/provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/util/cmdutil"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	pprovider "github.com/pulumi/pulumi/sdk/v3/go/pulumi/provider"
+)
+
+const providerName = "ctfer-io"
+
+var (
+	version = "dev"
+
+	//go:embed schema.json
+	schema []byte
+)
+
+func main() {
+	if err := provider.ComponentMain(providerName, version, schema, construct); err != nil {
+		cmdutil.ExitError(err.Error())
+	}
+}
+
+func construct(
+	ctx *pulumi.Context,
+	typ, name string,
+	inputs pprovider.ConstructInputs,
+	options pulumi.ResourceOption,
+) (*pprovider.ConstructResult, error) {
+	switch typ {
+	case sdk.EnvironmentToken:
+		return constructEnvironment(ctx, name, inputs, options)
+	case sdk.InstallToken:
+		return constructInstall(ctx, name, inputs, options)
+	default:
+		return nil, fmt.Errorf("unknown resource type %s", typ)
+	}
+}
+
+type (
+	environmentArgs struct {
+		Namespace        pulumi.StringInput      `pulumi:"namespace"`
+		Harden           bool                    `pulumi:"harden"`
+		Allow            *allowArgs              `pulumi:"allow"`
+		AllowApps        *allowArgs              `pulumi:"allowApps"`
+		PodSecurityLevel string                  `pulumi:"podSecurityLevel"`
+		Restricted       bool                    `pulumi:"restricted"`
+		Resources        *resourcesArgs          `pulumi:"resources"`
+		Tag              pulumi.StringInput      `pulumi:"tag"`
+		ClaimName        pulumi.StringInput      `pulumi:"claimName"`
+		StorageClassName pulumi.StringInput      `pulumi:"storageClassName"`
+		StorageSize      pulumi.StringInput      `pulumi:"storageSize"`
+		PVCAccessModes   pulumi.StringArrayInput `pulumi:"pvcAccessModes"`
+		CoLocate         bool                    `pulumi:"coLocate"`
+		Registry         pulumi.StringInput      `pulumi:"registry"`
+		Digest           pulumi.StringInput      `pulumi:"digest"`
+		ImagePullSecrets pulumi.StringArrayInput `pulumi:"imagePullSecrets"`
+		Credentials      *credentialsArgs        `pulumi:"registryCredentials"`
+		Expose           string                  `pulumi:"expose"`
+		NodeAddress      pulumi.StringInput      `pulumi:"nodeAddress"`
+		Ingress          *ingressArgs            `pulumi:"ingress"`
+		Gateway          *gatewayArgs            `pulumi:"gateway"`
+		TTL              pulumi.StringInput      `pulumi:"ttl"`
+		Preserve         *preserveArgs           `pulumi:"preserve"`
+	}
+
+	allowArgs struct {
+		CIDRs             []string              `pulumi:"cidrs"`
+		NamespaceSelector pulumi.StringMapInput `pulumi:"namespaceSelector"`
+		PodSelector       pulumi.StringMapInput `pulumi:"podSelector"`
+	}
+
+	credentialsArgs struct {
+		Username pulumi.StringInput `pulumi:"username"`
+		Password pulumi.StringInput `pulumi:"password"`
+	}
+
+	resourcesArgs struct {
+		Requests pulumi.StringMapInput `pulumi:"requests"`
+		Limits   pulumi.StringMapInput `pulumi:"limits"`
+	}
+
+	ingressArgs struct {
+		Host          pulumi.StringInput    `pulumi:"host"`
+		TLSSecretName pulumi.StringInput    `pulumi:"tlsSecretName"`
+		ClassName     pulumi.StringInput    `pulumi:"className"`
+		Annotations   pulumi.StringMapInput `pulumi:"annotations"`
+	}
+
+	gatewayArgs struct {
+		Name        pulumi.StringInput `pulumi:"name"`
+		Namespace   pulumi.StringInput `pulumi:"namespace"`
+		SectionName pulumi.StringInput `pulumi:"sectionName"`
+		Hostname    pulumi.StringInput `pulumi:"hostname"`
+		PathPrefix  pulumi.StringInput `pulumi:"pathPrefix"`
+		Address     pulumi.StringInput `pulumi:"address"`
+	}
+
+	preserveArgs struct {
+		Sink       pulumi.StringInput `pulumi:"sink"`
+		ClaimName  pulumi.StringInput `pulumi:"claimName"`
+		S3Endpoint pulumi.StringInput `pulumi:"s3Endpoint"`
+		S3Region   pulumi.StringInput `pulumi:"s3Region"`
+		S3Insecure bool               `pulumi:"s3Insecure"`
+		SecretName pulumi.StringInput `pulumi:"secretName"`
+		Allow      *allowArgs         `pulumi:"allow"`
+	}
+
+	installArgs struct {
+		Namespace pulumi.StringInput `pulumi:"namespace"`
+		APIServer pulumi.StringInput `pulumi:"apiServer"`
+		Harden    bool               `pulumi:"harden"`
+		Webhook   bool               `pulumi:"webhook"`
+		GC        *gcArgs            `pulumi:"gc"`
+	}
+
+	gcArgs struct {
+		Schedule         pulumi.StringInput      `pulumi:"schedule"`
+		Tag              pulumi.StringInput      `pulumi:"tag"`
+		Registry         pulumi.StringInput      `pulumi:"registry"`
+		Digest           pulumi.StringInput      `pulumi:"digest"`
+		ImagePullSecrets pulumi.StringArrayInput `pulumi:"imagePullSecrets"`
+	}
+)
+
+func constructEnvironment(
+	ctx *pulumi.Context,
+	name string,
+	inputs pprovider.ConstructInputs,
+	options pulumi.ResourceOption,
+) (*pprovider.ConstructResult, error) {
+	args := &environmentArgs{}
+	if err := inputs.CopyTo(args); err != nil {
+		return nil, errors.Wrap(err, "setting environment args")
+	}
+
+	eargs := &sdk.RomeoEnvironmentArgs{
+		Namespace:        args.Namespace,
+		Harden:           args.Harden,
+		PodSecurityLevel: args.PodSecurityLevel,
+		Restricted:       args.Restricted,
+		Tag:              args.Tag,
+		ClaimName:        args.ClaimName,
+		StorageClassName: args.StorageClassName,
+		StorageSize:      args.StorageSize,
+		PVCAccessModes:   args.PVCAccessModes,
+		CoLocate:         args.CoLocate,
+		Registry:         args.Registry,
+		Digest:           args.Digest,
+		ImagePullSecrets: args.ImagePullSecrets,
+		Expose:           args.Expose,
+		NodeAddress:      args.NodeAddress,
+		TTL:              args.TTL,
+	}
+	if args.Allow != nil {
+		eargs.Allow = &sdk.RomeoAllowArgs{
+			CIDRs:             args.Allow.CIDRs,
+			NamespaceSelector: args.Allow.NamespaceSelector,
+			PodSelector:       args.Allow.PodSelector,
+		}
+	}
+	if args.AllowApps != nil {
+		eargs.AllowApps = &sdk.RomeoAllowArgs{
+			CIDRs:             args.AllowApps.CIDRs,
+			NamespaceSelector: args.AllowApps.NamespaceSelector,
+			PodSelector:       args.AllowApps.PodSelector,
+		}
+	}
+	if args.Credentials != nil {
+		eargs.RegistryCredentials = &sdk.RomeoRegistryCredentialsArgs{
+			Username: args.Credentials.Username,
+			Password: args.Credentials.Password,
+		}
+	}
+	if args.Resources != nil {
+		eargs.Resources = corev1.ResourceRequirementsArgs{
+			Requests: args.Resources.Requests,
+			Limits:   args.Resources.Limits,
+		}
+	}
+	if args.Ingress != nil {
+		eargs.Ingress = &sdk.RomeoIngressArgs{
+			Host:          args.Ingress.Host,
+			TLSSecretName: args.Ingress.TLSSecretName,
+			ClassName:     args.Ingress.ClassName,
+			Annotations:   args.Ingress.Annotations,
+		}
+	}
+	if args.Gateway != nil {
+		eargs.Gateway = &sdk.RomeoGatewayArgs{
+			Name:        args.Gateway.Name,
+			Namespace:   args.Gateway.Namespace,
+			SectionName: args.Gateway.SectionName,
+			Hostname:    args.Gateway.Hostname,
+			PathPrefix:  args.Gateway.PathPrefix,
+			Address:     args.Gateway.Address,
+		}
+	}
+	if args.Preserve != nil {
+		eargs.Preserve = &sdk.RomeoPreserveArgs{
+			Sink:       args.Preserve.Sink,
+			ClaimName:  args.Preserve.ClaimName,
+			S3Endpoint: args.Preserve.S3Endpoint,
+			S3Region:   args.Preserve.S3Region,
+			S3Insecure: args.Preserve.S3Insecure,
+			SecretName: args.Preserve.SecretName,
+		}
+		if args.Preserve.Allow != nil {
+			eargs.Preserve.Allow = &sdk.RomeoAllowArgs{
+				CIDRs:             args.Preserve.Allow.CIDRs,
+				NamespaceSelector: args.Preserve.Allow.NamespaceSelector,
+				PodSelector:       args.Preserve.Allow.PodSelector,
+			}
+		}
+	}
+
+	renv, err := sdk.NewRomeoEnvironment(ctx, name, eargs, options)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating environment")
+	}
+	return pprovider.NewConstructResult(renv)
+}
+
+func constructInstall(
+	ctx *pulumi.Context,
+	name string,
+	inputs pprovider.ConstructInputs,
+	options pulumi.ResourceOption,
+) (*pprovider.ConstructResult, error) {
+	args := &installArgs{}
+	if err := inputs.CopyTo(args); err != nil {
+		return nil, errors.Wrap(err, "setting install args")
+	}
+
+	iargs := &sdk.RomeoInstallArgs{
+		Namespace: args.Namespace,
+		APIServer: args.APIServer,
+		Harden:    args.Harden,
+		Webhook:   args.Webhook,
+	}
+	if args.GC != nil {
+		iargs.GC = &sdk.RomeoGCArgs{
+			Schedule:         args.GC.Schedule,
+			Tag:              args.GC.Tag,
+			Registry:         args.GC.Registry,
+			Digest:           args.GC.Digest,
+			ImagePullSecrets: args.GC.ImagePullSecrets,
+		}
+	}
+
+	rist, err := sdk.NewRomeoInstall(ctx, name, iargs, options)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating install")
+	}
+	return pprovider.NewConstructResult(rist)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ctfer-io/romeo/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_U_Schema(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Token string
+		Args  any
+	}{
+		"environment": {
+			Token: sdk.EnvironmentToken,
+			Args:  environmentArgs{},
+		},
+		"install": {
+			Token: sdk.InstallToken,
+			Args:  installArgs{},
+		},
+	}
+
+	spec := struct {
+		Name      string `json:"name"`
+		Resources map[string]struct {
+			IsComponent     bool           `json:"isComponent"`
+			InputProperties map[string]any `json:"inputProperties"`
+		} `json:"resources"`
+	}{}
+	require.NoError(t, json.Unmarshal(schema, &spec))
+	require.Equal(t, providerName, spec.Name)
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			res, ok := spec.Resources[tt.Token]
+			require.True(ok)
+			assert.True(res.IsComponent)
+
+			// The schema inputs are those the provider decodes
+			inputs := []string{}
+			typ := reflect.TypeOf(tt.Args)
+			for i := range typ.NumField() {
+				tag, _, _ := strings.Cut(typ.Field(i).Tag.Get("pulumi"), ",")
+				inputs = append(inputs, tag)
+			}
+			props := []string{}
+			for prop := range res.InputProperties {
+				props = append(props, prop)
+			}
+			assert.ElementsMatch(inputs, props)
+		})
+	}
+}
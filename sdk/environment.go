@@ -0,0 +1,1395 @@
+package sdk
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apiextensions"
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apps/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	netwv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/networking/v1"
+	"github.com/pulumi/pulumi-random/sdk/v4/go/random"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+type (
+	// RomeoEnvironment contains the ephemeral Kubernetes infrastructure for the Go
+	// binaries to export their coverage info into.
+	RomeoEnvironment struct {
+		pulumi.ResourceState
+
+		ns        *Namespace
+		h         *Hardening
+		randName  *random.RandomString
+		instance  pulumi.StringOutput
+		pvc       *corev1.PersistentVolumeClaim
+		coverRand *random.RandomString
+		dep       *appsv1.Deployment
+		svc       *corev1.Service
+		ing       *netwv1.Ingress
+		route     *apiextensions.CustomResource
+		basePath  pulumi.StringOutput
+		netpol    *netwv1.NetworkPolicy
+		appspol   *netwv1.NetworkPolicy
+		prespol   *netwv1.NetworkPolicy
+		pull      *corev1.Secret
+
+		// Namespace to where Romeo is deployed.
+		// You can reuse it for further tests such that deployed Go apps target
+		// this namespace for their own deployment, thus can reach the
+		// PersistentVolumeClaim.
+		Namespace pulumi.StringOutput `pulumi:"namespace"`
+
+		// The port to reach the Romeo instance on.
+		// It is the node port when exposed through a NodePort, else the
+		// Service port.
+		Port pulumi.IntOutput `pulumi:"port"`
+
+		// URL to reach the Romeo instance at, according to how it is exposed.
+		URL pulumi.StringOutput `pulumi:"url"`
+
+		// The claim name to mount in coverage-monitored Go pods for them to
+		// export their coverage data.
+		ClaimName pulumi.StringOutput `pulumi:"claimName"`
+
+		PodLabels pulumi.StringMapOutput `pulumi:"podLabels"`
+
+		// Affinity schedules the coverage-monitored pods on the same node as
+		// the Romeo webserver, as required by ReadWriteOnce volumes.
+		// It is set by [Instrument], or could be set by hand on pods.
+		// Only defined with [RomeoEnvironmentArgs.CoLocate].
+		Affinity corev1.AffinityPtrOutput `pulumi:"affinity"`
+
+		// ImagePullSecrets are the names of the Secrets the Romeo Docker
+		// image is pulled with, to reuse for the other Romeo components
+		// (e.g. [*RomeoWebhook]).
+		ImagePullSecrets pulumi.StringArrayOutput `pulumi:"imagePullSecrets"`
+	}
+
+	// RomeoEnvironmentArgs contains all the arguments to deploy a Romeo environment.
+	RomeoEnvironmentArgs struct {
+		Tag pulumi.StringInput
+		tag pulumi.StringOutput
+
+		ClaimName pulumi.StringInput
+
+		// StorageClassName of the PersistentVolumeClaim.
+		// If empty, the cluster default StorageClass is used.
+		StorageClassName pulumi.StringInput
+		storageClassName pulumi.StringPtrInput
+
+		StorageSize pulumi.StringInput
+		storageSize pulumi.StringOutput
+
+		// Namespace in which to sets up the Romeo environments.
+		Namespace       pulumi.StringInput
+		createNamespace bool
+
+		// Harden the namespace or not.
+		// Deny all traffic, deny inter-namespace communications,
+		// then grant DNS resolution, and grant internet communications.
+		Harden bool
+
+		// Allow the peers to reach the webserver API, when hardened, e.g.
+		// the CI runners CIDRs or the Ingress controller pods.
+		// Defaults to all IP addresses, for the API to be reachable
+		// whatever the exposure.
+		Allow *RomeoAllowArgs
+
+		// AllowApps allows the in-cluster applications to reach the
+		// webserver API, when hardened, e.g. for them to push their
+		// coverages. It is a separate rule, granted along with Allow.
+		// In a created namespace, whose hardening denies the egress to
+		// private ranges, the pods of the PodSelector (or all of them if
+		// there is none, or with a NamespaceSelector) are also granted to
+		// egress to the webserver. In an existing namespace, granting it is
+		// up to its own policies.
+		AllowApps *RomeoAllowArgs
+
+		// PodSecurityLevel the created namespace enforces, either
+		// [PodSecurityPrivileged], [PodSecurityBaseline] or
+		// [PodSecurityRestricted]. Defaults to [PodSecurityBaseline].
+		// With [PodSecurityRestricted], the webserver runs Restricted.
+		PodSecurityLevel string
+
+		// Restricted runs the webserver as a non-root user, with a read-only
+		// root filesystem, all capabilities dropped and the RuntimeDefault
+		// seccomp profile, as the restricted Pod Security Standard requires
+		// e.g. for namespaces managed by the caller.
+		Restricted bool
+
+		// Resources of the webserver container. Merges hold the encoded
+		// coverages in memory, so size its memory limit after them.
+		// If nil, none are set.
+		Resources corev1.ResourceRequirementsPtrInput
+
+		PVCAccessModes pulumi.StringArrayInput
+		pvcAccessModes pulumi.StringArrayOutput
+
+		// CoLocate exports the pod affinity for the coverage-monitored pods
+		// to run on the same node as the Romeo webserver, such that they
+		// could share a ReadWriteOnce volume.
+		CoLocate bool
+
+		// Registry define from where to fetch the Romeo Docker images.
+		// If set empty, defaults to Docker Hub.
+		// Authenticate with ImagePullSecrets or RegistryCredentials.
+		Registry pulumi.StringInput
+		registry pulumi.StringOutput
+
+		// Digest pins the Romeo Docker image (e.g. "sha256:..."), such that
+		// the deployed webserver could be verified against its SLSA
+		// provenance. The tag is kept in the reference for readability.
+		Digest pulumi.StringInput
+
+		// ImagePullSecrets are the names of existing Secrets, in the
+		// namespace, to pull the Romeo Docker image with.
+		ImagePullSecrets pulumi.StringArrayInput
+
+		// RegistryCredentials creates a Secret to pull the Romeo Docker
+		// image from the Registry with, in addition to ImagePullSecrets.
+		RegistryCredentials *RomeoRegistryCredentialsArgs
+
+		// Expose defines how to reach the Romeo webserver, either
+		// [ExposeClusterIP], [ExposeNodePort], [ExposeLoadBalancer],
+		// [ExposeIngress] or [ExposeGateway]. Defaults to [ExposeNodePort].
+		Expose string
+
+		// NodeAddress is the address the Kubernetes nodes are reachable at.
+		// Used to build the URL when exposed through a NodePort.
+		// Defaults to "localhost".
+		NodeAddress pulumi.StringInput
+		nodeAddress pulumi.StringOutput
+
+		// Ingress configures the Ingress when exposed through it.
+		Ingress *RomeoIngressArgs
+
+		// Gateway configures the HTTPRoute when exposed through a Gateway.
+		Gateway *RomeoGatewayArgs
+
+		// Instance names the environment resources (PersistentVolumeClaim,
+		// Deployment, Service, ...) deterministically, e.g. for rendering
+		// manifests. If not set, they are named after random strings.
+		Instance pulumi.StringInput
+
+		// TTL is the duration (e.g. "24h") after which the environment is
+		// considered orphaned, thus garbage collected by `romeo gc`.
+		// Defaults to [DefaultTTL]. Set it to "0" for it to never expire.
+		TTL         pulumi.StringInput
+		annotations pulumi.StringMapOutput
+
+		// Preserve the coverages when the environment is destroyed, by
+		// writing them to a sink before the webserver stops.
+		// Opt-in, as the coverages are expected to be downloaded before.
+		// As the webserver stops on every rollout or eviction too, the sink
+		// is then overwritten by the merge of all the coverages so far, as
+		// they outlive the pod on the PersistentVolumeClaim.
+		Preserve *RomeoPreserveArgs
+	}
+
+	// RomeoPreserveArgs contains the arguments to preserve the coverages of
+	// a Romeo environment when it is destroyed.
+	// The webserver merges them on stop, then writes the zip archive to the
+	// sink, just as `romeo preserve` does.
+	RomeoPreserveArgs struct {
+		// Sink to write the coverages to, either a file path or an
+		// S3-compatible object ("s3://bucket/key").
+		// Defaults to "/etc/preserve/coverout.zip" with a ClaimName.
+		Sink pulumi.StringInput
+
+		// ClaimName of a PersistentVolumeClaim to mount at "/etc/preserve",
+		// for the file sinks to outlive the environment.
+		ClaimName pulumi.StringInput
+
+		// S3Endpoint of the S3-compatible API (e.g. "minio.minio:9000").
+		S3Endpoint pulumi.StringInput
+
+		// S3Region of the bucket, if required by the endpoint.
+		S3Region pulumi.StringInput
+
+		// S3Insecure reaches the S3-compatible API over plain HTTP.
+		S3Insecure bool
+
+		// SecretName of a Secret whose keys are exposed as environment
+		// variables, e.g. AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+		SecretName pulumi.StringInput
+
+		// Allow the webserver to egress to the peers of the sink, when
+		// hardened, e.g. an in-cluster S3-compatible API, as the hardening
+		// of the created namespace denies the egress to private ranges.
+		Allow *RomeoAllowArgs
+	}
+
+	// RomeoAllowArgs contains the peers allowed to reach the webserver API
+	// of a hardened Romeo environment.
+	// Any of them is allowed, and at least one has to be defined.
+	RomeoAllowArgs struct {
+		// CIDRs allowed, e.g. "203.0.113.0/24".
+		CIDRs []string
+
+		// NamespaceSelector matches the labels of the namespaces whose pods
+		// are allowed. An empty one matches all namespaces.
+		NamespaceSelector pulumi.StringMapInput
+
+		// PodSelector matches the labels of the pods allowed, in the
+		// namespaces of the NamespaceSelector if any, else in the
+		// environment namespace.
+		PodSelector pulumi.StringMapInput
+	}
+
+	// RomeoIngressArgs contains the arguments to expose a Romeo environment
+	// through an Ingress.
+	RomeoIngressArgs struct {
+		// Host to serve Romeo on. Required.
+		Host pulumi.StringInput
+
+		// TLSSecretName is the name of the Secret containing the TLS
+		// certificate for the host. If set, the URL is served over HTTPS.
+		TLSSecretName pulumi.StringInput
+
+		// ClassName of the Ingress. If empty, uses the cluster default one.
+		ClassName pulumi.StringInput
+
+		// Annotations to add to the Ingress, e.g. for the controller configuration.
+		Annotations pulumi.StringMapInput
+	}
+
+	// RomeoGatewayArgs contains the arguments to expose a Romeo environment
+	// through an existing Gateway API Gateway, using an HTTPRoute.
+	// Many environments can share a single Gateway, each one matching its own
+	// hostname or path prefix.
+	RomeoGatewayArgs struct {
+		// Name of the Gateway to attach to. Required.
+		Name pulumi.StringInput
+
+		// Namespace of the Gateway. Defaults to the environment namespace.
+		Namespace pulumi.StringInput
+
+		// SectionName of the Gateway listener to attach to.
+		// If empty, attaches to all listeners.
+		SectionName pulumi.StringInput
+
+		// Hostname to match requests on, e.g. "romeo-1.example.com".
+		Hostname pulumi.StringInput
+
+		// PathPrefix to match requests on, and serve the webserver under.
+		// If both it and Hostname are empty, defaults to a per-environment
+		// prefix such that environments don't collide.
+		PathPrefix pulumi.StringInput
+
+		// Address the Gateway is reachable at (e.g. "https://gw.example.com"),
+		// used to build the URL. Defaults to "http://" and the hostname.
+		Address pulumi.StringInput
+	}
+)
+
+// EnvironmentToken is the type token of [*RomeoEnvironment].
+const EnvironmentToken = "ctfer-io:romeo:environment"
+
+// How a Romeo environment is exposed.
+const (
+	ExposeClusterIP    = "ClusterIP"
+	ExposeNodePort     = "NodePort"
+	ExposeLoadBalancer = "LoadBalancer"
+	ExposeIngress      = "Ingress"
+	ExposeGateway      = "Gateway"
+)
+
+const (
+	// TTLAnnotation is set on the resources of a Romeo environment, with
+	// the duration after which they are garbage collected.
+	TTLAnnotation = "romeo.ctfer.io/ttl"
+
+	// DefaultTTL of a Romeo environment.
+	DefaultTTL = "24h"
+)
+
+const (
+	coverdir           = "/etc/coverdir"
+	defaultTag         = "dev"
+	defaultStorageSize = "50M"
+	defaultNodeAddress = "localhost"
+	preserveDir        = "/etc/preserve"
+	tmpDir             = "/tmp"
+	port               = 8080
+
+	// restrictedID is the user and group the webserver runs as when
+	// restricted, as the image does not define a non-root user.
+	restrictedID = 65532
+)
+
+// NewRomeoEnvironment deploys a Romeo instance on Kubernetes.
+// The Romeo variable could be reused as a Pulumi ressource i.e. could
+// be a dependency, consumes inputs and produces outputs, etc.
+func NewRomeoEnvironment(
+	ctx *pulumi.Context,
+	name string,
+	args *RomeoEnvironmentArgs,
+	opts ...pulumi.ResourceOption,
+) (*RomeoEnvironment, error) {
+	renv := &RomeoEnvironment{}
+
+	args = renv.defaults(args)
+	if err := renv.check(args); err != nil {
+		return nil, err
+	}
+	if err := ctx.RegisterComponentResource(EnvironmentToken, name, renv, opts...); err != nil {
+		return nil, err
+	}
+	opts = append(opts, pulumi.Parent(renv))
+	if err := renv.provision(ctx, name, args, opts...); err != nil {
+		return nil, err
+	}
+	if err := renv.outputs(ctx, args); err != nil {
+		return nil, err
+	}
+
+	return renv, nil
+}
+
+func (renv *RomeoEnvironment) defaults(args *RomeoEnvironmentArgs) *RomeoEnvironmentArgs {
+	if args == nil {
+		args = &RomeoEnvironmentArgs{}
+	}
+
+	args.createNamespace = createNamespace(args.Namespace)
+	if !args.createNamespace {
+		args.Namespace = existingNamespace(args.Namespace)
+	}
+
+	// Default Pod Security level to baseline, and comply with restricted
+	if args.PodSecurityLevel == "" {
+		args.PodSecurityLevel = PodSecurityBaseline
+	}
+	if args.PodSecurityLevel == PodSecurityRestricted {
+		args.Restricted = true
+	}
+
+	// Default tag to dev
+	args.tag = pulumi.String(defaultTag).ToStringOutput()
+	if args.Tag != nil {
+		args.tag = args.Tag.ToStringOutput().ApplyT(func(tag string) string {
+			if tag == "" {
+				return defaultTag
+			}
+			return tag
+		}).(pulumi.StringOutput)
+	}
+
+	// Don't default storage class name -> will select the default one
+	// on the K8s cluster.
+	if args.StorageClassName != nil {
+		args.storageClassName = args.StorageClassName.ToStringOutput().ApplyT(func(scm string) *string {
+			if scm == "" {
+				return nil
+			}
+			return &scm
+		}).(pulumi.StringPtrOutput)
+	}
+
+	// Default storage size to 50M
+	args.storageSize = pulumi.String(defaultStorageSize).ToStringOutput()
+	if args.StorageSize != nil {
+		args.storageSize = args.StorageSize.ToStringOutput().ApplyT(func(size string) string {
+			if size == "" {
+				return defaultStorageSize
+			}
+			return size
+		}).(pulumi.StringOutput)
+	}
+
+	// Define private registry if any
+	args.registry = pulumi.String("").ToStringOutput()
+	if args.Registry != nil {
+		args.registry = args.Registry.ToStringOutput().ApplyT(func(in string) string {
+			if in != "" && !strings.HasSuffix(in, "/") {
+				in += "/"
+			}
+			return in
+		}).(pulumi.StringOutput)
+	}
+
+	// Default PVC access modes
+	if args.PVCAccessModes == nil {
+		args.pvcAccessModes = pulumi.ToStringArray([]string{
+			"ReadWriteMany",
+		}).ToStringArrayOutput()
+	} else {
+		args.pvcAccessModes = args.PVCAccessModes.ToStringArrayOutput().ApplyT(func(slc []string) []string {
+			if len(slc) == 0 {
+				return []string{"ReadWriteMany"}
+			}
+			return slc
+		}).(pulumi.StringArrayOutput)
+	}
+
+	// Default exposure to NodePort
+	if args.Expose == "" {
+		args.Expose = ExposeNodePort
+	}
+	args.nodeAddress = pulumi.String(defaultNodeAddress).ToStringOutput()
+	if args.NodeAddress != nil {
+		args.nodeAddress = args.NodeAddress.ToStringOutput().ApplyT(func(addr string) string {
+			if addr == "" {
+				return defaultNodeAddress
+			}
+			return addr
+		}).(pulumi.StringOutput)
+	}
+
+	// Default TTL, such that orphaned environments get garbage collected
+	args.annotations = pulumi.StringMap{
+		TTLAnnotation: pulumi.String(DefaultTTL),
+	}.ToStringMapOutput()
+	if args.TTL != nil {
+		args.annotations = args.TTL.ToStringOutput().ApplyT(func(ttl string) map[string]string {
+			switch ttl {
+			case "":
+				ttl = DefaultTTL
+			case "0":
+				return map[string]string{}
+			}
+			return map[string]string{
+				TTLAnnotation: ttl,
+			}
+		}).(pulumi.StringMapOutput)
+	}
+
+	return args
+}
+
+func (renv *RomeoEnvironment) check(args *RomeoEnvironmentArgs) error {
+	if ttl, ok := args.TTL.(pulumi.String); ok && ttl != "" && ttl != "0" {
+		if _, err := time.ParseDuration(string(ttl)); err != nil {
+			return errors.Wrap(err, "invalid TTL")
+		}
+	}
+
+	if args.Digest != nil && !validDigest(args.Digest) {
+		return fmt.Errorf("invalid image digest %q, expected sha256:<hex>", args.Digest)
+	}
+	if rc := args.RegistryCredentials; rc != nil && (rc.Username == nil || rc.Password == nil) {
+		return errors.New("registry credentials require a username and a password")
+	}
+
+	switch args.PodSecurityLevel {
+	case PodSecurityPrivileged, PodSecurityBaseline, PodSecurityRestricted:
+	default:
+		return fmt.Errorf("unsupported Pod Security level %q", args.PodSecurityLevel)
+	}
+
+	switch args.Expose {
+	case ExposeClusterIP, ExposeNodePort, ExposeLoadBalancer:
+	case ExposeIngress:
+		if args.Ingress == nil || args.Ingress.Host == nil {
+			return errors.New("exposing through an Ingress requires a host")
+		}
+	case ExposeGateway:
+		if args.Gateway == nil || args.Gateway.Name == nil {
+			return errors.New("exposing through a Gateway requires its name")
+		}
+		if args.Gateway.Hostname == nil && args.Gateway.Address == nil {
+			return errors.New("exposing through a Gateway requires either a hostname or an address")
+		}
+	default:
+		return fmt.Errorf("unsupported expose %q", args.Expose)
+	}
+
+	for _, allow := range []*RomeoAllowArgs{args.Allow, args.AllowApps} {
+		if err := allow.check(); err != nil {
+			return err
+		}
+	}
+
+	if args.Preserve != nil {
+		if args.Preserve.Sink == nil && args.Preserve.ClaimName == nil {
+			return errors.New("preserving coverages requires either a sink or a claim name")
+		}
+		if err := args.Preserve.Allow.check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (renv *RomeoEnvironment) provision(
+	ctx *pulumi.Context,
+	name string,
+	args *RomeoEnvironmentArgs,
+	opts ...pulumi.ResourceOption,
+) (err error) {
+	// Name after the instance if any, else generate unique (random enough)
+	// PVC name and let the other resources be auto-named
+	var resName pulumi.StringPtrInput
+	if args.Instance != nil {
+		renv.instance = args.Instance.ToStringOutput()
+		resName = renv.instance
+	} else {
+		renv.randName, err = random.NewRandomString(ctx, "romeo-name-"+name, &random.RandomStringArgs{
+			Length:  pulumi.Int(8),
+			Special: pulumi.Bool(false),
+			Numeric: pulumi.Bool(false),
+			Upper:   pulumi.Bool(false),
+		}, opts...)
+		if err != nil {
+			return
+		}
+		renv.instance = renv.randName.Result
+	}
+
+	// Create namespace if required
+	namespace := args.Namespace
+	if args.createNamespace {
+		renv.ns, err = NewNamespace(ctx, "romeo-environment", &NamespaceArgs{
+			Name: pulumi.String("romeo-environment"),
+			AdditionalLabels: pulumi.StringMap{
+				"app.kubernetes.io/component": pulumi.String("environment"),
+				"app.kubernetes.io/part-of":   pulumi.String("romeo"),
+			},
+			AdditionalAnnotations: args.annotations,
+			PodSecurityLevel:      pulumi.String(args.PodSecurityLevel),
+		}, opts...)
+		if err != nil {
+			return err
+		}
+		namespace = renv.ns.Name
+
+		if args.Harden {
+			renv.h, err = NewHardening(ctx, "environment-hard", &HardeningArgs{
+				Name: namespace,
+				AdditionalLabels: pulumi.StringMap{
+					"app.kubernetes.io/component": pulumi.String("environment"),
+					"app.kubernetes.io/part-of":   pulumi.String("romeo"),
+				},
+			}, opts...)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Provision K8s resource
+	// => PVC
+	renv.pvc, err = corev1.NewPersistentVolumeClaim(ctx, "romeo-pvc-"+name, &corev1.PersistentVolumeClaimArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Namespace: namespace,
+			Labels: pulumi.StringMap{
+				"app.kubernetes.io/component": pulumi.String(name),
+				"app.kubernetes.io/part-of":   pulumi.String("romeo"),
+				"instance":                    renv.instance,
+			},
+			Name:        renv.instance,
+			Annotations: args.annotations,
+		},
+		Spec: corev1.PersistentVolumeClaimSpecArgs{
+			StorageClassName: args.storageClassName,
+			AccessModes:      args.pvcAccessModes,
+			Resources: corev1.VolumeResourceRequirementsArgs{
+				Requests: pulumi.StringMap{
+					"storage": args.storageSize,
+				},
+			},
+		},
+	}, opts...)
+	if err != nil {
+		return
+	}
+
+	// => Deployment
+	basePath := pulumi.String("").ToStringOutput()
+	if args.Expose == ExposeGateway {
+		basePath = pulumi.All(orEmpty(args.Gateway.PathPrefix), orEmpty(args.Gateway.Hostname), renv.instance).ApplyT(func(all []any) string {
+			prefix, hostname, rand := all[0].(string), all[1].(string), all[2].(string)
+			if prefix == "" && hostname == "" {
+				prefix = "romeo-" + rand
+			}
+			if prefix = strings.Trim(prefix, "/"); prefix != "" {
+				return "/" + prefix
+			}
+			return ""
+		}).(pulumi.StringOutput)
+	}
+	renv.basePath = basePath
+	envs := corev1.EnvVarArray{
+		corev1.EnvVarArgs{
+			Name:  pulumi.String("COVERDIR"),
+			Value: pulumi.String(coverdir),
+		},
+		corev1.EnvVarArgs{
+			Name:  pulumi.String("BASE_PATH"),
+			Value: basePath,
+		},
+	}
+	volumeMounts := corev1.VolumeMountArray{
+		corev1.VolumeMountArgs{
+			Name:      pulumi.String("coverdir"),
+			MountPath: pulumi.String(coverdir),
+		},
+	}
+	volumes := corev1.VolumeArray{
+		corev1.VolumeArgs{
+			Name: pulumi.String("coverdir"),
+			PersistentVolumeClaim: corev1.PersistentVolumeClaimVolumeSourceArgs{
+				ClaimName: renv.pvc.Metadata.Name().Elem(),
+			},
+		},
+	}
+	if args.ClaimName != nil {
+		_ = ctx.Log.Info("Deploying with a claim name thus coverage exports", nil)
+
+		// If coverage is turned on, export coverages in a random directory
+		// that is different from coverdir (ensure no collision).
+		dir := renv.instance
+		if args.Instance == nil {
+			renv.coverRand, err = random.NewRandomString(ctx, "cover-rand-"+name, &random.RandomStringArgs{
+				Length:  pulumi.Int(16),
+				Lower:   pulumi.BoolPtr(true),
+				Numeric: pulumi.BoolPtr(false),
+				Special: pulumi.BoolPtr(false),
+				Upper:   pulumi.BoolPtr(false),
+			}, opts...)
+			if err != nil {
+				return
+			}
+			dir = renv.coverRand.Result
+		}
+		path := dir.ApplyT(func(rand string) string {
+			return fmt.Sprintf("/tmp/%s", rand)
+		}).(pulumi.StringOutput)
+
+		envs = append(envs, corev1.EnvVarArgs{
+			Name:  pulumi.String("GOCOVERDIR"),
+			Value: path,
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMountArgs{
+			Name:      pulumi.String("coverages"),
+			MountPath: path,
+		})
+		volumes = append(volumes, corev1.VolumeArgs{
+			Name: pulumi.String("coverages"),
+			PersistentVolumeClaim: corev1.PersistentVolumeClaimVolumeSourceArgs{
+				ClaimName: args.ClaimName,
+			},
+		})
+	}
+
+	// If required, preserve the coverages on stop, i.e. before the claim is
+	// released when the environment is destroyed
+	var lifecycle corev1.LifecyclePtrInput
+	var envFrom corev1.EnvFromSourceArray
+	var gracePeriod pulumi.IntPtrInput
+	if pr := args.Preserve; pr != nil {
+		sink := pulumi.String(preserveDir + "/coverout.zip").ToStringOutput()
+		if pr.Sink != nil {
+			sink = pr.Sink.ToStringOutput()
+		}
+		lifecycle = corev1.LifecycleArgs{
+			PreStop: corev1.LifecycleHandlerArgs{
+				Exec: corev1.ExecActionArgs{
+					Command: pulumi.StringArray{
+						pulumi.String("/romeo"),
+						pulumi.String("preserve"),
+						pulumi.String("--server"),
+						pulumi.Sprintf("http://localhost:%d%s", port, basePath),
+						pulumi.String("--sink"),
+						sink,
+					},
+				},
+			},
+		}
+		// Merging could take longer than the default 30s
+		gracePeriod = pulumi.Int(120)
+
+		if pr.S3Endpoint != nil {
+			envs = append(envs, corev1.EnvVarArgs{
+				Name:  pulumi.String("S3_ENDPOINT"),
+				Value: pr.S3Endpoint,
+			})
+		}
+		if pr.S3Region != nil {
+			envs = append(envs, corev1.EnvVarArgs{
+				Name:  pulumi.String("S3_REGION"),
+				Value: pr.S3Region,
+			})
+		}
+		if pr.S3Insecure {
+			envs = append(envs, corev1.EnvVarArgs{
+				Name:  pulumi.String("S3_INSECURE"),
+				Value: pulumi.String("true"),
+			})
+		}
+		if pr.SecretName != nil {
+			envFrom = corev1.EnvFromSourceArray{
+				corev1.EnvFromSourceArgs{
+					SecretRef: corev1.SecretEnvSourceArgs{
+						Name: pr.SecretName,
+					},
+				},
+			}
+		}
+		if pr.ClaimName != nil {
+			volumeMounts = append(volumeMounts, corev1.VolumeMountArgs{
+				Name:      pulumi.String("preserve"),
+				MountPath: pulumi.String(preserveDir),
+			})
+			volumes = append(volumes, corev1.VolumeArgs{
+				Name: pulumi.String("preserve"),
+				PersistentVolumeClaim: corev1.PersistentVolumeClaimVolumeSourceArgs{
+					ClaimName: pr.ClaimName,
+				},
+			})
+		}
+	}
+
+	// If required, comply with the restricted Pod Security Standard.
+	// The root filesystem being read-only, the merges and the Go build
+	// cache of the merge engine are written in an emptyDir.
+	var securityContext corev1.SecurityContextPtrInput
+	var podSecurityContext corev1.PodSecurityContextPtrInput
+	if args.Restricted {
+		securityContext, podSecurityContext = restricted()
+		envs = append(envs,
+			corev1.EnvVarArgs{
+				Name:  pulumi.String("HOME"),
+				Value: pulumi.String(tmpDir),
+			},
+			corev1.EnvVarArgs{
+				Name:  pulumi.String("GOCACHE"),
+				Value: pulumi.String(tmpDir + "/.cache"),
+			},
+		)
+		volumeMounts = append(volumeMounts, corev1.VolumeMountArgs{
+			Name:      pulumi.String("tmp"),
+			MountPath: pulumi.String(tmpDir),
+		})
+		volumes = append(volumes, corev1.VolumeArgs{
+			Name:     pulumi.String("tmp"),
+			EmptyDir: corev1.EmptyDirVolumeSourceArgs{},
+		})
+	}
+
+	// => Secret (the registry credentials), if required
+	pullSecrets := pulumi.StringArray{}.ToStringArrayOutput()
+	if args.ImagePullSecrets != nil {
+		pullSecrets = args.ImagePullSecrets.ToStringArrayOutput()
+	}
+	if rc := args.RegistryCredentials; rc != nil {
+		renv.pull, err = corev1.NewSecret(ctx, "romeo-pull-"+name, &corev1.SecretArgs{
+			Metadata: metav1.ObjectMetaArgs{
+				Name:      resName,
+				Namespace: namespace,
+				Labels: pulumi.StringMap{
+					"app.kubernetes.io/component": pulumi.String(name),
+					"app.kubernetes.io/part-of":   pulumi.String("romeo"),
+					"instance":                    renv.instance,
+				},
+				Annotations: args.annotations,
+			},
+			Type: pulumi.String("kubernetes.io/dockerconfigjson"),
+			StringData: pulumi.StringMap{
+				".dockerconfigjson": pulumi.All(args.registry, rc.Username, rc.Password).ApplyT(func(all []any) string {
+					return dockerConfigJSON(all[0].(string), all[1].(string), all[2].(string))
+				}).(pulumi.StringOutput),
+			},
+		}, opts...)
+		if err != nil {
+			return
+		}
+		pullSecrets = pulumi.All(pullSecrets, renv.pull.Metadata.Name().Elem()).ApplyT(func(all []any) []string {
+			return append(all[0].([]string), all[1].(string))
+		}).(pulumi.StringArrayOutput)
+	}
+	renv.ImagePullSecrets = pullSecrets
+
+	renv.dep, err = appsv1.NewDeployment(ctx, "romeo-dep-"+name, &appsv1.DeploymentArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      resName,
+			Namespace: namespace,
+			Labels: pulumi.StringMap{
+				"app.kubernetes.io/name":      pulumi.String("romeo"),
+				"app.kubernetes.io/version":   args.tag,
+				"app.kubernetes.io/component": pulumi.String(name),
+				"app.kubernetes.io/part-of":   pulumi.String("romeo"),
+				"instance":                    renv.instance,
+			},
+			Annotations: args.annotations,
+		},
+		Spec: appsv1.DeploymentSpecArgs{
+			Selector: metav1.LabelSelectorArgs{
+				MatchLabels: pulumi.StringMap{
+					"app.kubernetes.io/name":      pulumi.String("romeo"),
+					"app.kubernetes.io/version":   args.tag,
+					"app.kubernetes.io/component": pulumi.String(name),
+					"app.kubernetes.io/part-of":   pulumi.String("romeo"),
+					"instance":                    renv.instance,
+				},
+			},
+			Replicas: pulumi.Int(1),
+			Template: corev1.PodTemplateSpecArgs{
+				Metadata: metav1.ObjectMetaArgs{
+					Namespace: namespace,
+					Labels: pulumi.StringMap{
+						"app.kubernetes.io/name":      pulumi.String("romeo"),
+						"app.kubernetes.io/version":   args.tag,
+						"app.kubernetes.io/component": pulumi.String(name),
+						"app.kubernetes.io/part-of":   pulumi.String("romeo"),
+						"instance":                    renv.instance,
+					},
+				},
+				Spec: corev1.PodSpecArgs{
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:  pulumi.String("romeo"),
+							Image: romeoImage(args.registry, args.tag, args.Digest),
+							Ports: corev1.ContainerPortArray{
+								corev1.ContainerPortArgs{
+									ContainerPort: pulumi.Int(port),
+									Name:          pulumi.String("api"),
+								},
+							},
+							Env:             envs,
+							EnvFrom:         envFrom,
+							VolumeMounts:    volumeMounts,
+							Lifecycle:       lifecycle,
+							Resources:       args.Resources,
+							SecurityContext: securityContext,
+							LivenessProbe: corev1.ProbeArgs{
+								HttpGet: corev1.HTTPGetActionArgs{
+									Path: pulumi.Sprintf("%s/healthz", basePath),
+									Port: pulumi.String("api"),
+								},
+							},
+							ReadinessProbe: corev1.ProbeArgs{
+								HttpGet: corev1.HTTPGetActionArgs{
+									Path: pulumi.Sprintf("%s/readyz", basePath),
+									Port: pulumi.String("api"),
+								},
+							},
+						},
+					},
+					Volumes:                       volumes,
+					TerminationGracePeriodSeconds: gracePeriod,
+					SecurityContext:               podSecurityContext,
+					ImagePullSecrets:              localObjectReferences(pullSecrets),
+				},
+			},
+		},
+	}, opts...)
+	if err != nil {
+		return
+	}
+
+	// => Service (expose Romeo)
+	renv.svc, err = corev1.NewService(ctx, "romeo-svc-"+name, &corev1.ServiceArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Name:      resName,
+			Namespace: namespace,
+			Labels: pulumi.StringMap{
+				"app.kubernetes.io/component": pulumi.String(name),
+				"app.kubernetes.io/part-of":   pulumi.String("romeo"),
+				"instance":                    renv.instance,
+			},
+			Annotations: args.annotations,
+		},
+		Spec: &corev1.ServiceSpecArgs{
+			Type: pulumi.String(serviceType(args.Expose)),
+			Selector: pulumi.StringMap{
+				"app.kubernetes.io/name":      pulumi.String("romeo"),
+				"app.kubernetes.io/version":   args.tag,
+				"app.kubernetes.io/component": pulumi.String(name),
+				"app.kubernetes.io/part-of":   pulumi.String("romeo"),
+				"instance":                    renv.instance,
+			},
+			Ports: corev1.ServicePortArray{
+				corev1.ServicePortArgs{
+					TargetPort: pulumi.Int(port),
+					Port:       pulumi.Int(port),
+					Name:       pulumi.String("api"),
+				},
+			},
+		},
+	}, opts...)
+	if err != nil {
+		return
+	}
+
+	// => Ingress (expose Romeo), if required
+	if args.Expose == ExposeIngress {
+		tls := netwv1.IngressTLSArray{}
+		if args.Ingress.TLSSecretName != nil {
+			tls = append(tls, netwv1.IngressTLSArgs{
+				Hosts: pulumi.StringArray{
+					args.Ingress.Host,
+				},
+				SecretName: args.Ingress.TLSSecretName,
+			})
+		}
+
+		renv.ing, err = netwv1.NewIngress(ctx, "romeo-ing-"+name, &netwv1.IngressArgs{
+			Metadata: metav1.ObjectMetaArgs{
+				Name:      resName,
+				Namespace: namespace,
+				Labels: pulumi.StringMap{
+					"app.kubernetes.io/component": pulumi.String(name),
+					"app.kubernetes.io/part-of":   pulumi.String("romeo"),
+					"instance":                    renv.instance,
+				},
+				Annotations: ingressAnnotations(args),
+			},
+			Spec: netwv1.IngressSpecArgs{
+				IngressClassName: args.Ingress.ClassName,
+				Tls:              tls,
+				Rules: netwv1.IngressRuleArray{
+					netwv1.IngressRuleArgs{
+						Host: args.Ingress.Host,
+						Http: netwv1.HTTPIngressRuleValueArgs{
+							Paths: netwv1.HTTPIngressPathArray{
+								netwv1.HTTPIngressPathArgs{
+									Path:     pulumi.String("/"),
+									PathType: pulumi.String("Prefix"),
+									Backend: netwv1.IngressBackendArgs{
+										Service: netwv1.IngressServiceBackendArgs{
+											Name: renv.svc.Metadata.Name().Elem(),
+											Port: netwv1.ServiceBackendPortArgs{
+												Name: pulumi.String("api"),
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}, opts...)
+		if err != nil {
+			return
+		}
+	}
+
+	// => HTTPRoute (expose Romeo through a Gateway), if required
+	if args.Expose == ExposeGateway {
+		parentRef := pulumi.All(args.Gateway.Name, orEmpty(args.Gateway.Namespace), orEmpty(args.Gateway.SectionName)).
+			ApplyT(func(all []any) map[string]any {
+				ref := map[string]any{
+					"name": all[0].(string),
+				}
+				if ns := all[1].(string); ns != "" {
+					ref["namespace"] = ns
+				}
+				if section := all[2].(string); section != "" {
+					ref["sectionName"] = section
+				}
+				return ref
+			}).(pulumi.MapOutput)
+		// The inputs may be defined yet resolve empty (e.g. from an unset configuration)
+		hostnames := pulumi.All(orEmpty(args.Gateway.Hostname), orEmpty(args.Gateway.Address)).ApplyT(func(all []any) ([]string, error) {
+			hostname, addr := all[0].(string), all[1].(string)
+			if hostname == "" && addr == "" {
+				return nil, errors.New("exposing through a Gateway requires either a hostname or an address, both resolved empty")
+			}
+			if hostname == "" {
+				return []string{}, nil
+			}
+			return []string{hostname}, nil
+		}).(pulumi.StringArrayOutput)
+		pathPrefix := basePath.ApplyT(func(bp string) string {
+			if bp == "" {
+				return "/"
+			}
+			return bp
+		}).(pulumi.StringOutput)
+
+		renv.route, err = apiextensions.NewCustomResource(ctx, "romeo-route-"+name, &apiextensions.CustomResourceArgs{
+			ApiVersion: pulumi.String("gateway.networking.k8s.io/v1"),
+			Kind:       pulumi.String("HTTPRoute"),
+			Metadata: metav1.ObjectMetaArgs{
+				Name:      resName,
+				Namespace: namespace,
+				Labels: pulumi.StringMap{
+					"app.kubernetes.io/component": pulumi.String(name),
+					"app.kubernetes.io/part-of":   pulumi.String("romeo"),
+					"instance":                    renv.instance,
+				},
+				Annotations: args.annotations,
+			},
+			OtherFields: kubernetes.UntypedArgs{
+				"spec": pulumi.Map{
+					"parentRefs": pulumi.Array{
+						parentRef,
+					},
+					"hostnames": hostnames,
+					"rules": pulumi.Array{
+						pulumi.Map{
+							"matches": pulumi.Array{
+								pulumi.Map{
+									"path": pulumi.Map{
+										"type":  pulumi.String("PathPrefix"),
+										"value": pathPrefix,
+									},
+								},
+							},
+							"backendRefs": pulumi.Array{
+								pulumi.Map{
+									"name": renv.svc.Metadata.Name().Elem(),
+									"port": pulumi.Int(port),
+								},
+							},
+						},
+					},
+				},
+			},
+		}, opts...)
+		if err != nil {
+			return
+		}
+	}
+
+	if args.Harden {
+		renv.netpol, err = netwv1.NewNetworkPolicy(ctx, "netpol", &netwv1.NetworkPolicyArgs{
+			Metadata: metav1.ObjectMetaArgs{
+				Name:      resName,
+				Namespace: namespace,
+				Labels: pulumi.StringMap{
+					"app.kubernetes.io/component": pulumi.String(name),
+					"app.kubernetes.io/part-of":   pulumi.String("romeo"),
+					"instance":                    renv.instance,
+				},
+				Annotations: args.annotations,
+			},
+			Spec: netwv1.NetworkPolicySpecArgs{
+				PodSelector: metav1.LabelSelectorArgs{
+					MatchLabels: renv.dep.Spec.Template().Metadata().Labels(),
+				},
+				PolicyTypes: pulumi.ToStringArray([]string{
+					"Ingress",
+				}),
+				Ingress: ingressRules(args),
+			},
+		}, opts...)
+		if err != nil {
+			return
+		}
+
+		// The hardening of the created namespace denies the applications
+		// egress to the webserver, as it is in a private range
+		if args.createNamespace && args.AllowApps != nil {
+			apps := metav1.LabelSelectorArgs{}
+			if args.AllowApps.NamespaceSelector == nil && args.AllowApps.PodSelector != nil {
+				apps.MatchLabels = args.AllowApps.PodSelector
+			}
+			var appsName pulumi.StringPtrInput
+			if args.Instance != nil {
+				appsName = pulumi.Sprintf("%s-apps", renv.instance)
+			}
+			renv.appspol, err = netwv1.NewNetworkPolicy(ctx, "netpol-apps", &netwv1.NetworkPolicyArgs{
+				Metadata: metav1.ObjectMetaArgs{
+					Name:      appsName,
+					Namespace: namespace,
+					Labels: pulumi.StringMap{
+						"app.kubernetes.io/component": pulumi.String(name),
+						"app.kubernetes.io/part-of":   pulumi.String("romeo"),
+						"instance":                    renv.instance,
+					},
+					Annotations: args.annotations,
+				},
+				Spec: netwv1.NetworkPolicySpecArgs{
+					PodSelector: apps,
+					PolicyTypes: pulumi.ToStringArray([]string{
+						"Egress",
+					}),
+					Egress: netwv1.NetworkPolicyEgressRuleArray{
+						netwv1.NetworkPolicyEgressRuleArgs{
+							To: netwv1.NetworkPolicyPeerArray{
+								netwv1.NetworkPolicyPeerArgs{
+									PodSelector: metav1.LabelSelectorArgs{
+										MatchLabels: renv.dep.Spec.Template().Metadata().Labels(),
+									},
+								},
+							},
+							Ports: netwv1.NetworkPolicyPortArray{
+								netwv1.NetworkPolicyPortArgs{
+									Port: pulumi.Int(port),
+								},
+							},
+						},
+					},
+				},
+			}, opts...)
+			if err != nil {
+				return
+			}
+		}
+
+		// Same goes for the webserver egress to the sink, on stop.
+		// The ports are not restricted, as a Service port could differ from
+		// the one of the pods behind it.
+		if args.createNamespace && args.Preserve != nil && args.Preserve.Allow != nil {
+			var presName pulumi.StringPtrInput
+			if args.Instance != nil {
+				presName = pulumi.Sprintf("%s-preserve", renv.instance)
+			}
+			renv.prespol, err = netwv1.NewNetworkPolicy(ctx, "netpol-preserve", &netwv1.NetworkPolicyArgs{
+				Metadata: metav1.ObjectMetaArgs{
+					Name:      presName,
+					Namespace: namespace,
+					Labels: pulumi.StringMap{
+						"app.kubernetes.io/component": pulumi.String(name),
+						"app.kubernetes.io/part-of":   pulumi.String("romeo"),
+						"instance":                    renv.instance,
+					},
+					Annotations: args.annotations,
+				},
+				Spec: netwv1.NetworkPolicySpecArgs{
+					PodSelector: metav1.LabelSelectorArgs{
+						MatchLabels: renv.dep.Spec.Template().Metadata().Labels(),
+					},
+					PolicyTypes: pulumi.ToStringArray([]string{
+						"Egress",
+					}),
+					Egress: netwv1.NetworkPolicyEgressRuleArray{
+						netwv1.NetworkPolicyEgressRuleArgs{
+							To: args.Preserve.Allow.peers(),
+						},
+					},
+				},
+			}, opts...)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+func (renv *RomeoEnvironment) outputs(ctx *pulumi.Context, args *RomeoEnvironmentArgs) error {
+	if args.createNamespace {
+		renv.Namespace = renv.ns.Name
+	} else {
+		renv.Namespace = args.Namespace.ToStringOutput()
+	}
+
+	renv.ClaimName = renv.pvc.Metadata.Name().Elem()
+	renv.PodLabels = renv.dep.Spec.Template().Metadata().Labels()
+
+	renv.Affinity = pulumi.ToOutput((*corev1.Affinity)(nil)).(corev1.AffinityPtrOutput)
+	if args.CoLocate {
+		renv.Affinity = pulumi.All(renv.instance, renv.Namespace).ApplyT(func(all []any) *corev1.Affinity {
+			return coLocation(all[0].(string), all[1].(string))
+		}).(corev1.AffinityPtrOutput)
+	}
+
+	renv.Port = pulumi.Int(port).ToIntOutput()
+	switch args.Expose {
+	case ExposeClusterIP:
+		renv.URL = pulumi.Sprintf("http://%s.%s.svc.cluster.local:%d", renv.svc.Metadata.Name().Elem(), renv.Namespace, port)
+	case ExposeNodePort:
+		renv.Port = renv.svc.Spec.Ports().Index(pulumi.Int(0)).NodePort().Elem()
+		renv.URL = pulumi.Sprintf("http://%s:%d", args.nodeAddress, renv.Port)
+	case ExposeLoadBalancer:
+		addr := renv.svc.Status.ApplyT(func(st *corev1.ServiceStatus) string {
+			if st == nil || st.LoadBalancer == nil || len(st.LoadBalancer.Ingress) == 0 {
+				return ""
+			}
+			if ing := st.LoadBalancer.Ingress[0]; ing.Hostname != nil && *ing.Hostname != "" {
+				return *ing.Hostname
+			} else if ing.Ip != nil {
+				return *ing.Ip
+			}
+			return ""
+		}).(pulumi.StringOutput)
+		renv.URL = pulumi.Sprintf("http://%s:%d", addr, port)
+	case ExposeIngress:
+		scheme := pulumi.String("http").ToStringOutput()
+		if args.Ingress.TLSSecretName != nil {
+			scheme = args.Ingress.TLSSecretName.ToStringOutput().ApplyT(func(sec string) string {
+				if sec == "" {
+					return "http"
+				}
+				return "https"
+			}).(pulumi.StringOutput)
+		}
+		renv.URL = pulumi.Sprintf("%s://%s", scheme, args.Ingress.Host)
+	case ExposeGateway:
+		renv.URL = pulumi.All(orEmpty(args.Gateway.Address), orEmpty(args.Gateway.Hostname), renv.basePath).ApplyT(func(all []any) string {
+			addr, hostname, bp := all[0].(string), all[1].(string), all[2].(string)
+			if addr == "" {
+				addr = "http://" + hostname
+			}
+			return strings.TrimSuffix(addr, "/") + bp
+		}).(pulumi.StringOutput)
+	}
+
+	return ctx.RegisterResourceOutputs(renv, pulumi.Map{
+		"namespace":        renv.Namespace,
+		"claim-name":       renv.ClaimName,
+		"port":             renv.Port,
+		"url":              renv.URL,
+		"podLabels":        renv.PodLabels,
+		"affinity":         renv.Affinity,
+		"imagePullSecrets": renv.ImagePullSecrets,
+	})
+}
+
+// coLocation returns the affinity to run on the same node as the Romeo
+// webserver of the instance.
+func coLocation(instance, namespace string) *corev1.Affinity {
+	return &corev1.Affinity{
+		PodAffinity: &corev1.PodAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
+				{
+					LabelSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							"app.kubernetes.io/name": "romeo",
+							"instance":               instance,
+						},
+					},
+					Namespaces:  []string{namespace},
+					TopologyKey: "kubernetes.io/hostname",
+				},
+			},
+		},
+	}
+}
+
+// orEmpty returns the input as an output, or an empty string if not defined.
+func orEmpty(in pulumi.StringInput) pulumi.StringOutput {
+	if in == nil {
+		return pulumi.String("").ToStringOutput()
+	}
+	return in.ToStringOutput()
+}
+
+// serviceType returns the Service type to create for an exposure.
+func serviceType(expose string) string {
+	switch expose {
+	case ExposeNodePort, ExposeLoadBalancer:
+		return expose
+	default:
+		return ExposeClusterIP
+	}
+}
+
+// ingressAnnotations merges the Ingress annotations with the environment ones.
+func ingressAnnotations(args *RomeoEnvironmentArgs) pulumi.StringMapOutput {
+	if args.Ingress.Annotations == nil {
+		return args.annotations
+	}
+	return pulumi.All(args.annotations, args.Ingress.Annotations).ApplyT(func(all []any) map[string]string {
+		annotations := maps.Clone(all[1].(map[string]string))
+		maps.Copy(annotations, all[0].(map[string]string))
+		return annotations
+	}).(pulumi.StringMapOutput)
+}
+
+// restricted returns the container and pod security contexts complying with
+// the restricted Pod Security Standard.
+// The pod fsGroup lets the non-root webserver write into the volumes.
+func restricted() (corev1.SecurityContextPtrInput, corev1.PodSecurityContextPtrInput) {
+	seccomp := corev1.SeccompProfileArgs{
+		Type: pulumi.String("RuntimeDefault"),
+	}
+	container := corev1.SecurityContextArgs{
+		RunAsNonRoot:             pulumi.Bool(true),
+		RunAsUser:                pulumi.Int(restrictedID),
+		RunAsGroup:               pulumi.Int(restrictedID),
+		ReadOnlyRootFilesystem:   pulumi.Bool(true),
+		AllowPrivilegeEscalation: pulumi.Bool(false),
+		Capabilities: corev1.CapabilitiesArgs{
+			Drop: pulumi.ToStringArray([]string{"ALL"}),
+		},
+		SeccompProfile: seccomp,
+	}
+	pod := corev1.PodSecurityContextArgs{
+		RunAsNonRoot:   pulumi.Bool(true),
+		FsGroup:        pulumi.Int(restrictedID),
+		SeccompProfile: seccomp,
+	}
+	return container, pod
+}
+
+// check the allowed peers are valid, if any.
+func (allow *RomeoAllowArgs) check() error {
+	if allow == nil {
+		return nil
+	}
+	if len(allow.CIDRs) == 0 && allow.NamespaceSelector == nil && allow.PodSelector == nil {
+		return errors.New("allowing requires either CIDRs, a namespace selector or a pod selector")
+	}
+	for _, cidr := range allow.CIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return errors.Wrap(err, "invalid allowed CIDR")
+		}
+	}
+	return nil
+}
+
+// peers returns the NetworkPolicy peers of the allowed ones.
+func (allow *RomeoAllowArgs) peers() netwv1.NetworkPolicyPeerArray {
+	peers := netwv1.NetworkPolicyPeerArray{}
+	for _, cidr := range allow.CIDRs {
+		peers = append(peers, netwv1.NetworkPolicyPeerArgs{
+			IpBlock: netwv1.IPBlockArgs{
+				Cidr: pulumi.String(cidr),
+			},
+		})
+	}
+	if allow.NamespaceSelector != nil || allow.PodSelector != nil {
+		peer := netwv1.NetworkPolicyPeerArgs{}
+		if allow.NamespaceSelector != nil {
+			peer.NamespaceSelector = metav1.LabelSelectorArgs{
+				MatchLabels: allow.NamespaceSelector,
+			}
+		}
+		if allow.PodSelector != nil {
+			peer.PodSelector = metav1.LabelSelectorArgs{
+				MatchLabels: allow.PodSelector,
+			}
+		}
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+// ingressRules returns the NetworkPolicy rules granting the allowed peers
+// to reach the webserver API: the clients one, then the in-cluster
+// applications one if any.
+func ingressRules(args *RomeoEnvironmentArgs) netwv1.NetworkPolicyIngressRuleArray {
+	ports := netwv1.NetworkPolicyPortArray{
+		netwv1.NetworkPolicyPortArgs{
+			Port: pulumi.Int(port),
+		},
+	}
+
+	clients := netwv1.NetworkPolicyPeerArray{
+		netwv1.NetworkPolicyPeerArgs{
+			IpBlock: netwv1.IPBlockArgs{
+				Cidr: pulumi.String("0.0.0.0/0"),
+			},
+		},
+	}
+	if args.Allow != nil {
+		clients = args.Allow.peers()
+	}
+	rules := netwv1.NetworkPolicyIngressRuleArray{
+		netwv1.NetworkPolicyIngressRuleArgs{
+			From:  clients,
+			Ports: ports,
+		},
+	}
+
+	if args.AllowApps != nil {
+		rules = append(rules, netwv1.NetworkPolicyIngressRuleArgs{
+			From:  args.AllowApps.peers(),
+			Ports: ports,
+		})
+	}
+	return rules
+}
@@ -0,0 +1,773 @@
+package sdk_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/ctfer-io/romeo/sdk"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mocks struct{}
+
+func (mocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	return args.Name + "_id", args.Inputs, nil
+}
+
+func (mocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	return args.Args, nil
+}
+
+// recordMocks records the inputs of the resources by type token, to assert
+// on the rendered objects.
+type recordMocks struct {
+	mx     sync.Mutex
+	inputs map[string][]map[string]any
+}
+
+func (m *recordMocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.inputs == nil {
+		m.inputs = map[string][]map[string]any{}
+	}
+	m.inputs[args.TypeToken] = append(m.inputs[args.TypeToken], args.Inputs.Mappable())
+
+	// Name the Kubernetes resources as Pulumi auto-naming would
+	outs := args.Inputs.Copy()
+	if meta, ok := outs["metadata"]; ok && meta.IsObject() && !meta.ObjectValue().HasValue("name") {
+		meta.ObjectValue()["name"] = resource.NewStringProperty(args.Name)
+	}
+	return args.Name + "_id", outs, nil
+}
+
+func (m *recordMocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	return args.Args, nil
+}
+
+// of returns the recorded inputs of the resources of the type token.
+func (m *recordMocks) of(typ string) []map[string]any {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	return m.inputs[typ]
+}
+
+// field returns the value at the path of the object, made of map keys and
+// slice indexes, or nil if there is none. Secrets are looked through.
+func field(obj any, path ...any) any {
+	for _, p := range path {
+		if s, ok := obj.(*resource.Secret); ok {
+			obj = s.Element.Mappable()
+		}
+		switch k := p.(type) {
+		case string:
+			m, ok := obj.(map[string]any)
+			if !ok {
+				return nil
+			}
+			obj = m[k]
+		case int:
+			s, ok := obj.([]any)
+			if !ok || k >= len(s) {
+				return nil
+			}
+			obj = s[k]
+		}
+	}
+	return obj
+}
+
+// assertRestricted asserts whether the pod spec complies with the
+// restricted Pod Security Standard.
+func assertRestricted(assert *assert.Assertions, spec any, expect bool) {
+	assert.Equal(expect, field(spec, "securityContext", "runAsNonRoot") == true)
+	assert.Equal(expect, field(spec, "securityContext", "seccompProfile", "type") == "RuntimeDefault")
+	containers, _ := field(spec, "containers").([]any)
+	assert.NotEmpty(containers)
+	for _, c := range containers {
+		assert.Equal(expect, field(c, "securityContext", "readOnlyRootFilesystem") == true)
+		assert.Equal(expect, field(c, "securityContext", "allowPrivilegeEscalation") == false)
+		assert.Equal(expect, field(c, "securityContext", "capabilities", "drop", 0) == "ALL")
+	}
+}
+
+func Test_U_RomeoEnvironment(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Args      *sdk.RomeoEnvironmentArgs
+		ExpectErr bool
+	}{
+		"nil": {
+			Args: nil,
+		},
+		"empty-args": {
+			Args: &sdk.RomeoEnvironmentArgs{},
+		},
+		"local": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Registry: pulumi.String("localhost:5000"),
+			},
+		},
+		"storage": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				StorageClassName: pulumi.String("nfs"),
+				PVCAccessModes:   pulumi.ToStringArray([]string{"ReadWriteMany"}),
+			},
+		},
+		"storage-cluster-default": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				StorageClassName: pulumi.String(""),
+			},
+		},
+		"co-locate": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				PVCAccessModes: pulumi.ToStringArray([]string{"ReadWriteOnce"}),
+				CoLocate:       true,
+			},
+		},
+		"restricted": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				PodSecurityLevel: sdk.PodSecurityRestricted,
+				Resources: corev1.ResourceRequirementsArgs{
+					Requests: pulumi.StringMap{
+						"cpu":    pulumi.String("100m"),
+						"memory": pulumi.String("128Mi"),
+					},
+					Limits: pulumi.StringMap{
+						"memory": pulumi.String("512Mi"),
+					},
+				},
+			},
+		},
+		"restricted-existing-namespace": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Namespace:  pulumi.String("existing"),
+				Restricted: true,
+			},
+		},
+		"invalid-pod-security-level": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				PodSecurityLevel: "strict",
+			},
+			ExpectErr: true,
+		},
+		"private-registry": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Registry:         pulumi.String("registry.example.com/mirror"),
+				Digest:           pulumi.String("sha256:abababababababababababababababababababababababababababababababab"),
+				ImagePullSecrets: pulumi.ToStringArray([]string{"existing"}),
+				RegistryCredentials: &sdk.RomeoRegistryCredentialsArgs{
+					Username: pulumi.String("user"),
+					Password: pulumi.String("password"),
+				},
+			},
+		},
+		"invalid-digest": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Digest: pulumi.String("latest"),
+			},
+			ExpectErr: true,
+		},
+		"registry-credentials-no-password": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				RegistryCredentials: &sdk.RomeoRegistryCredentialsArgs{
+					Username: pulumi.String("user"),
+				},
+			},
+			ExpectErr: true,
+		},
+		"hardened-allow": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Harden: true,
+				Allow: &sdk.RomeoAllowArgs{
+					CIDRs: []string{"203.0.113.0/24"},
+					NamespaceSelector: pulumi.StringMap{
+						"kubernetes.io/metadata.name": pulumi.String("ingress-nginx"),
+					},
+				},
+				AllowApps: &sdk.RomeoAllowArgs{
+					PodSelector: pulumi.StringMap{
+						"romeo.ctfer.io/instrument": pulumi.String("true"),
+					},
+				},
+			},
+		},
+		"invalid-allow-cidr": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Harden: true,
+				Allow: &sdk.RomeoAllowArgs{
+					CIDRs: []string{"203.0.113.0"},
+				},
+			},
+			ExpectErr: true,
+		},
+		"empty-allow-apps": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Harden:    true,
+				AllowApps: &sdk.RomeoAllowArgs{},
+			},
+			ExpectErr: true,
+		},
+		"preserve-claim": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Preserve: &sdk.RomeoPreserveArgs{
+					ClaimName: pulumi.String("preserve"),
+				},
+			},
+		},
+		"preserve-s3": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Preserve: &sdk.RomeoPreserveArgs{
+					Sink:       pulumi.String("s3://bucket/coverout.zip"),
+					S3Endpoint: pulumi.String("minio.minio:9000"),
+					S3Insecure: true,
+					SecretName: pulumi.String("s3-credentials"),
+				},
+			},
+		},
+		"preserve-allow-empty": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Preserve: &sdk.RomeoPreserveArgs{
+					Sink:  pulumi.String("s3://bucket/coverout.zip"),
+					Allow: &sdk.RomeoAllowArgs{},
+				},
+			},
+			ExpectErr: true,
+		},
+		"preserve-no-sink": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Preserve: &sdk.RomeoPreserveArgs{},
+			},
+			ExpectErr: true,
+		},
+		"instance": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Instance:  pulumi.String("romeo"),
+				ClaimName: pulumi.String("claim"),
+			},
+		},
+		"expose-clusterip": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Expose: sdk.ExposeClusterIP,
+			},
+		},
+		"expose-nodeport": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Expose:      sdk.ExposeNodePort,
+				NodeAddress: pulumi.String("172.18.0.2"),
+			},
+		},
+		"expose-loadbalancer": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Expose: sdk.ExposeLoadBalancer,
+			},
+		},
+		"expose-ingress": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Expose: sdk.ExposeIngress,
+				Ingress: &sdk.RomeoIngressArgs{
+					Host:          pulumi.String("romeo.example.com"),
+					TLSSecretName: pulumi.String("romeo-tls"),
+				},
+			},
+		},
+		"expose-gateway": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Expose: sdk.ExposeGateway,
+				Gateway: &sdk.RomeoGatewayArgs{
+					Name:      pulumi.String("shared"),
+					Namespace: pulumi.String("gateways"),
+					Address:   pulumi.String("https://gw.example.com"),
+				},
+			},
+		},
+		"expose-gateway-hostname": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Expose: sdk.ExposeGateway,
+				Gateway: &sdk.RomeoGatewayArgs{
+					Name:     pulumi.String("shared"),
+					Hostname: pulumi.String("romeo-1.example.com"),
+				},
+			},
+		},
+		"expose-gateway-no-address": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Expose: sdk.ExposeGateway,
+				Gateway: &sdk.RomeoGatewayArgs{
+					Name: pulumi.String("shared"),
+				},
+			},
+			ExpectErr: true,
+		},
+		"expose-ingress-no-host": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Expose: sdk.ExposeIngress,
+			},
+			ExpectErr: true,
+		},
+		"ttl": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				TTL:    pulumi.String("2h"),
+				Expose: sdk.ExposeIngress,
+				Ingress: &sdk.RomeoIngressArgs{
+					Host: pulumi.String("romeo.example.com"),
+					Annotations: pulumi.StringMap{
+						"nginx.ingress.kubernetes.io/proxy-read-timeout": pulumi.String("300"),
+					},
+				},
+			},
+		},
+		"ttl-never": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				TTL: pulumi.String("0"),
+			},
+		},
+		"ttl-invalid": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				TTL: pulumi.String("one day"),
+			},
+			ExpectErr: true,
+		},
+		"expose-unsupported": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Expose: "Tunnel",
+			},
+			ExpectErr: true,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+				_, err := sdk.NewRomeoEnvironment(ctx, "romeo-test", tt.Args)
+				if tt.ExpectErr {
+					require.Error(err)
+				} else {
+					require.NoError(err)
+				}
+
+				return nil
+			}, pulumi.WithMocks("project", "stack", mocks{}))
+			assert.NoError(err)
+		})
+	}
+}
+
+func Test_U_RomeoEnvironmentGatewayResolved(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Gateway   *sdk.RomeoGatewayArgs
+		ExpectErr bool
+	}{
+		"hostname": {
+			Gateway: &sdk.RomeoGatewayArgs{
+				Name:     pulumi.String("shared"),
+				Hostname: pulumi.String("romeo-1.example.com"),
+			},
+		},
+		"address": {
+			Gateway: &sdk.RomeoGatewayArgs{
+				Name:     pulumi.String("shared"),
+				Hostname: pulumi.String(""),
+				Address:  pulumi.String("https://gw.example.com"),
+			},
+		},
+		"neither-hostname-nor-address": {
+			Gateway: &sdk.RomeoGatewayArgs{
+				Name:     pulumi.String("shared"),
+				Hostname: pulumi.String(""),
+				Address:  pulumi.String(""),
+			},
+			ExpectErr: true,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			assert := assert.New(t)
+			require := require.New(t)
+
+			// Both are defined, so only resolving them reveals they are empty
+			err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+				_, err := sdk.NewRomeoEnvironment(ctx, "romeo-test", &sdk.RomeoEnvironmentArgs{
+					Expose:  sdk.ExposeGateway,
+					Gateway: tt.Gateway,
+				})
+				require.NoError(err)
+				return nil
+			}, pulumi.WithMocks("project", "stack", mocks{}))
+			if tt.ExpectErr {
+				assert.ErrorContains(err, "resolved empty")
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+}
+
+func Test_U_RomeoEnvironmentRestricted(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Args   *sdk.RomeoEnvironmentArgs
+		Expect bool
+	}{
+		"baseline": {
+			Args:   &sdk.RomeoEnvironmentArgs{},
+			Expect: false,
+		},
+		"pod-security-level": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				PodSecurityLevel: sdk.PodSecurityRestricted,
+			},
+			Expect: true,
+		},
+		"restricted": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Namespace:  pulumi.String("existing"),
+				Restricted: true,
+			},
+			Expect: true,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			assert := assert.New(t)
+			require := require.New(t)
+
+			rec := &recordMocks{}
+			err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+				_, err := sdk.NewRomeoEnvironment(ctx, "romeo-test", tt.Args)
+				return err
+			}, pulumi.WithMocks("project", "stack", rec))
+			require.NoError(err)
+
+			deps := rec.of("kubernetes:apps/v1:Deployment")
+			require.Len(deps, 1)
+			spec := field(deps[0], "spec", "template", "spec")
+			assertRestricted(assert, spec, tt.Expect)
+
+			// The merges are written in an emptyDir, as the root filesystem
+			// is read-only
+			tmp := false
+			volumes, _ := field(spec, "volumes").([]any)
+			for _, v := range volumes {
+				if field(v, "name") == "tmp" {
+					tmp = field(v, "emptyDir") != nil
+				}
+			}
+			assert.Equal(tt.Expect, tmp)
+
+			home := ""
+			envs, _ := field(spec, "containers", 0, "env").([]any)
+			for _, env := range envs {
+				if field(env, "name") == "HOME" {
+					home, _ = field(env, "value").(string)
+				}
+			}
+			assert.Equal(tt.Expect, home == "/tmp")
+		})
+	}
+}
+
+func Test_U_RomeoEnvironmentImage(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Args              *sdk.RomeoEnvironmentArgs
+		ExpectImage       string
+		ExpectPullSecrets []any
+	}{
+		"docker-hub": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Tag: pulumi.String("v1.0.0"),
+			},
+			ExpectImage:       "ctferio/romeo:v1.0.0",
+			ExpectPullSecrets: []any{},
+		},
+		"private-registry": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Registry:         pulumi.String("registry.example.com/mirror"),
+				Tag:              pulumi.String("v1.0.0"),
+				Digest:           pulumi.String("sha256:abababababababababababababababababababababababababababababababab"),
+				ImagePullSecrets: pulumi.ToStringArray([]string{"existing"}),
+				RegistryCredentials: &sdk.RomeoRegistryCredentialsArgs{
+					Username: pulumi.String("user"),
+					Password: pulumi.String("password"),
+				},
+			},
+			ExpectImage: "registry.example.com/mirror/ctferio/romeo:v1.0.0@sha256:abababababababababababababababababababababababababababababababab",
+			ExpectPullSecrets: []any{
+				map[string]any{"name": "existing"},
+				map[string]any{"name": "romeo-pull-romeo-test"},
+			},
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			assert := assert.New(t)
+			require := require.New(t)
+
+			rec := &recordMocks{}
+			err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+				_, err := sdk.NewRomeoEnvironment(ctx, "romeo-test", tt.Args)
+				return err
+			}, pulumi.WithMocks("project", "stack", rec))
+			require.NoError(err)
+
+			deps := rec.of("kubernetes:apps/v1:Deployment")
+			require.Len(deps, 1)
+			spec := field(deps[0], "spec", "template", "spec")
+			assert.Equal(tt.ExpectImage, field(spec, "containers", 0, "image"))
+			assert.Equal(tt.ExpectPullSecrets, field(spec, "imagePullSecrets"))
+
+			// The registry credentials Secret authenticates to its host
+			secrets := rec.of("kubernetes:core/v1:Secret")
+			if tt.Args.RegistryCredentials == nil {
+				assert.Empty(secrets)
+				return
+			}
+			require.Len(secrets, 1)
+			assert.Equal("kubernetes.io/dockerconfigjson", field(secrets[0], "type"))
+			assert.Contains(field(secrets[0], "stringData", ".dockerconfigjson"), `"registry.example.com"`)
+		})
+	}
+}
+
+func Test_U_RomeoEnvironmentAllow(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Args              *sdk.RomeoEnvironmentArgs
+		ExpectClients     []any
+		ExpectApps        []any
+		ExpectAppsPolicy  bool
+		ExpectAppsSelects any
+	}{
+		"default": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Harden: true,
+			},
+			ExpectClients: []any{
+				map[string]any{"ipBlock": map[string]any{"cidr": "0.0.0.0/0"}},
+			},
+		},
+		"allow": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Harden: true,
+				Allow: &sdk.RomeoAllowArgs{
+					CIDRs: []string{"203.0.113.0/24"},
+					NamespaceSelector: pulumi.StringMap{
+						"kubernetes.io/metadata.name": pulumi.String("ingress-nginx"),
+					},
+				},
+				AllowApps: &sdk.RomeoAllowArgs{
+					PodSelector: pulumi.StringMap{
+						"romeo.ctfer.io/instrument": pulumi.String("true"),
+					},
+				},
+			},
+			ExpectClients: []any{
+				map[string]any{"ipBlock": map[string]any{"cidr": "203.0.113.0/24"}},
+				map[string]any{"namespaceSelector": map[string]any{"matchLabels": map[string]any{"kubernetes.io/metadata.name": "ingress-nginx"}}},
+			},
+			ExpectApps: []any{
+				map[string]any{"podSelector": map[string]any{"matchLabels": map[string]any{"romeo.ctfer.io/instrument": "true"}}},
+			},
+			ExpectAppsPolicy:  true,
+			ExpectAppsSelects: map[string]any{"romeo.ctfer.io/instrument": "true"},
+		},
+		"allow-apps-namespace": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Harden: true,
+				AllowApps: &sdk.RomeoAllowArgs{
+					NamespaceSelector: pulumi.StringMap{
+						"team": pulumi.String("apps"),
+					},
+				},
+			},
+			ExpectClients: []any{
+				map[string]any{"ipBlock": map[string]any{"cidr": "0.0.0.0/0"}},
+			},
+			ExpectApps: []any{
+				map[string]any{"namespaceSelector": map[string]any{"matchLabels": map[string]any{"team": "apps"}}},
+			},
+			// All the pods of the namespace could match the namespace selector
+			ExpectAppsPolicy:  true,
+			ExpectAppsSelects: nil,
+		},
+		"existing-namespace": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Namespace: pulumi.String("existing"),
+				Harden:    true,
+				AllowApps: &sdk.RomeoAllowArgs{
+					CIDRs: []string{"10.42.0.0/16"},
+				},
+			},
+			ExpectClients: []any{
+				map[string]any{"ipBlock": map[string]any{"cidr": "0.0.0.0/0"}},
+			},
+			ExpectApps: []any{
+				map[string]any{"ipBlock": map[string]any{"cidr": "10.42.0.0/16"}},
+			},
+			// Its policies are not managed by Romeo
+			ExpectAppsPolicy: false,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			assert := assert.New(t)
+			require := require.New(t)
+
+			rec := &recordMocks{}
+			err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+				_, err := sdk.NewRomeoEnvironment(ctx, "romeo-test", tt.Args)
+				return err
+			}, pulumi.WithMocks("project", "stack", rec))
+			require.NoError(err)
+
+			// The webserver policy is the ingress one, the applications one
+			// egresses to the webserver port
+			var server, apps map[string]any
+			for _, np := range rec.of("kubernetes:networking.k8s.io/v1:NetworkPolicy") {
+				switch {
+				case field(np, "spec", "ingress") != nil:
+					server = np
+				case field(np, "spec", "egress", 0, "ports", 0, "port") == float64(8080):
+					apps = np
+				}
+			}
+			require.NotNil(server)
+			expectRules := []any{
+				map[string]any{"from": tt.ExpectClients, "ports": []any{map[string]any{"port": float64(8080)}}},
+			}
+			if tt.ExpectApps != nil {
+				expectRules = append(expectRules, map[string]any{"from": tt.ExpectApps, "ports": []any{map[string]any{"port": float64(8080)}}})
+			}
+			assert.Equal(expectRules, field(server, "spec", "ingress"))
+
+			if !tt.ExpectAppsPolicy {
+				assert.Nil(apps)
+				return
+			}
+			require.NotNil(apps)
+			assert.Equal([]any{"Egress"}, field(apps, "spec", "policyTypes"))
+			assert.Equal(tt.ExpectAppsSelects, field(apps, "spec", "podSelector", "matchLabels"))
+			assert.Equal(field(server, "spec", "podSelector"), field(apps, "spec", "egress", 0, "to", 0, "podSelector"))
+		})
+	}
+}
+
+func Test_U_RomeoEnvironmentPreserveAllow(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Args         *sdk.RomeoEnvironmentArgs
+		ExpectPolicy bool
+	}{
+		"hardened": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Harden: true,
+				Preserve: &sdk.RomeoPreserveArgs{
+					Sink:       pulumi.String("s3://bucket/coverout.zip"),
+					S3Endpoint: pulumi.String("minio.minio:9000"),
+					Allow: &sdk.RomeoAllowArgs{
+						NamespaceSelector: pulumi.StringMap{
+							"kubernetes.io/metadata.name": pulumi.String("minio"),
+						},
+					},
+				},
+			},
+			ExpectPolicy: true,
+		},
+		"not-hardened": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Preserve: &sdk.RomeoPreserveArgs{
+					Sink:       pulumi.String("s3://bucket/coverout.zip"),
+					S3Endpoint: pulumi.String("minio.minio:9000"),
+					Allow: &sdk.RomeoAllowArgs{
+						NamespaceSelector: pulumi.StringMap{
+							"kubernetes.io/metadata.name": pulumi.String("minio"),
+						},
+					},
+				},
+			},
+			ExpectPolicy: false,
+		},
+		"existing-namespace": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Namespace: pulumi.String("existing"),
+				Harden:    true,
+				Preserve: &sdk.RomeoPreserveArgs{
+					Sink:       pulumi.String("s3://bucket/coverout.zip"),
+					S3Endpoint: pulumi.String("minio.minio:9000"),
+					Allow: &sdk.RomeoAllowArgs{
+						CIDRs: []string{"10.43.0.0/16"},
+					},
+				},
+			},
+			// Its policies are not managed by Romeo
+			ExpectPolicy: false,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			assert := assert.New(t)
+			require := require.New(t)
+
+			rec := &recordMocks{}
+			err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+				_, err := sdk.NewRomeoEnvironment(ctx, "romeo-test", tt.Args)
+				return err
+			}, pulumi.WithMocks("project", "stack", rec))
+			require.NoError(err)
+
+			// Unlike the hardening ones, the sink policy selects the webserver,
+			// and egresses to any port
+			var server, preserve map[string]any
+			for _, np := range rec.of("kubernetes:networking.k8s.io/v1:NetworkPolicy") {
+				switch {
+				case field(np, "spec", "ingress") != nil:
+					server = np
+				case field(np, "spec", "podSelector", "matchLabels") != nil && field(np, "spec", "egress", 0, "ports") == nil:
+					preserve = np
+				}
+			}
+
+			if !tt.ExpectPolicy {
+				assert.Nil(preserve)
+				return
+			}
+			require.NotNil(server)
+			require.NotNil(preserve)
+			assert.Equal([]any{"Egress"}, field(preserve, "spec", "policyTypes"))
+			assert.Equal(field(server, "spec", "podSelector"), field(preserve, "spec", "podSelector"))
+			assert.Equal([]any{
+				map[string]any{"namespaceSelector": map[string]any{"matchLabels": map[string]any{"kubernetes.io/metadata.name": "minio"}}},
+			}, field(preserve, "spec", "egress", 0, "to"))
+		})
+	}
+}
@@ -1,4 +1,4 @@
-package parts
+package sdk
 
 import (
 	"github.com/pkg/errors"
@@ -7,7 +7,18 @@ import (
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
+const (
+	// HardeningToken is the type token of [*Hardening].
+	HardeningToken = "ctfer-io:romeo:hardening"
+
+	// Type token [*Hardening] was registered with in the install and
+	// environment programs, before being shared.
+	legacyHardeningToken = "ctfer-io:romeo-install:hardening"
+)
+
 type (
+	// Hardening isolates a namespace with network policies: it denies all
+	// traffic by default, then grants DNS resolution and internet access.
 	Hardening struct {
 		pulumi.ResourceState
 
@@ -25,6 +36,7 @@ type (
 	}
 )
 
+// NewHardening creates a new [*Hardening] for the namespace it is given.
 func NewHardening(
 	ctx *pulumi.Context,
 	name string,
@@ -39,7 +51,8 @@ func NewHardening(
 	}
 
 	h := &Hardening{}
-	if err := ctx.RegisterComponentResource("ctfer-io:romeo-install:hardening", name, h, opts...); err != nil {
+	aliases := pulumi.Aliases([]pulumi.Alias{{Type: pulumi.String(legacyHardeningToken)}})
+	if err := ctx.RegisterComponentResource(HardeningToken, name, h, append(opts, aliases)...); err != nil {
 		return nil, err
 	}
 	opts = append(opts, pulumi.Parent(h))
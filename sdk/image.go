@@ -0,0 +1,97 @@
+package sdk
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+type (
+	// RomeoRegistryCredentialsArgs contains the credentials to pull the
+	// Romeo Docker image from a private registry with.
+	RomeoRegistryCredentialsArgs struct {
+		// Username to authenticate with. Required.
+		Username pulumi.StringInput
+
+		// Password (or token) to authenticate with. Required.
+		Password pulumi.StringInput
+	}
+)
+
+const (
+	// dockerHubServer is the server of the Docker Hub credentials.
+	dockerHubServer = "https://index.docker.io/v1/"
+)
+
+var digestRegex = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// validDigest tells whether the digest input is valid, if known.
+func validDigest(digest pulumi.StringInput) bool {
+	d, ok := digest.(pulumi.String)
+	return !ok || digestRegex.MatchString(string(d))
+}
+
+// romeoImage returns the reference of the Romeo Docker image, pinned to
+// the digest if any such that it could be verified against its SLSA
+// provenance.
+func romeoImage(registry, tag pulumi.StringOutput, digest pulumi.StringInput) pulumi.StringOutput {
+	if digest == nil {
+		return pulumi.Sprintf("%sctferio/romeo:%s", registry, tag)
+	}
+	return pulumi.All(registry, tag, digest).ApplyT(func(all []any) string {
+		registry, tag, digest := all[0].(string), all[1].(string), all[2].(string)
+		ref := registry + "ctferio/romeo:" + tag
+		if digest != "" {
+			ref += "@" + digest
+		}
+		return ref
+	}).(pulumi.StringOutput)
+}
+
+// dockerConfigJSON returns the content of a kubernetes.io/dockerconfigjson
+// Secret authenticating to the registry.
+// The registry server is its host, or Docker Hub if empty.
+func dockerConfigJSON(registry, username, password string) string {
+	server, _, _ := strings.Cut(strings.TrimSuffix(registry, "/"), "/")
+	if server == "" {
+		server = dockerHubServer
+	}
+
+	b, _ := json.Marshal(map[string]any{
+		"auths": map[string]any{
+			server: map[string]string{
+				"username": username,
+				"password": password,
+				"auth":     base64.StdEncoding.EncodeToString([]byte(username + ":" + password)),
+			},
+		},
+	})
+	return string(b)
+}
+
+// imagePullSecrets returns the pod spec imagePullSecrets of the names, if
+// any.
+func imagePullSecrets(names pulumi.StringArrayInput) corev1.LocalObjectReferenceArrayInput {
+	if names == nil {
+		return nil
+	}
+	return localObjectReferences(names.ToStringArrayOutput())
+}
+
+// localObjectReferences returns the references to the objects of the names,
+// e.g. for a pod spec imagePullSecrets.
+func localObjectReferences(names pulumi.StringArrayOutput) corev1.LocalObjectReferenceArrayOutput {
+	return names.ApplyT(func(names []string) []corev1.LocalObjectReference {
+		refs := make([]corev1.LocalObjectReference, 0, len(names))
+		for _, name := range names {
+			refs = append(refs, corev1.LocalObjectReference{
+				Name: pulumi.StringRef(name),
+			})
+		}
+		return refs
+	}).(corev1.LocalObjectReferenceArrayOutput)
+}
@@ -1,19 +1,27 @@
-package parts
+package sdk
 
 import (
 	"bytes"
 	_ "embed"
 	"encoding/base64"
-	"sync"
+	"fmt"
+	"strings"
 	"text/template"
 
 	"github.com/pkg/errors"
+	batchv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/batch/v1"
 	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
 	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	netwv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/networking/v1"
 	rbacv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/rbac/v1"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
+// InstallToken is the type token of [*RomeoInstall].
+const InstallToken = "ctfer-io:romeo-install:romeo"
+
+const defaultGCSchedule = "@hourly"
+
 type (
 	// RomeoInstall contains the RBAC ressources required by Romeo environments.
 	// In summary, it contains a Role, a ServiceAccount, a RoleBinding and a Secret
@@ -22,23 +30,27 @@ type (
 	RomeoInstall struct {
 		pulumi.ResourceState
 
-		ns  *Namespace
-		h   *Hardening
-		cr  *rbacv1.ClusterRole
-		r   *rbacv1.Role
-		sa  *corev1.ServiceAccount
-		crb *rbacv1.ClusterRoleBinding
-		rb  *rbacv1.RoleBinding
-		sec *corev1.Secret
+		ns   *Namespace
+		h    *Hardening
+		cr   *rbacv1.ClusterRole
+		r    *rbacv1.Role
+		sa   *corev1.ServiceAccount
+		crb  *rbacv1.ClusterRoleBinding
+		rb   *rbacv1.RoleBinding
+		wcr  *rbacv1.ClusterRole
+		wcrb *rbacv1.ClusterRoleBinding
+		sec  *corev1.Secret
+		gc   *batchv1.CronJob
+		gcn  *netwv1.NetworkPolicy
 
 		// Kubeconfig to store in the workflow secrets. Pass this to the Romeo
 		// steps for deploying ephemeral environments.
-		Kubeconfig pulumi.StringOutput
+		Kubeconfig pulumi.StringOutput `pulumi:"kubeconfig"`
 
 		// Namespace in which the resources have been created.
 		// By nature, should be considered ephemeral thus should not contain
 		// any production charge (only for tests/IVV purposes).
-		Namespace pulumi.StringOutput
+		Namespace pulumi.StringOutput `pulumi:"namespace"`
 	}
 
 	// RomeoInstallArgs contains all the arguments to setup Romeo environments.
@@ -55,6 +67,40 @@ type (
 		// Deny all traffic, deny inter-namespace communications,
 		// then grant DNS resolution, and grant internet communications.
 		Harden bool
+
+		// Webhook grants to deploy the Romeo webhook, i.e. to manage the
+		// cluster-wide MutatingWebhookConfigurations.
+		// Opt-in, as it lets the kubeconfig mutate the pods of any namespace.
+		Webhook bool
+
+		// GC, if set, deploys a CronJob garbage collecting the orphaned
+		// Romeo environments of the namespace (see [TTLAnnotation]).
+		GC *RomeoGCArgs
+	}
+
+	// RomeoGCArgs contains the arguments of the CronJob garbage collecting
+	// the orphaned Romeo environments.
+	RomeoGCArgs struct {
+		// Schedule of the CronJob, in the Cron format.
+		// Defaults to "@hourly".
+		Schedule pulumi.StringInput
+		schedule pulumi.StringOutput
+
+		// Tag of the Romeo Docker image. Defaults to "dev".
+		Tag pulumi.StringInput
+		tag pulumi.StringOutput
+
+		// Registry to fetch the Romeo Docker image from.
+		// If set empty, defaults to Docker Hub.
+		Registry pulumi.StringInput
+		registry pulumi.StringOutput
+
+		// Digest pins the Romeo Docker image (e.g. "sha256:...").
+		Digest pulumi.StringInput
+
+		// ImagePullSecrets are the names of the Secrets of the namespace to
+		// pull the Romeo Docker image with, e.g. from a private registry.
+		ImagePullSecrets pulumi.StringArrayInput
 	}
 )
 
@@ -67,10 +113,14 @@ func NewRomeoInstall(
 	args *RomeoInstallArgs,
 	opts ...pulumi.ResourceOption,
 ) (*RomeoInstall, error) {
+	if args != nil && args.GC != nil && args.GC.Digest != nil && !validDigest(args.GC.Digest) {
+		return nil, fmt.Errorf("invalid image digest %q, expected sha256:<hex>", args.GC.Digest)
+	}
+
 	rist := &RomeoInstall{}
 
 	args = rist.defaults(args)
-	if err := ctx.RegisterComponentResource("ctfer-io:romeo-install:romeo", name, rist, opts...); err != nil {
+	if err := ctx.RegisterComponentResource(InstallToken, name, rist, opts...); err != nil {
 		return nil, err
 	}
 	opts = append(opts, pulumi.Parent(rist))
@@ -92,16 +142,41 @@ func (rist *RomeoInstall) defaults(args *RomeoInstallArgs) *RomeoInstallArgs {
 		args.APIServer = pulumi.String("")
 	}
 
-	args.createNamespace = args.Namespace == nil
-	if args.Namespace != nil {
-		wg := &sync.WaitGroup{}
-		wg.Add(1)
-		args.Namespace.ToStringOutput().ApplyT(func(ns string) error {
-			args.createNamespace = ns == ""
-			wg.Done()
-			return nil
-		})
-		wg.Wait()
+	args.createNamespace = createNamespace(args.Namespace)
+	if !args.createNamespace {
+		args.Namespace = existingNamespace(args.Namespace)
+	}
+
+	if args.GC != nil {
+		args.GC.schedule = pulumi.String(defaultGCSchedule).ToStringOutput()
+		if args.GC.Schedule != nil {
+			args.GC.schedule = args.GC.Schedule.ToStringOutput().ApplyT(func(schedule string) string {
+				if schedule == "" {
+					return defaultGCSchedule
+				}
+				return schedule
+			}).(pulumi.StringOutput)
+		}
+
+		args.GC.tag = pulumi.String(defaultTag).ToStringOutput()
+		if args.GC.Tag != nil {
+			args.GC.tag = args.GC.Tag.ToStringOutput().ApplyT(func(tag string) string {
+				if tag == "" {
+					return defaultTag
+				}
+				return tag
+			}).(pulumi.StringOutput)
+		}
+
+		args.GC.registry = pulumi.String("").ToStringOutput()
+		if args.GC.Registry != nil {
+			args.GC.registry = args.GC.Registry.ToStringOutput().ApplyT(func(in string) string {
+				if in != "" && !strings.HasSuffix(in, "/") {
+					in += "/"
+				}
+				return in
+			}).(pulumi.StringOutput)
+		}
 	}
 
 	return args
@@ -198,6 +273,19 @@ func (rist *RomeoInstall) provision(
 					"endpoints",
 					"events",
 					"pods",
+					"secrets", // for the webhook certificate
+				}),
+			},
+			// The following is required when downloading through a port-forward
+			rbacv1.PolicyRuleArgs{
+				ApiGroups: pulumi.ToStringArray([]string{
+					"",
+				}),
+				Verbs: pulumi.ToStringArray([]string{
+					"create",
+				}),
+				Resources: pulumi.ToStringArray([]string{
+					"pods/portforward",
 				}),
 			},
 			rbacv1.PolicyRuleArgs{
@@ -217,7 +305,7 @@ func (rist *RomeoInstall) provision(
 					"replicasets",
 				}),
 			},
-			// The following is required when deploying in hardened mode
+			// The following is required when deploying in hardened mode, or exposing through an Ingress
 			rbacv1.PolicyRuleArgs{
 				ApiGroups: pulumi.ToStringArray([]string{
 					"networking.k8s.io",
@@ -232,6 +320,24 @@ func (rist *RomeoInstall) provision(
 				}),
 				Resources: pulumi.ToStringArray([]string{
 					"networkpolicies",
+					"ingresses",
+				}),
+			},
+			// The following is required when exposing through a Gateway
+			rbacv1.PolicyRuleArgs{
+				ApiGroups: pulumi.ToStringArray([]string{
+					"gateway.networking.k8s.io",
+				}),
+				Verbs: pulumi.ToStringArray([]string{
+					"create",
+					"delete",
+					"get",
+					"patch",
+					"list",
+					"watch",
+				}),
+				Resources: pulumi.ToStringArray([]string{
+					"httproutes",
 				}),
 			},
 		},
@@ -306,6 +412,66 @@ func (rist *RomeoInstall) provision(
 		return
 	}
 
+	// => ClusterRole and ClusterRoleBinding, if required to deploy the webhook.
+	// The MutatingWebhookConfigurations are named by Pulumi after random
+	// suffixes, one per environment, thus could not be restricted to some
+	// resourceNames.
+	if args.Webhook {
+		rist.wcr, err = rbacv1.NewClusterRole(ctx, "romeo-webhook-crole", &rbacv1.ClusterRoleArgs{
+			Metadata: metav1.ObjectMetaArgs{
+				Labels: pulumi.StringMap{
+					"app.kubernetes.io/component": pulumi.String("install"),
+					"app.kubernetes.io/part-of":   pulumi.String("romeo"),
+				},
+			},
+			Rules: rbacv1.PolicyRuleArray{
+				rbacv1.PolicyRuleArgs{
+					ApiGroups: pulumi.ToStringArray([]string{
+						"admissionregistration.k8s.io",
+					}),
+					Verbs: pulumi.ToStringArray([]string{
+						"create",
+						"delete",
+						"get",
+						"patch",
+						"list",
+						"watch",
+					}),
+					Resources: pulumi.ToStringArray([]string{
+						"mutatingwebhookconfigurations",
+					}),
+				},
+			},
+		}, opts...)
+		if err != nil {
+			return
+		}
+
+		rist.wcrb, err = rbacv1.NewClusterRoleBinding(ctx, "romeo-webhook-crole-binding", &rbacv1.ClusterRoleBindingArgs{
+			Metadata: metav1.ObjectMetaArgs{
+				Labels: pulumi.StringMap{
+					"app.kubernetes.io/component": pulumi.String("install"),
+					"app.kubernetes.io/part-of":   pulumi.String("romeo"),
+				},
+			},
+			RoleRef: rbacv1.RoleRefArgs{
+				ApiGroup: pulumi.String("rbac.authorization.k8s.io"),
+				Kind:     pulumi.String("ClusterRole"),
+				Name:     rist.wcr.Metadata.Name().Elem(),
+			},
+			Subjects: rbacv1.SubjectArray{
+				rbacv1.SubjectArgs{
+					Kind:      pulumi.String("ServiceAccount"),
+					Name:      rist.sa.Metadata.Name().Elem(),
+					Namespace: namespace,
+				},
+			},
+		}, opts...)
+		if err != nil {
+			return
+		}
+	}
+
 	// => Secret
 	rist.sec, err = corev1.NewSecret(ctx, "sa-secret", &corev1.SecretArgs{
 		Metadata: metav1.ObjectMetaArgs{
@@ -320,6 +486,95 @@ func (rist *RomeoInstall) provision(
 		return
 	}
 
+	// => CronJob (garbage collect orphaned environments), if required.
+	// It reuses the ServiceAccount, as it is granted to list and delete the
+	// environments resources. As it only reaches the API server, it always
+	// complies with the restricted Pod Security Standard.
+	if args.GC != nil {
+		securityContext, podSecurityContext := restricted()
+		labels := pulumi.StringMap{
+			"app.kubernetes.io/name":      pulumi.String("romeo"),
+			"app.kubernetes.io/component": pulumi.String("gc"),
+			"app.kubernetes.io/part-of":   pulumi.String("romeo"),
+		}
+		rist.gc, err = batchv1.NewCronJob(ctx, "romeo-gc", &batchv1.CronJobArgs{
+			Metadata: metav1.ObjectMetaArgs{
+				Namespace: namespace,
+				Labels:    labels,
+			},
+			Spec: batchv1.CronJobSpecArgs{
+				Schedule:          args.GC.schedule,
+				ConcurrencyPolicy: pulumi.String("Forbid"),
+				JobTemplate: batchv1.JobTemplateSpecArgs{
+					Spec: batchv1.JobSpecArgs{
+						BackoffLimit: pulumi.Int(0),
+						Template: corev1.PodTemplateSpecArgs{
+							Metadata: metav1.ObjectMetaArgs{
+								Labels: labels,
+							},
+							Spec: corev1.PodSpecArgs{
+								ServiceAccountName: rist.sa.Metadata.Name().Elem(),
+								RestartPolicy:      pulumi.String("Never"),
+								SecurityContext:    podSecurityContext,
+								ImagePullSecrets:   imagePullSecrets(args.GC.ImagePullSecrets),
+								Containers: corev1.ContainerArray{
+									corev1.ContainerArgs{
+										Name:  pulumi.String("gc"),
+										Image: romeoImage(args.GC.registry, args.GC.tag, args.GC.Digest),
+										Args: pulumi.StringArray{
+											pulumi.String("gc"),
+											pulumi.String("--namespace"),
+											namespace,
+										},
+										SecurityContext: securityContext,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}, opts...)
+		if err != nil {
+			return
+		}
+
+		// Grant access to the API server, denied by the hardening
+		if args.Harden {
+			rist.gcn, err = netwv1.NewNetworkPolicy(ctx, "romeo-gc-netpol", &netwv1.NetworkPolicyArgs{
+				Metadata: metav1.ObjectMetaArgs{
+					Namespace: namespace,
+					Labels:    labels,
+				},
+				Spec: netwv1.NetworkPolicySpecArgs{
+					PodSelector: metav1.LabelSelectorArgs{
+						MatchLabels: labels,
+					},
+					PolicyTypes: pulumi.ToStringArray([]string{
+						"Egress",
+					}),
+					Egress: netwv1.NetworkPolicyEgressRuleArray{
+						netwv1.NetworkPolicyEgressRuleArgs{
+							Ports: netwv1.NetworkPolicyPortArray{
+								netwv1.NetworkPolicyPortArgs{
+									Port:     pulumi.Int(443),
+									Protocol: pulumi.String("TCP"),
+								},
+								netwv1.NetworkPolicyPortArgs{
+									Port:     pulumi.Int(6443),
+									Protocol: pulumi.String("TCP"),
+								},
+							},
+						},
+					},
+				},
+			}, opts...)
+			if err != nil {
+				return
+			}
+		}
+	}
+
 	return
 }
 
@@ -330,7 +585,7 @@ func (rist *RomeoInstall) outputs(ctx *pulumi.Context, args *RomeoInstallArgs) e
 		rist.Namespace = args.Namespace.ToStringOutput()
 	}
 
-	rist.Kubeconfig = pulumi.All(rist.sec.Data, args.APIServer, rist.ns.Name).ApplyT(func(all []any) string {
+	rist.Kubeconfig = pulumi.All(rist.sec.Data, args.APIServer, rist.Namespace).ApplyT(func(all []any) string {
 		data := all[0].(map[string]string)
 		apiServer := all[1].(string)
 		ns := all[2].(string)
@@ -0,0 +1,130 @@
+package sdk_test
+
+import (
+	"testing"
+
+	"github.com/ctfer-io/romeo/sdk"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_U_RomeoInstall(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Args              *sdk.RomeoInstallArgs
+		ExpectErr         bool
+		ExpectImage       string
+		ExpectPullSecrets any
+		ExpectWebhook     bool
+	}{
+		"nil": {
+			Args: nil,
+		},
+		"namespace": {
+			Args: &sdk.RomeoInstallArgs{
+				Namespace: pulumi.String("romeo"),
+			},
+		},
+		"webhook": {
+			Args: &sdk.RomeoInstallArgs{
+				Webhook: true,
+			},
+			ExpectWebhook: true,
+		},
+		"gc": {
+			Args: &sdk.RomeoInstallArgs{
+				GC: &sdk.RomeoGCArgs{},
+			},
+			ExpectImage: "ctferio/romeo:dev",
+		},
+		"gc-harden": {
+			Args: &sdk.RomeoInstallArgs{
+				Harden: true,
+				GC: &sdk.RomeoGCArgs{
+					Schedule: pulumi.String("*/15 * * * *"),
+					Tag:      pulumi.String("v1.0.0"),
+					Registry: pulumi.String("localhost:5000"),
+				},
+			},
+			ExpectImage: "localhost:5000/ctferio/romeo:v1.0.0",
+		},
+		"gc-private-registry": {
+			Args: &sdk.RomeoInstallArgs{
+				GC: &sdk.RomeoGCArgs{
+					Registry:         pulumi.String("registry.example.com/mirror"),
+					Tag:              pulumi.String("v1.0.0"),
+					Digest:           pulumi.String("sha256:abababababababababababababababababababababababababababababababab"),
+					ImagePullSecrets: pulumi.ToStringArray([]string{"pull"}),
+				},
+			},
+			ExpectImage: "registry.example.com/mirror/ctferio/romeo:v1.0.0@sha256:abababababababababababababababababababababababababababababababab",
+			ExpectPullSecrets: []any{
+				map[string]any{"name": "pull"},
+			},
+		},
+		"gc-invalid-digest": {
+			Args: &sdk.RomeoInstallArgs{
+				GC: &sdk.RomeoGCArgs{
+					Digest: pulumi.String("latest"),
+				},
+			},
+			ExpectErr: true,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			rec := &recordMocks{}
+			err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+				_, err := sdk.NewRomeoInstall(ctx, "romeo-test", tt.Args)
+				if tt.ExpectErr {
+					require.Error(err)
+				} else {
+					require.NoError(err)
+				}
+				return nil
+			}, pulumi.WithMocks("project", "stack", rec))
+			assert.NoError(err)
+			if tt.ExpectErr {
+				return
+			}
+
+			// The MutatingWebhookConfigurations are only granted on demand,
+			// in a separate ClusterRole
+			webhook := 0
+			for _, cr := range rec.of("kubernetes:rbac.authorization.k8s.io/v1:ClusterRole") {
+				for _, rule := range field(cr, "rules").([]any) {
+					if field(rule, "resources", 0) == "mutatingwebhookconfigurations" {
+						webhook++
+						assert.Len(field(cr, "rules"), 1)
+					}
+				}
+			}
+			if tt.ExpectWebhook {
+				assert.Equal(1, webhook)
+				assert.Len(rec.of("kubernetes:rbac.authorization.k8s.io/v1:ClusterRoleBinding"), 2)
+			} else {
+				assert.Zero(webhook)
+				assert.Len(rec.of("kubernetes:rbac.authorization.k8s.io/v1:ClusterRoleBinding"), 1)
+			}
+
+			// The gc CronJob always complies with the restricted Pod Security
+			// Standard
+			crons := rec.of("kubernetes:batch/v1:CronJob")
+			if tt.Args == nil || tt.Args.GC == nil {
+				assert.Empty(crons)
+				return
+			}
+			require.Len(crons, 1)
+			spec := field(crons[0], "spec", "jobTemplate", "spec", "template", "spec")
+			assertRestricted(assert, spec, true)
+			assert.Equal(tt.ExpectImage, field(spec, "containers", 0, "image"))
+			assert.Equal(tt.ExpectPullSecrets, field(spec, "imagePullSecrets"))
+		})
+	}
+}
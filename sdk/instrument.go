@@ -0,0 +1,248 @@
+package sdk
+
+import (
+	"encoding/json"
+
+	"github.com/ctfer-io/romeo/sdk/instrument"
+	"github.com/pkg/errors"
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apps/v1"
+	batchv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/batch/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	k8scorev1 "k8s.io/api/core/v1"
+)
+
+// InstrumentArgs selects the workloads to instrument, and how.
+type InstrumentArgs struct {
+	// MatchLabels the workloads must have to be instrumented.
+	// If empty, instruments all the supported workloads.
+	MatchLabels map[string]string
+
+	// MountPath to mount the claim at in the instrumented containers.
+	// Defaults to "/etc/coverout".
+	MountPath string
+}
+
+// Instrument returns a resource option that instruments for coverage the
+// Deployments, StatefulSets, DaemonSets, Jobs and CronJobs it applies to,
+// and those of their children (e.g. when passed to a component).
+// Their pods mount the claim of the Romeo environment, with GOCOVERDIR
+// pointing to a per-pod subdirectory, as the Romeo webhook does. They are
+// deployed in the Romeo environment namespace if they don't define one, and
+// not instrumented if they define another one, as the claim could not be
+// mounted there.
+//
+//	app, err := NewApp(ctx, "app", appArgs, sdk.Instrument(romeo, nil))
+func Instrument(renv *RomeoEnvironment, args *InstrumentArgs) pulumi.ResourceOption {
+	return pulumi.Transformations([]pulumi.ResourceTransformation{
+		renv.Transformation(args),
+	})
+}
+
+// Transformation returns the resource transformation behind [Instrument].
+func (renv *RomeoEnvironment) Transformation(args *InstrumentArgs) pulumi.ResourceTransformation {
+	if args == nil {
+		args = &InstrumentArgs{}
+	}
+	mountPath := args.MountPath
+	if mountPath == "" {
+		mountPath = instrument.DefaultMountPath
+	}
+
+	return func(rta *pulumi.ResourceTransformationArgs) *pulumi.ResourceTransformationResult {
+		switch props := rta.Props.(type) {
+		case *appsv1.DeploymentArgs:
+			if props.Spec == nil {
+				return nil
+			}
+			props.Spec = renv.instrument(props.Metadata, props.Spec.ToDeploymentSpecPtrOutput(), args.MatchLabels, func(v any, claimName string, affinity *corev1.Affinity) (any, error) {
+				if spec := v.(*appsv1.DeploymentSpec); spec != nil {
+					return v, instrumentPod(&spec.Template, claimName, mountPath, affinity)
+				}
+				return v, nil
+			}).ApplyT(func(v any) *appsv1.DeploymentSpec {
+				return v.(*appsv1.DeploymentSpec)
+			}).(appsv1.DeploymentSpecPtrOutput)
+			props.Metadata = renv.namespaced(props.Metadata, args.MatchLabels)
+
+		case *appsv1.StatefulSetArgs:
+			if props.Spec == nil {
+				return nil
+			}
+			props.Spec = renv.instrument(props.Metadata, props.Spec.ToStatefulSetSpecPtrOutput(), args.MatchLabels, func(v any, claimName string, affinity *corev1.Affinity) (any, error) {
+				if spec := v.(*appsv1.StatefulSetSpec); spec != nil {
+					return v, instrumentPod(&spec.Template, claimName, mountPath, affinity)
+				}
+				return v, nil
+			}).ApplyT(func(v any) *appsv1.StatefulSetSpec {
+				return v.(*appsv1.StatefulSetSpec)
+			}).(appsv1.StatefulSetSpecPtrOutput)
+			props.Metadata = renv.namespaced(props.Metadata, args.MatchLabels)
+
+		case *appsv1.DaemonSetArgs:
+			if props.Spec == nil {
+				return nil
+			}
+			props.Spec = renv.instrument(props.Metadata, props.Spec.ToDaemonSetSpecPtrOutput(), args.MatchLabels, func(v any, claimName string, affinity *corev1.Affinity) (any, error) {
+				if spec := v.(*appsv1.DaemonSetSpec); spec != nil {
+					return v, instrumentPod(&spec.Template, claimName, mountPath, affinity)
+				}
+				return v, nil
+			}).ApplyT(func(v any) *appsv1.DaemonSetSpec {
+				return v.(*appsv1.DaemonSetSpec)
+			}).(appsv1.DaemonSetSpecPtrOutput)
+			props.Metadata = renv.namespaced(props.Metadata, args.MatchLabels)
+
+		case *batchv1.JobArgs:
+			if props.Spec == nil {
+				return nil
+			}
+			props.Spec = renv.instrument(props.Metadata, props.Spec.ToJobSpecPtrOutput(), args.MatchLabels, func(v any, claimName string, affinity *corev1.Affinity) (any, error) {
+				if spec := v.(*batchv1.JobSpec); spec != nil {
+					return v, instrumentPod(&spec.Template, claimName, mountPath, affinity)
+				}
+				return v, nil
+			}).ApplyT(func(v any) *batchv1.JobSpec {
+				return v.(*batchv1.JobSpec)
+			}).(batchv1.JobSpecPtrOutput)
+			props.Metadata = renv.namespaced(props.Metadata, args.MatchLabels)
+
+		case *batchv1.CronJobArgs:
+			if props.Spec == nil {
+				return nil
+			}
+			props.Spec = renv.instrument(props.Metadata, props.Spec.ToCronJobSpecPtrOutput(), args.MatchLabels, func(v any, claimName string, affinity *corev1.Affinity) (any, error) {
+				if spec := v.(*batchv1.CronJobSpec); spec != nil && spec.JobTemplate.Spec != nil {
+					return v, instrumentPod(&spec.JobTemplate.Spec.Template, claimName, mountPath, affinity)
+				}
+				return v, nil
+			}).ApplyT(func(v any) *batchv1.CronJobSpec {
+				return v.(*batchv1.CronJobSpec)
+			}).(batchv1.CronJobSpecPtrOutput)
+			props.Metadata = renv.namespaced(props.Metadata, args.MatchLabels)
+
+		default:
+			return nil
+		}
+
+		return &pulumi.ResourceTransformationResult{
+			Props: rta.Props,
+			Opts:  rta.Opts,
+		}
+	}
+}
+
+// instrument applies f to the workload spec, if the workload matches
+// the labels and lays in the Romeo environment namespace.
+func (renv *RomeoEnvironment) instrument(
+	meta metav1.ObjectMetaPtrInput,
+	spec pulumi.Output,
+	matchLabels map[string]string,
+	f func(spec any, claimName string, affinity *corev1.Affinity) (any, error),
+) pulumi.AnyOutput {
+	return pulumi.All(metaOutput(meta), spec, renv.ClaimName, renv.Affinity, renv.Namespace).ApplyT(func(all []any) (any, error) {
+		m := all[0].(*metav1.ObjectMeta)
+		if !matches(m, matchLabels) || !inNamespace(m, all[4].(string)) {
+			return all[1], nil
+		}
+		return f(all[1], all[2].(string), all[3].(*corev1.Affinity))
+	}).(pulumi.AnyOutput)
+}
+
+// namespaced sets the Romeo environment namespace on the workload if it
+// matches the labels and does not define one.
+func (renv *RomeoEnvironment) namespaced(meta metav1.ObjectMetaPtrInput, matchLabels map[string]string) metav1.ObjectMetaPtrOutput {
+	return pulumi.All(metaOutput(meta), renv.Namespace).ApplyT(func(all []any) *metav1.ObjectMeta {
+		m := all[0].(*metav1.ObjectMeta)
+		if !matches(m, matchLabels) {
+			return m
+		}
+		if m == nil {
+			m = &metav1.ObjectMeta{}
+		}
+		if m.Namespace == nil || *m.Namespace == "" {
+			ns := all[1].(string)
+			m.Namespace = &ns
+		}
+		return m
+	}).(metav1.ObjectMetaPtrOutput)
+}
+
+func metaOutput(meta metav1.ObjectMetaPtrInput) metav1.ObjectMetaPtrOutput {
+	if meta == nil {
+		return pulumi.ToOutput((*metav1.ObjectMeta)(nil)).(metav1.ObjectMetaPtrOutput)
+	}
+	return meta.ToObjectMetaPtrOutput()
+}
+
+// inNamespace tells whether the workload lays in the namespace, or will be
+// deployed in it as it defines none.
+func inNamespace(meta *metav1.ObjectMeta, namespace string) bool {
+	return meta == nil || meta.Namespace == nil || *meta.Namespace == "" || *meta.Namespace == namespace
+}
+
+func matches(meta *metav1.ObjectMeta, matchLabels map[string]string) bool {
+	for k, v := range matchLabels {
+		if meta == nil || meta.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// instrumentPod instruments the pod template as the Romeo webhook does,
+// see [instrument.Pod]. If the environment is co-located, the pod gets its
+// affinity.
+func instrumentPod(tpl *corev1.PodTemplateSpec, claimName, mountPath string, affinity *corev1.Affinity) error {
+	if tpl.Spec == nil {
+		return nil
+	}
+
+	pod := &k8scorev1.PodTemplateSpec{}
+	if err := convert(tpl, pod); err != nil {
+		return errors.Wrap(err, "converting pod template")
+	}
+	ok, err := instrument.Pod(&pod.ObjectMeta, &pod.Spec, instrument.Options{
+		ClaimName: claimName,
+		MountPath: mountPath,
+	})
+	if err != nil || !ok {
+		return err
+	}
+	out := &corev1.PodTemplateSpec{}
+	if err := convert(pod, out); err != nil {
+		return errors.Wrap(err, "converting pod template")
+	}
+	*tpl = *out
+
+	if affinity != nil {
+		coLocate(tpl.Spec, affinity)
+	}
+	return nil
+}
+
+// convert converts between the Pulumi and the Kubernetes API types, whose
+// fields match by their case-insensitive JSON names.
+func convert(in, out any) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+// coLocate adds the pod affinity terms of the Romeo environment to the
+// ones of the pod.
+func coLocate(spec *corev1.PodSpec, affinity *corev1.Affinity) {
+	if spec.Affinity == nil {
+		spec.Affinity = &corev1.Affinity{}
+	}
+	if spec.Affinity.PodAffinity == nil {
+		spec.Affinity.PodAffinity = &corev1.PodAffinity{}
+	}
+	spec.Affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution = append(
+		spec.Affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution,
+		affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution...,
+	)
+}
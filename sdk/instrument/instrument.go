@@ -0,0 +1,121 @@
+// Package instrument instruments pods for coverage, such that the Romeo
+// webhook, the manifests instrumentation and the SDK transformations mutate
+// them alike.
+package instrument
+
+import (
+	"path"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// Label marks the pods to instrument for coverage, when set to "true".
+	Label = "romeo.ctfer.io/instrument"
+
+	// Annotation is set on the instrumented pods, such that they are not
+	// instrumented twice.
+	Annotation = "romeo.ctfer.io/instrumented"
+
+	// DefaultMountPath is where the Romeo claim is mounted in the
+	// instrumented containers.
+	DefaultMountPath = "/etc/coverout"
+
+	// VolumeName is the name of the Romeo claim volume.
+	VolumeName = "romeo-coverages"
+
+	// PodNameEnv is the environment variable containing the pod name, used
+	// to export the coverages in a per-pod subdirectory.
+	PodNameEnv = "ROMEO_POD_NAME"
+)
+
+// Options to instrument pods with.
+type Options struct {
+	// ClaimName of the Romeo environment PersistentVolumeClaim. Required.
+	ClaimName string
+
+	// MountPath to mount the claim at. Defaults to [DefaultMountPath].
+	MountPath string
+}
+
+// Validate checks the options, and defaults the mount path.
+func (opts *Options) Validate() error {
+	if opts == nil || opts.ClaimName == "" {
+		return errors.New("no claim name defined")
+	}
+	if opts.MountPath == "" {
+		opts.MountPath = DefaultMountPath
+	}
+	if !path.IsAbs(opts.MountPath) {
+		return errors.Errorf("mount path %s is not absolute", opts.MountPath)
+	}
+	return nil
+}
+
+// Pod instruments a pod, or a pod template, for coverage: it adds the
+// Romeo claim volume, mounts a per-pod subdirectory of it in every
+// container and points GOCOVERDIR to it.
+// It returns false if the pod was already instrumented.
+func Pod(meta *metav1.ObjectMeta, spec *corev1.PodSpec, opts Options) (bool, error) {
+	if err := opts.Validate(); err != nil {
+		return false, err
+	}
+	if meta.Annotations[Annotation] == "true" {
+		return false, nil
+	}
+	for _, vol := range spec.Volumes {
+		if vol.Name == VolumeName {
+			return false, nil
+		}
+	}
+
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[Annotation] = "true"
+
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: VolumeName,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: opts.ClaimName,
+			},
+		},
+	})
+	for i := range spec.Containers {
+		container(&spec.Containers[i], opts.MountPath)
+	}
+	return true, nil
+}
+
+func container(c *corev1.Container, mountPath string) {
+	// The pod name has to be defined before GOCOVERDIR for the kubelet to
+	// expand it, so drop any previous definition.
+	env := make([]corev1.EnvVar, 0, len(c.Env)+2)
+	env = append(env, corev1.EnvVar{
+		Name: PodNameEnv,
+		ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{
+				FieldPath: "metadata.name",
+			},
+		},
+	})
+	for _, e := range c.Env {
+		if e.Name != PodNameEnv && e.Name != "GOCOVERDIR" {
+			env = append(env, e)
+		}
+	}
+	c.Env = append(env, corev1.EnvVar{
+		Name:  "GOCOVERDIR",
+		Value: mountPath,
+	})
+
+	// The kubelet creates the per-pod subdirectory, as Go does not
+	c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{
+		Name:        VolumeName,
+		MountPath:   mountPath,
+		SubPathExpr: "$(" + PodNameEnv + ")",
+	})
+}
@@ -0,0 +1,108 @@
+package instrument_test
+
+import (
+	"testing"
+
+	"github.com/ctfer-io/romeo/sdk/instrument"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_U_Pod(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Meta      metav1.ObjectMeta
+		Spec      corev1.PodSpec
+		Options   instrument.Options
+		ExpectErr bool
+		ExpectOK  bool
+	}{
+		"no-claim": {
+			Options:   instrument.Options{},
+			ExpectErr: true,
+		},
+		"relative-mount-path": {
+			Options: instrument.Options{
+				ClaimName: "claim",
+				MountPath: "coverout",
+			},
+			ExpectErr: true,
+		},
+		"pod": {
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "app"},
+					{
+						Name: "sidecar",
+						Env: []corev1.EnvVar{
+							{Name: "GOCOVERDIR", Value: "/tmp"},
+							{Name: "OTHER", Value: "value"},
+						},
+					},
+				},
+			},
+			Options: instrument.Options{
+				ClaimName: "claim",
+			},
+			ExpectOK: true,
+		},
+		"already-instrumented": {
+			Meta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					instrument.Annotation: "true",
+				},
+			},
+			Options: instrument.Options{
+				ClaimName: "claim",
+			},
+			ExpectOK: false,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			ok, err := instrument.Pod(&tt.Meta, &tt.Spec, tt.Options)
+			if tt.ExpectErr {
+				require.Error(err)
+				return
+			}
+			require.NoError(err)
+			require.Equal(tt.ExpectOK, ok)
+			if !ok {
+				return
+			}
+
+			assert.Equal("true", tt.Meta.Annotations[instrument.Annotation])
+			require.Len(tt.Spec.Volumes, 1)
+			assert.Equal("claim", tt.Spec.Volumes[0].PersistentVolumeClaim.ClaimName)
+			for _, c := range tt.Spec.Containers {
+				// The pod name comes first for GOCOVERDIR to expand, and is
+				// the only GOCOVERDIR
+				require.NotEmpty(c.Env)
+				assert.Equal(instrument.PodNameEnv, c.Env[0].Name)
+				gocoverdirs := 0
+				for _, e := range c.Env {
+					if e.Name == "GOCOVERDIR" {
+						gocoverdirs++
+						assert.Equal(instrument.DefaultMountPath, e.Value)
+					}
+				}
+				assert.Equal(1, gocoverdirs)
+
+				require.Len(c.VolumeMounts, 1)
+				assert.Equal("$("+instrument.PodNameEnv+")", c.VolumeMounts[0].SubPathExpr)
+			}
+
+			// Instrumenting twice is a no-op
+			ok, err = instrument.Pod(&tt.Meta, &tt.Spec, tt.Options)
+			require.NoError(err)
+			assert.False(ok)
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package sdk_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/ctfer-io/romeo/sdk"
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apps/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deploymentMocks records the inputs of the Deployments, and gives the
+// random strings a result.
+type deploymentMocks struct {
+	mx     sync.Mutex
+	inputs map[string]resource.PropertyMap
+}
+
+func (m *deploymentMocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	outs := args.Inputs.Copy()
+	switch args.TypeToken {
+	case "random:index/randomString:RandomString":
+		outs["result"] = resource.NewStringProperty("random")
+	case "kubernetes:apps/v1:Deployment":
+		m.mx.Lock()
+		m.inputs[args.Name] = args.Inputs
+		m.mx.Unlock()
+	}
+	return args.Name + "_id", outs, nil
+}
+
+func (m *deploymentMocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	return args.Args, nil
+}
+
+func Test_U_Instrument(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Labels           map[string]string
+		Namespace        string
+		CoLocate         bool
+		Args             *sdk.InstrumentArgs
+		ExpectInstrument bool
+	}{
+		"all": {
+			Args:             nil,
+			ExpectInstrument: true,
+		},
+		"matching": {
+			Labels: map[string]string{
+				"app": "app",
+			},
+			Args: &sdk.InstrumentArgs{
+				MatchLabels: map[string]string{
+					"app": "app",
+				},
+				MountPath: "/coverout",
+			},
+			ExpectInstrument: true,
+		},
+		"co-locate": {
+			CoLocate:         true,
+			Args:             nil,
+			ExpectInstrument: true,
+		},
+		"not-matching": {
+			Labels: map[string]string{
+				"app": "other",
+			},
+			Args: &sdk.InstrumentArgs{
+				MatchLabels: map[string]string{
+					"app": "app",
+				},
+			},
+			ExpectInstrument: false,
+		},
+		"other-namespace": {
+			Namespace:        "other",
+			Args:             nil,
+			ExpectInstrument: false,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			mocks := &deploymentMocks{
+				inputs: map[string]resource.PropertyMap{},
+			}
+			err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+				romeo, err := sdk.NewRomeoEnvironment(ctx, "romeo-test", &sdk.RomeoEnvironmentArgs{
+					Namespace: pulumi.String("romeo"),
+					CoLocate:  tt.CoLocate,
+				})
+				require.NoError(err)
+
+				var namespace pulumi.StringPtrInput
+				if tt.Namespace != "" {
+					namespace = pulumi.String(tt.Namespace)
+				}
+				_, err = appsv1.NewDeployment(ctx, "app", &appsv1.DeploymentArgs{
+					Metadata: metav1.ObjectMetaArgs{
+						Labels:    pulumi.ToStringMap(tt.Labels),
+						Namespace: namespace,
+					},
+					Spec: appsv1.DeploymentSpecArgs{
+						Template: corev1.PodTemplateSpecArgs{
+							Spec: corev1.PodSpecArgs{
+								Containers: corev1.ContainerArray{
+									corev1.ContainerArgs{
+										Name:  pulumi.String("app"),
+										Image: pulumi.String("app"),
+									},
+								},
+							},
+						},
+					},
+				}, sdk.Instrument(romeo, tt.Args))
+				require.NoError(err)
+
+				return nil
+			}, pulumi.WithMocks("project", "stack", mocks))
+			require.NoError(err)
+
+			inputs, ok := mocks.inputs["app"]
+			require.True(ok)
+			meta := inputs["metadata"].ObjectValue()
+			spec := inputs["spec"].ObjectValue()["template"].ObjectValue()["spec"].ObjectValue()
+			if !tt.ExpectInstrument {
+				if tt.Namespace == "" {
+					assert.False(meta.HasValue("namespace"))
+				} else {
+					assert.Equal(tt.Namespace, meta["namespace"].StringValue())
+				}
+				assert.False(spec.HasValue("volumes"))
+				assert.False(spec.HasValue("affinity"))
+				return
+			}
+
+			assert.Equal("romeo", meta["namespace"].StringValue())
+			volumes := spec["volumes"].ArrayValue()
+			require.Len(volumes, 1)
+			assert.Equal("random", volumes[0].ObjectValue()["persistentVolumeClaim"].ObjectValue()["claimName"].StringValue())
+			annotations := inputs["spec"].ObjectValue()["template"].ObjectValue()["metadata"].ObjectValue()["annotations"].ObjectValue()
+			assert.Equal("true", annotations["romeo.ctfer.io/instrumented"].StringValue())
+			container := spec["containers"].ArrayValue()[0].ObjectValue()
+			assert.Equal("app", container["image"].StringValue())
+			assert.Len(container["env"].ArrayValue(), 2)
+			assert.Len(container["volumeMounts"].ArrayValue(), 1)
+			if !tt.CoLocate {
+				assert.False(spec.HasValue("affinity"))
+				return
+			}
+			terms := spec["affinity"].ObjectValue()["podAffinity"].ObjectValue()["requiredDuringSchedulingIgnoredDuringExecution"].ArrayValue()
+			require.Len(terms, 1)
+			term := terms[0].ObjectValue()
+			assert.Equal("kubernetes.io/hostname", term["topologyKey"].StringValue())
+			assert.Equal("romeo", term["namespaces"].ArrayValue()[0].StringValue())
+		})
+	}
+}
@@ -1,17 +1,36 @@
-package parts
+package sdk
 
 import (
 	"fmt"
+	"maps"
 
+	"github.com/pkg/errors"
 	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
 	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
 	"github.com/pulumi/pulumi-random/sdk/v4/go/random"
 	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
 )
 
+const (
+	// NamespaceToken is the type token of [*Namespace].
+	NamespaceToken = "ctfer-io:romeo:namespace"
+
+	// Type token [*Namespace] was registered with in the install and
+	// environment programs, before being shared.
+	legacyNamespaceToken = "ctfer-io:romeo-install:namespace"
+)
+
+// Pod Security Standards levels a [*Namespace] could enforce.
+const (
+	PodSecurityPrivileged = "privileged"
+	PodSecurityBaseline   = "baseline"
+	PodSecurityRestricted = "restricted"
+)
+
 type (
 	// Namespace is an isolated and secured Kubernetes namespace, with security
-	// annotations for enforce and warn to baseline, and versions to latest.
+	// annotations for enforce and warn to its Pod Security level (baseline by
+	// default), and versions to latest.
 	// It is deployed with a basic set of network policies that ensure the network
 	// isolation toward adjacent namespaces, and deny all non-explicitly-granted
 	// traffic.
@@ -36,6 +55,15 @@ type (
 
 		// AdditionalLabels to pass to the namespace, mostly for filtering purposes.
 		AdditionalLabels pulumi.StringMapInput
+
+		// AdditionalAnnotations to pass to the namespace.
+		AdditionalAnnotations pulumi.StringMapInput
+
+		// PodSecurityLevel to enforce and warn, either [PodSecurityPrivileged],
+		// [PodSecurityBaseline] or [PodSecurityRestricted].
+		// Defaults to [PodSecurityBaseline].
+		PodSecurityLevel pulumi.StringInput
+		podSecurityLevel pulumi.StringOutput
 	}
 )
 
@@ -49,7 +77,8 @@ func NewNamespace(
 	ns := &Namespace{}
 
 	args = ns.defaults(args)
-	if err := ctx.RegisterComponentResource("ctfer-io:romeo-install:namespace", name, ns, opts...); err != nil {
+	aliases := pulumi.Aliases([]pulumi.Alias{{Type: pulumi.String(legacyNamespaceToken)}})
+	if err := ctx.RegisterComponentResource(NamespaceToken, name, ns, append(opts, aliases)...); err != nil {
 		return nil, err
 	}
 	opts = append(opts, pulumi.Parent(ns))
@@ -75,6 +104,17 @@ func (ns *Namespace) defaults(args *NamespaceArgs) *NamespaceArgs {
 		args.AdditionalLabels = pulumi.StringMap{}.ToStringMapOutput()
 	}
 
+	// Default Pod Security level to baseline
+	args.podSecurityLevel = pulumi.String(PodSecurityBaseline).ToStringOutput()
+	if args.PodSecurityLevel != nil {
+		args.podSecurityLevel = args.PodSecurityLevel.ToStringOutput().ApplyT(func(level string) string {
+			if level == "" {
+				return PodSecurityBaseline
+			}
+			return level
+		}).(pulumi.StringOutput)
+	}
+
 	return args
 }
 
@@ -101,14 +141,18 @@ func (ns *Namespace) provision(ctx *pulumi.Context, args *NamespaceArgs, opts ..
 				}
 				return fmt.Sprintf("%s-%s", name, all[1])
 			}).(pulumi.StringOutput),
-			Labels: args.AdditionalLabels.ToStringMapOutput().ApplyT(func(labels map[string]string) map[string]string {
+			Labels: pulumi.All(args.AdditionalLabels, args.podSecurityLevel).ApplyT(func(all []any) map[string]string {
 				// Use the additional labels as a base, add/overwrite our own labels
-				labels["pod-security.kubernetes.io/enforce"] = "baseline"
+				labels := map[string]string{}
+				maps.Copy(labels, all[0].(map[string]string))
+				level := all[1].(string)
+				labels["pod-security.kubernetes.io/enforce"] = level
 				labels["pod-security.kubernetes.io/enforce-version"] = "latest"
-				labels["pod-security.kubernetes.io/warn"] = "baseline"
+				labels["pod-security.kubernetes.io/warn"] = level
 				labels["pod-security.kubernetes.io/warn-version"] = "latest"
 				return labels
 			}).(pulumi.StringMapOutput),
+			Annotations: args.AdditionalAnnotations,
 		},
 	}, opts...)
 	if err != nil {
@@ -127,3 +171,34 @@ func (ns *Namespace) outputs(ctx *pulumi.Context) error {
 		"labels": ns.Labels,
 	})
 }
+
+// existingNamespace returns the namespace input, failing once resolved if
+// it is empty, as an input that is not known to be empty upfront is not
+// created (see [createNamespace]).
+func existingNamespace(namespace pulumi.StringInput) pulumi.StringOutput {
+	return namespace.ToStringOutput().ApplyT(func(ns string) (string, error) {
+		if ns == "" {
+			return "", errors.New("the namespace resolved empty, leave it undefined to create one")
+		}
+		return ns, nil
+	}).(pulumi.StringOutput)
+}
+
+// createNamespace tells whether a namespace has to be created for the given
+// input, i.e. it is not set or is an empty string.
+// Any other input, such as the name output of a [*Namespace] managed by the
+// caller, is considered an existing namespace. This is decided without waiting
+// for the input to resolve, so that it does not block on unknown values, thus
+// it fails once resolved if empty (see [existingNamespace]).
+func createNamespace(namespace pulumi.StringInput) bool {
+	switch ns := namespace.(type) {
+	case nil:
+		return true
+	case pulumi.String:
+		return ns == ""
+	case *pulumi.String:
+		return ns == nil || *ns == ""
+	default:
+		return false
+	}
+}
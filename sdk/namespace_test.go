@@ -0,0 +1,78 @@
+package sdk_test
+
+import (
+	"testing"
+
+	"github.com/ctfer-io/romeo/sdk"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_U_Namespace(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		DryRun    bool
+		Empty     bool
+		ExpectErr bool
+	}{
+		"preview": {
+			DryRun: true,
+		},
+		"update": {
+			DryRun: false,
+		},
+		"empty": {
+			DryRun:    false,
+			Empty:     true,
+			ExpectErr: true,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			// Compose the components in a namespace managed by the program,
+			// whose name is unknown during previews. It is auto-named by the
+			// mocks, as it would be by Pulumi.
+			err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+				ns, err := sdk.NewNamespace(ctx, "tests", &sdk.NamespaceArgs{
+					Name: pulumi.String("tests"),
+				})
+				require.NoError(err)
+				name := ns.Name
+				if tt.Empty {
+					// e.g. a stack reference output that is not defined
+					name = pulumi.String("").ToStringOutput()
+				}
+
+				_, err = sdk.NewHardening(ctx, "tests", &sdk.HardeningArgs{
+					Name: ns.Name,
+				})
+				require.NoError(err)
+
+				_, err = sdk.NewRomeoInstall(ctx, "install", &sdk.RomeoInstallArgs{
+					Namespace: name,
+				})
+				require.NoError(err)
+
+				_, err = sdk.NewRomeoEnvironment(ctx, "environment", &sdk.RomeoEnvironmentArgs{
+					Namespace: name,
+				})
+				require.NoError(err)
+
+				return nil
+			}, pulumi.WithMocks("project", "stack", &recordMocks{}), func(info *pulumi.RunInfo) {
+				info.DryRun = tt.DryRun
+			})
+			if tt.ExpectErr {
+				assert.ErrorContains(err, "the namespace resolved empty")
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+}
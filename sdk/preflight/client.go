@@ -0,0 +1,43 @@
+package preflight
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// NewClient builds a Kubernetes client out of the kubeconfig, either a path
+// or its content. If empty, the Kubernetes loading rules apply (e.g.
+// KUBECONFIG).
+func NewClient(kubeconfig string) (kubernetes.Interface, error) {
+	var cc clientcmd.ClientConfig
+	if kubeconfig == "" {
+		cc = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			clientcmd.NewDefaultClientConfigLoadingRules(),
+			&clientcmd.ConfigOverrides{},
+		)
+	} else {
+		raw := []byte(kubeconfig)
+		//nolint:gosec // G304 -- the kubeconfig path is user-provided on purpose
+		if b, err := os.ReadFile(kubeconfig); err == nil {
+			raw = b
+		}
+		var err error
+		cc, err = clientcmd.NewClientConfigFromBytes(raw)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading kubeconfig")
+		}
+	}
+
+	cfg, err := cc.ClientConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "building REST configuration")
+	}
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "building Kubernetes client")
+	}
+	return client, nil
+}
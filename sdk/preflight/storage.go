@@ -0,0 +1,164 @@
+// Package preflight inspects a Kubernetes cluster before deploying a Romeo
+// environment in it, such that misconfigurations fail early with an
+// actionable error rather than a PersistentVolumeClaim pending forever.
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Annotations marking the default StorageClass of a cluster.
+const (
+	DefaultClassAnnotation     = "storageclass.kubernetes.io/is-default-class"
+	BetaDefaultClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
+// sharedProvisioners serve volumes that could be mounted by many pods at
+// once, either through a network filesystem or from the node they are
+// bound to (the pods then follow the volume node affinity).
+var sharedProvisioners = []string{
+	"rancher.io/local-path",
+	"k8s.io/minikube-hostpath",
+	"microk8s.io/hostpath",
+	"driver.longhorn.io",
+	"efs.csi.aws.com",
+	"file.csi.azure.com",
+	"kubernetes.io/azure-file",
+	"filestore.csi.storage.gke.io",
+	"cephfs.csi.ceph.com",
+	"nfs.csi.k8s.io",
+	"smb.csi.k8s.io",
+}
+
+// blockProvisioners serve block volumes, that a single node could mount.
+var blockProvisioners = []string{
+	"ebs.csi.aws.com",
+	"kubernetes.io/aws-ebs",
+	"pd.csi.storage.gke.io",
+	"kubernetes.io/gce-pd",
+	"disk.csi.azure.com",
+	"kubernetes.io/azure-disk",
+	"rbd.csi.ceph.com",
+	"cinder.csi.openstack.org",
+	"openebs.io/local",
+}
+
+// StorageOptions are the storage choices of the caller, if any.
+type StorageOptions struct {
+	// StorageClassName to use. If empty, the cluster default one is chosen.
+	StorageClassName string
+
+	// AccessMode of the PersistentVolumeClaim. If empty, the most workable
+	// one for the StorageClass provisioner is chosen.
+	AccessMode string
+}
+
+// Storage is the storage to deploy a Romeo environment with.
+type Storage struct {
+	StorageClassName string
+	AccessMode       string
+
+	// Warnings about the storage, e.g. the coverage-monitored pods that
+	// must run on the same node as the Romeo webserver.
+	Warnings []string
+}
+
+// ChooseStorage inspects the StorageClasses of the cluster, and chooses the
+// storage of a Romeo environment according to the options.
+// It fails if the coverages could not be shared between the Romeo webserver
+// and the coverage-monitored pods.
+// If the StorageClasses could not be listed (e.g. missing RBAC), it returns
+// the options as is, with a warning.
+func ChooseStorage(ctx context.Context, client kubernetes.Interface, opts StorageOptions) (*Storage, error) {
+	st := &Storage{
+		StorageClassName: opts.StorageClassName,
+		AccessMode:       opts.AccessMode,
+	}
+
+	// Whatever the StorageClass, some access modes prevent sharing
+	switch corev1.PersistentVolumeAccessMode(opts.AccessMode) {
+	case corev1.ReadWriteOncePod:
+		return nil, fmt.Errorf("access mode %s only lets the Romeo webserver mount the claim, thus coverages could not be shared: use ReadWriteMany, or ReadWriteOnce with the pods on the same node", opts.AccessMode)
+	case corev1.ReadOnlyMany:
+		return nil, fmt.Errorf("access mode %s prevents the pods from writing their coverages: use ReadWriteMany, or ReadWriteOnce with the pods on the same node", opts.AccessMode)
+	}
+
+	list, err := client.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		if !apierrors.IsForbidden(err) {
+			return nil, errors.Wrap(err, "listing StorageClasses")
+		}
+		st.Warnings = append(st.Warnings, "StorageClasses could not be listed, thus the storage is not checked: grant list on storageclasses.storage.k8s.io for preflight checks")
+		if st.AccessMode == "" {
+			st.AccessMode = string(corev1.ReadWriteMany)
+		}
+		return st, nil
+	}
+
+	sc, err := storageClass(list.Items, opts.StorageClassName)
+	if err != nil {
+		return nil, err
+	}
+	st.StorageClassName = sc.Name
+
+	shared := slices.Contains(sharedProvisioners, sc.Provisioner) || strings.Contains(sc.Provisioner, "nfs")
+	block := slices.Contains(blockProvisioners, sc.Provisioner)
+	switch {
+	case st.AccessMode == "" && shared:
+		st.AccessMode = string(corev1.ReadWriteMany)
+	case st.AccessMode == "":
+		st.AccessMode = string(corev1.ReadWriteOnce)
+		if !block {
+			st.Warnings = append(st.Warnings, fmt.Sprintf("provisioner %s of StorageClass %s is unknown, defaulting to access mode ReadWriteOnce", sc.Provisioner, sc.Name))
+		}
+	case st.AccessMode == string(corev1.ReadWriteMany) && block:
+		return nil, fmt.Errorf("StorageClass %s provisioner %s does not support access mode ReadWriteMany: use ReadWriteOnce with the pods on the same node, or a StorageClass backed by a shared filesystem (e.g. NFS)", sc.Name, sc.Provisioner)
+	}
+
+	if st.AccessMode == string(corev1.ReadWriteOnce) {
+		st.Warnings = append(st.Warnings, fmt.Sprintf("access mode ReadWriteOnce requires the coverage-monitored pods to run on the same node as the Romeo webserver to share StorageClass %s volume", sc.Name))
+	}
+	return st, nil
+}
+
+// storageClass returns the StorageClass of the given name, or the default
+// one if empty.
+func storageClass(scs []storagev1.StorageClass, name string) (*storagev1.StorageClass, error) {
+	names := make([]string, 0, len(scs))
+	for _, sc := range scs {
+		names = append(names, sc.Name)
+	}
+	if len(scs) == 0 {
+		return nil, errors.New("no StorageClass in the cluster: install a provisioner (e.g. an NFS one) for the PersistentVolumeClaim to be bound")
+	}
+
+	if name != "" {
+		for i := range scs {
+			if scs[i].Name == name {
+				return &scs[i], nil
+			}
+		}
+		return nil, fmt.Errorf("StorageClass %s does not exist, choose one among %s", name, strings.Join(names, ", "))
+	}
+
+	for i := range scs {
+		if isDefault(&scs[i]) {
+			return &scs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no default StorageClass in the cluster, choose one among %s", strings.Join(names, ", "))
+}
+
+func isDefault(sc *storagev1.StorageClass) bool {
+	return sc.Annotations[DefaultClassAnnotation] == "true" || sc.Annotations[BetaDefaultClassAnnotation] == "true"
+}
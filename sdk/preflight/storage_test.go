@@ -0,0 +1,193 @@
+package preflight_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ctfer-io/romeo/sdk/preflight"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func storageClass(name, provisioner string, isDefault bool) *storagev1.StorageClass {
+	sc := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Provisioner: provisioner,
+	}
+	if isDefault {
+		sc.Annotations = map[string]string{
+			preflight.DefaultClassAnnotation: "true",
+		}
+	}
+	return sc
+}
+
+func Test_U_ChooseStorage(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Objects       []runtime.Object
+		Forbidden     bool
+		Options       preflight.StorageOptions
+		ExpectStorage *preflight.Storage
+		ExpectErr     bool
+	}{
+		"default-shared": {
+			Objects: []runtime.Object{
+				storageClass("gp3", "ebs.csi.aws.com", false),
+				storageClass("nfs", "nfs.csi.k8s.io", true),
+			},
+			ExpectStorage: &preflight.Storage{
+				StorageClassName: "nfs",
+				AccessMode:       "ReadWriteMany",
+			},
+		},
+		"default-beta": {
+			Objects: []runtime.Object{
+				&storagev1.StorageClass{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "local-path",
+						Annotations: map[string]string{
+							preflight.BetaDefaultClassAnnotation: "true",
+						},
+					},
+					Provisioner: "rancher.io/local-path",
+				},
+			},
+			ExpectStorage: &preflight.Storage{
+				StorageClassName: "local-path",
+				AccessMode:       "ReadWriteMany",
+			},
+		},
+		"default-block": {
+			Objects: []runtime.Object{
+				storageClass("gp3", "ebs.csi.aws.com", true),
+			},
+			ExpectStorage: &preflight.Storage{
+				StorageClassName: "gp3",
+				AccessMode:       "ReadWriteOnce",
+				Warnings: []string{
+					"access mode ReadWriteOnce requires the coverage-monitored pods to run on the same node as the Romeo webserver to share StorageClass gp3 volume",
+				},
+			},
+		},
+		"unknown-provisioner": {
+			Objects: []runtime.Object{
+				storageClass("custom", "example.com/custom", true),
+			},
+			ExpectStorage: &preflight.Storage{
+				StorageClassName: "custom",
+				AccessMode:       "ReadWriteOnce",
+				Warnings: []string{
+					"provisioner example.com/custom of StorageClass custom is unknown, defaulting to access mode ReadWriteOnce",
+					"access mode ReadWriteOnce requires the coverage-monitored pods to run on the same node as the Romeo webserver to share StorageClass custom volume",
+				},
+			},
+		},
+		"chosen": {
+			Objects: []runtime.Object{
+				storageClass("gp3", "ebs.csi.aws.com", true),
+				storageClass("efs", "efs.csi.aws.com", false),
+			},
+			Options: preflight.StorageOptions{
+				StorageClassName: "efs",
+				AccessMode:       "ReadWriteMany",
+			},
+			ExpectStorage: &preflight.Storage{
+				StorageClassName: "efs",
+				AccessMode:       "ReadWriteMany",
+			},
+		},
+		"block-rwx": {
+			Objects: []runtime.Object{
+				storageClass("gp3", "ebs.csi.aws.com", true),
+			},
+			Options: preflight.StorageOptions{
+				AccessMode: "ReadWriteMany",
+			},
+			ExpectErr: true,
+		},
+		"rwop": {
+			Objects: []runtime.Object{
+				storageClass("nfs", "nfs.csi.k8s.io", true),
+			},
+			Options: preflight.StorageOptions{
+				AccessMode: "ReadWriteOncePod",
+			},
+			ExpectErr: true,
+		},
+		"rox": {
+			Objects: []runtime.Object{
+				storageClass("nfs", "nfs.csi.k8s.io", true),
+			},
+			Options: preflight.StorageOptions{
+				AccessMode: "ReadOnlyMany",
+			},
+			ExpectErr: true,
+		},
+		"unexisting": {
+			Objects: []runtime.Object{
+				storageClass("nfs", "nfs.csi.k8s.io", true),
+			},
+			Options: preflight.StorageOptions{
+				StorageClassName: "standard",
+			},
+			ExpectErr: true,
+		},
+		"no-default": {
+			Objects: []runtime.Object{
+				storageClass("nfs", "nfs.csi.k8s.io", false),
+			},
+			ExpectErr: true,
+		},
+		"no-storageclass": {
+			ExpectErr: true,
+		},
+		"forbidden": {
+			Forbidden: true,
+			Options: preflight.StorageOptions{
+				StorageClassName: "standard",
+			},
+			ExpectStorage: &preflight.Storage{
+				StorageClassName: "standard",
+				AccessMode:       "ReadWriteMany",
+				Warnings: []string{
+					"StorageClasses could not be listed, thus the storage is not checked: grant list on storageclasses.storage.k8s.io for preflight checks",
+				},
+			},
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			assert := assert.New(t)
+			require := require.New(t)
+
+			client := fake.NewClientset(tt.Objects...)
+			if tt.Forbidden {
+				client.PrependReactor("list", "storageclasses", func(k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "storage.k8s.io", Resource: "storageclasses"}, "", nil)
+				})
+			}
+
+			st, err := preflight.ChooseStorage(context.Background(), client, tt.Options)
+			if tt.ExpectErr {
+				require.Error(err)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tt.ExpectStorage, st)
+		})
+	}
+}
@@ -0,0 +1,474 @@
+// Package programs contains the Pulumi programs of Romeo, as run by the
+// install and environment deployments.
+// They are configured through the stack configuration of their project.
+package programs
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	netwv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/networking/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+
+	"github.com/ctfer-io/romeo/sdk"
+	"github.com/ctfer-io/romeo/sdk/preflight"
+)
+
+// EnvironmentProject is the Pulumi project of the [Environment] program.
+const EnvironmentProject = "env"
+
+// Environment deploys a Romeo environment, as configured by the stack.
+func Environment(ctx *pulumi.Context) error {
+	_, testi := os.LookupEnv("CTFERIO_CHALL_MANAGER_INTEGRATION_TEST")
+
+	cfg := loadEnvironmentConfig(ctx)
+	if err := cfg.preflight(ctx); err != nil {
+		return err
+	}
+
+	// Build Kubernetes provider
+	pv, err := kubernetes.NewProvider(ctx, "provider", &kubernetes.ProviderArgs{
+		Kubeconfig: config.GetSecret(ctx, EnvironmentProject+":kubeconfig"),
+	})
+	if err != nil {
+		return err
+	}
+
+	opts := []pulumi.ResourceOption{
+		pulumi.Provider(pv),
+		// Define timeouts to avoid waiting in CI.
+		// These should be large enough (observed ~1m on GitHub Action runners).
+		pulumi.Timeouts(&pulumi.CustomTimeouts{
+			Create: "2m",
+			Update: "2m", // should not occur
+			Delete: "2m", // should not occur
+		}),
+	}
+
+	// Deploy a Romeo instance
+	args := cfg.Args()
+	romeo, err := sdk.NewRomeoEnvironment(ctx, "deploy", args, opts...)
+	if err != nil {
+		return err
+	}
+
+	// Instrument the labelled pods automatically, if required
+	if cfg.Webhook {
+		if _, err := sdk.NewRomeoWebhook(ctx, "webhook", &sdk.RomeoWebhookArgs{
+			Namespace:        romeo.Namespace,
+			ClaimName:        romeo.ClaimName,
+			Tag:              pulumi.String(cfg.Tag),
+			Registry:         pulumi.String(cfg.Registry),
+			Harden:           cfg.Harden,
+			Restricted:       args.Restricted || args.PodSecurityLevel == sdk.PodSecurityRestricted,
+			Digest:           args.Digest,
+			ImagePullSecrets: romeo.ImagePullSecrets,
+		}, opts...); err != nil {
+			return errors.Wrap(err, "deploying webhook")
+		}
+	}
+
+	if testi {
+		if _, err := netwv1.NewNetworkPolicy(ctx, "allow-inside-oci", &netwv1.NetworkPolicyArgs{
+			Metadata: metav1.ObjectMetaArgs{
+				Namespace: pulumi.String(cfg.Namespace),
+				Labels: pulumi.StringMap{
+					"app.kubernetes.io/component": pulumi.String("chall-manager"),
+					"app.kubernetes.io/part-of":   pulumi.String("chall-manager"),
+					"ctfer.io/stack-name":         pulumi.String(ctx.Stack()),
+				},
+			},
+			Spec: netwv1.NetworkPolicySpecArgs{
+				PolicyTypes: pulumi.ToStringArray([]string{
+					"Egress",
+				}),
+				PodSelector: metav1.LabelSelectorArgs{
+					MatchLabels: romeo.PodLabels,
+				},
+				Egress: netwv1.NetworkPolicyEgressRuleArray{
+					netwv1.NetworkPolicyEgressRuleArgs{
+						Ports: netwv1.NetworkPolicyPortArray{
+							netwv1.NetworkPolicyPortArgs{
+								Port: pulumi.Int(5000), // we serve the OCI on this port
+							},
+						},
+						To: netwv1.NetworkPolicyPeerArray{
+							netwv1.NetworkPolicyPeerArgs{
+								IpBlock: netwv1.IPBlockArgs{
+									Cidr: pulumi.String("172.16.0.0/12"), // The CIDR the OCI registry lays into as a mirror
+								},
+							},
+						},
+					},
+				},
+			},
+		}, opts...); err != nil {
+			return errors.Wrap(err, "allowing inside OCI traffic")
+		}
+	}
+
+	// Export Romeo outputs
+	ctx.Export("namespace", romeo.Namespace)
+	ctx.Export("port", romeo.Port)
+	ctx.Export("url", romeo.URL)
+	ctx.Export("claim-name", romeo.ClaimName)
+	ctx.Export("affinity", romeo.Affinity)
+
+	return nil
+}
+
+// EnvironmentConfig is the configuration of the [Environment] program, as
+// described by its Pulumi.yaml (but the kubeconfig).
+type EnvironmentConfig struct {
+	Namespace        string
+	Harden           bool
+	PodSecurityLevel string
+	Restricted       bool
+	Tag              string
+	StorageClassName string
+	StorageSize      string
+	ClaimName        string
+	PVCAccessMode    string
+	CoLocate         bool
+	Registry         string
+	Digest           string
+	Expose           string
+	NodeAddress      string
+	Webhook          bool
+	Instance         string
+	TTL              string
+
+	ImagePullSecrets string
+	RegistryUsername string
+	RegistryPassword string
+
+	AllowCIDRs                 string
+	AllowNamespaceSelector     string
+	AllowPodSelector           string
+	AllowAppsCIDRs             string
+	AllowAppsNamespaceSelector string
+	AllowAppsPodSelector       string
+
+	CPURequest    string
+	MemoryRequest string
+	CPULimit      string
+	MemoryLimit   string
+
+	IngressHost          string
+	IngressTLSSecretName string
+	IngressClassName     string
+
+	GatewayName        string
+	GatewayNamespace   string
+	GatewaySectionName string
+	GatewayHostname    string
+	GatewayPathPrefix  string
+	GatewayAddress     string
+
+	PreserveSink       string
+	PreserveClaimName  string
+	PreserveS3Endpoint string
+	PreserveS3Region   string
+	PreserveS3Insecure bool
+	PreserveSecretName string
+
+	PreserveAllowCIDRs             string
+	PreserveAllowNamespaceSelector string
+	PreserveAllowPodSelector       string
+}
+
+func loadEnvironmentConfig(ctx *pulumi.Context) *EnvironmentConfig {
+	cfg := config.New(ctx, EnvironmentProject)
+	return &EnvironmentConfig{
+		Namespace:        cfg.Get("namespace"),
+		Harden:           cfg.GetBool("harden"),
+		PodSecurityLevel: cfg.Get("pod-security-level"),
+		Restricted:       cfg.GetBool("restricted"),
+		Tag:              cfg.Get("tag"),
+		StorageClassName: cfg.Get("storage-class-name"),
+		StorageSize:      cfg.Get("storage-size"),
+		ClaimName:        cfg.Get("claim-name"),
+		PVCAccessMode:    cfg.Get("pvc-access-mode"),
+		CoLocate:         cfg.GetBool("co-locate"),
+		Registry:         cfg.Get("registry"),
+		Digest:           cfg.Get("digest"),
+		Expose:           cfg.Get("expose"),
+		NodeAddress:      cfg.Get("node-address"),
+		Webhook:          cfg.GetBool("webhook"),
+		Instance:         cfg.Get("instance"),
+		TTL:              cfg.Get("ttl"),
+
+		ImagePullSecrets: cfg.Get("image-pull-secrets"),
+		RegistryUsername: cfg.Get("registry-username"),
+		RegistryPassword: cfg.Get("registry-password"),
+
+		AllowCIDRs:                 cfg.Get("allow-cidrs"),
+		AllowNamespaceSelector:     cfg.Get("allow-namespace-selector"),
+		AllowPodSelector:           cfg.Get("allow-pod-selector"),
+		AllowAppsCIDRs:             cfg.Get("allow-apps-cidrs"),
+		AllowAppsNamespaceSelector: cfg.Get("allow-apps-namespace-selector"),
+		AllowAppsPodSelector:       cfg.Get("allow-apps-pod-selector"),
+
+		CPURequest:    cfg.Get("cpu-request"),
+		MemoryRequest: cfg.Get("memory-request"),
+		CPULimit:      cfg.Get("cpu-limit"),
+		MemoryLimit:   cfg.Get("memory-limit"),
+
+		IngressHost:          cfg.Get("ingress-host"),
+		IngressTLSSecretName: cfg.Get("ingress-tls-secret-name"),
+		IngressClassName:     cfg.Get("ingress-class-name"),
+
+		GatewayName:        cfg.Get("gateway-name"),
+		GatewayNamespace:   cfg.Get("gateway-namespace"),
+		GatewaySectionName: cfg.Get("gateway-section-name"),
+		GatewayHostname:    cfg.Get("gateway-hostname"),
+		GatewayPathPrefix:  cfg.Get("gateway-path-prefix"),
+		GatewayAddress:     cfg.Get("gateway-address"),
+
+		PreserveSink:       cfg.Get("preserve-sink"),
+		PreserveClaimName:  cfg.Get("preserve-claim-name"),
+		PreserveS3Endpoint: cfg.Get("preserve-s3-endpoint"),
+		PreserveS3Region:   cfg.Get("preserve-s3-region"),
+		PreserveS3Insecure: cfg.GetBool("preserve-s3-insecure"),
+		PreserveSecretName: cfg.Get("preserve-secret-name"),
+
+		PreserveAllowCIDRs:             cfg.Get("preserve-allow-cidrs"),
+		PreserveAllowNamespaceSelector: cfg.Get("preserve-allow-namespace-selector"),
+		PreserveAllowPodSelector:       cfg.Get("preserve-allow-pod-selector"),
+	}
+}
+
+// preflight chooses the storage of the environment, as the StorageClasses
+// of the cluster permit, such that it fails early rather than waiting for a
+// PersistentVolumeClaim that could not be bound.
+func (cfg *EnvironmentConfig) preflight(ctx *pulumi.Context) error {
+	client, err := preflight.NewClient(config.Get(ctx, EnvironmentProject+":kubeconfig"))
+	if err != nil {
+		_ = ctx.Log.Warn(fmt.Sprintf("skipping storage preflight: %s", err), nil)
+		return nil
+	}
+
+	st, err := preflight.ChooseStorage(ctx.Context(), client, preflight.StorageOptions{
+		StorageClassName: cfg.StorageClassName,
+		AccessMode:       cfg.PVCAccessMode,
+	})
+	if err != nil {
+		return errors.Wrap(err, "storage preflight")
+	}
+	for _, warn := range st.Warnings {
+		_ = ctx.Log.Warn(warn, nil)
+	}
+	cfg.StorageClassName = st.StorageClassName
+	cfg.PVCAccessMode = st.AccessMode
+	return nil
+}
+
+// Args returns the arguments of the Romeo environment the configuration
+// defines.
+func (cfg *EnvironmentConfig) Args() *sdk.RomeoEnvironmentArgs {
+	return &sdk.RomeoEnvironmentArgs{
+		Namespace:        pulumi.String(cfg.Namespace),
+		Harden:           cfg.Harden,
+		Allow:            allow(cfg.AllowCIDRs, cfg.AllowNamespaceSelector, cfg.AllowPodSelector),
+		AllowApps:        allow(cfg.AllowAppsCIDRs, cfg.AllowAppsNamespaceSelector, cfg.AllowAppsPodSelector),
+		PodSecurityLevel: cfg.PodSecurityLevel,
+		Restricted:       cfg.Restricted,
+		Resources:        cfg.resources(),
+		Tag:              pulumi.String(cfg.Tag),
+		StorageClassName: pulumi.String(cfg.StorageClassName),
+		StorageSize:      pulumi.String(cfg.StorageSize),
+		ClaimName: func() (s pulumi.StringInput) {
+			if cfg.ClaimName != "" {
+				s = pulumi.String(cfg.ClaimName)
+			}
+			return
+		}(),
+		PVCAccessModes: func() (s pulumi.StringArrayInput) {
+			if cfg.PVCAccessMode != "" {
+				s = pulumi.ToStringArray([]string{cfg.PVCAccessMode})
+			}
+			return
+		}(),
+		CoLocate: cfg.CoLocate,
+		Registry: pulumi.String(cfg.Registry),
+		Digest: func() (s pulumi.StringInput) {
+			if cfg.Digest != "" {
+				s = pulumi.String(cfg.Digest)
+			}
+			return
+		}(),
+		ImagePullSecrets: func() (s pulumi.StringArrayInput) {
+			if names := split(cfg.ImagePullSecrets); len(names) != 0 {
+				s = pulumi.ToStringArray(names)
+			}
+			return
+		}(),
+		RegistryCredentials: func() (rc *sdk.RomeoRegistryCredentialsArgs) {
+			if cfg.RegistryUsername != "" {
+				rc = &sdk.RomeoRegistryCredentialsArgs{
+					Username: pulumi.String(cfg.RegistryUsername),
+					Password: pulumi.ToSecret(pulumi.String(cfg.RegistryPassword)).(pulumi.StringOutput),
+				}
+			}
+			return
+		}(),
+		Expose:      expose(cfg.Expose),
+		NodeAddress: pulumi.String(cfg.NodeAddress),
+		Ingress: func() (ing *sdk.RomeoIngressArgs) {
+			if cfg.IngressHost != "" {
+				ing = &sdk.RomeoIngressArgs{
+					Host: pulumi.String(cfg.IngressHost),
+				}
+				if cfg.IngressTLSSecretName != "" {
+					ing.TLSSecretName = pulumi.String(cfg.IngressTLSSecretName)
+				}
+				if cfg.IngressClassName != "" {
+					ing.ClassName = pulumi.String(cfg.IngressClassName)
+				}
+			}
+			return
+		}(),
+		Gateway: func() (gw *sdk.RomeoGatewayArgs) {
+			if cfg.GatewayName != "" {
+				gw = &sdk.RomeoGatewayArgs{
+					Name: pulumi.String(cfg.GatewayName),
+				}
+				if cfg.GatewayNamespace != "" {
+					gw.Namespace = pulumi.String(cfg.GatewayNamespace)
+				}
+				if cfg.GatewaySectionName != "" {
+					gw.SectionName = pulumi.String(cfg.GatewaySectionName)
+				}
+				if cfg.GatewayHostname != "" {
+					gw.Hostname = pulumi.String(cfg.GatewayHostname)
+				}
+				if cfg.GatewayPathPrefix != "" {
+					gw.PathPrefix = pulumi.String(cfg.GatewayPathPrefix)
+				}
+				if cfg.GatewayAddress != "" {
+					gw.Address = pulumi.String(cfg.GatewayAddress)
+				}
+			}
+			return
+		}(),
+		Instance: func() (s pulumi.StringInput) {
+			if cfg.Instance != "" {
+				s = pulumi.String(cfg.Instance)
+			}
+			return
+		}(),
+		TTL: pulumi.String(cfg.TTL),
+		Preserve: func() (pr *sdk.RomeoPreserveArgs) {
+			if cfg.PreserveSink != "" || cfg.PreserveClaimName != "" {
+				pr = &sdk.RomeoPreserveArgs{
+					S3Insecure: cfg.PreserveS3Insecure,
+					Allow:      allow(cfg.PreserveAllowCIDRs, cfg.PreserveAllowNamespaceSelector, cfg.PreserveAllowPodSelector),
+				}
+				if cfg.PreserveSink != "" {
+					pr.Sink = pulumi.String(cfg.PreserveSink)
+				}
+				if cfg.PreserveClaimName != "" {
+					pr.ClaimName = pulumi.String(cfg.PreserveClaimName)
+				}
+				if cfg.PreserveS3Endpoint != "" {
+					pr.S3Endpoint = pulumi.String(cfg.PreserveS3Endpoint)
+				}
+				if cfg.PreserveS3Region != "" {
+					pr.S3Region = pulumi.String(cfg.PreserveS3Region)
+				}
+				if cfg.PreserveSecretName != "" {
+					pr.SecretName = pulumi.String(cfg.PreserveSecretName)
+				}
+			}
+			return
+		}(),
+	}
+}
+
+// resources returns the resource requirements of the webserver container,
+// if any is configured.
+func (cfg *EnvironmentConfig) resources() corev1.ResourceRequirementsPtrInput {
+	requests := quantities(cfg.CPURequest, cfg.MemoryRequest)
+	limits := quantities(cfg.CPULimit, cfg.MemoryLimit)
+	if requests == nil && limits == nil {
+		return nil
+	}
+	return corev1.ResourceRequirementsArgs{
+		Requests: requests,
+		Limits:   limits,
+	}
+}
+
+func quantities(cpu, memory string) pulumi.StringMapInput {
+	q := pulumi.StringMap{}
+	if cpu != "" {
+		q["cpu"] = pulumi.String(cpu)
+	}
+	if memory != "" {
+		q["memory"] = pulumi.String(memory)
+	}
+	if len(q) == 0 {
+		return nil
+	}
+	return q
+}
+
+// allow returns the peers allowed to reach the webserver API, or for it to
+// reach, if any.
+// The CIDRs are comma-separated, and the selectors are comma-separated
+// key=value labels.
+func allow(cidrs, namespaceSelector, podSelector string) *sdk.RomeoAllowArgs {
+	if cidrs == "" && namespaceSelector == "" && podSelector == "" {
+		return nil
+	}
+	a := &sdk.RomeoAllowArgs{
+		CIDRs: split(cidrs),
+	}
+	if namespaceSelector != "" {
+		a.NamespaceSelector = pulumi.ToStringMap(labels(namespaceSelector))
+	}
+	if podSelector != "" {
+		a.PodSelector = pulumi.ToStringMap(labels(podSelector))
+	}
+	return a
+}
+
+// labels returns the comma-separated key=value labels.
+func labels(in string) map[string]string {
+	out := map[string]string{}
+	for _, kv := range split(in) {
+		k, v, _ := strings.Cut(kv, "=")
+		out[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return out
+}
+
+// split returns the comma-separated values, ignoring the empty ones.
+func split(in string) []string {
+	out := []string{}
+	for _, v := range strings.Split(in, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// expose maps the configuration to the way to expose Romeo.
+// Booleans are supported for backward compatibility: "true" exposes
+// through a NodePort, "false" keeps it cluster-internal.
+func expose(in string) string {
+	switch strings.ToLower(in) {
+	case "", "true":
+		return sdk.ExposeNodePort
+	case "false":
+		return sdk.ExposeClusterIP
+	}
+	return in
+}
@@ -0,0 +1,102 @@
+package programs
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/ctfer-io/romeo/sdk"
+	"github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+	"gopkg.in/yaml.v3"
+)
+
+// InstallProject is the Pulumi project of the [Install] program.
+const InstallProject = "install"
+
+// Install deploys the resources required by Romeo environments, as configured
+// by the stack.
+func Install(ctx *pulumi.Context) error {
+	cfg := config.New(ctx, InstallProject)
+
+	// Build Kubernetes provider
+	kubeconfig := cfg.GetSecret("kubeconfig")
+	pv, err := kubernetes.NewProvider(ctx, "provider", &kubernetes.ProviderArgs{
+		Kubeconfig: kubeconfig,
+	})
+	if err != nil {
+		return err
+	}
+
+	opts := []pulumi.ResourceOption{
+		pulumi.Provider(pv),
+		// Define timeouts to avoid waiting in CI.
+		// These should be large enough (observed ~1m on GitHub Action runners).
+		pulumi.Timeouts(&pulumi.CustomTimeouts{
+			Create: "2m",
+			Update: "2m", // should not occur
+			Delete: "2m", // should not occur
+		}),
+	}
+
+	// Get api-server
+	apiServer := kubeconfig.ApplyT(func(kubeconfig string) (apiServer string, err error) {
+		apiServer = cfg.Get("api-server")
+		if apiServer == "" {
+			apiServer, err = extractAPIServer(kubeconfig)
+		}
+		return
+	}).(pulumi.StringOutput)
+
+	// Garbage collect the orphaned environments, if required
+	var gc *sdk.RomeoGCArgs
+	if schedule := cfg.Get("gc-schedule"); schedule != "" {
+		gc = &sdk.RomeoGCArgs{
+			Schedule: pulumi.String(schedule),
+			Tag:      pulumi.String(cfg.Get("tag")),
+			Registry: pulumi.String(cfg.Get("registry")),
+		}
+		if digest := cfg.Get("digest"); digest != "" {
+			gc.Digest = pulumi.String(digest)
+		}
+		if secrets := cfg.Get("image-pull-secrets"); secrets != "" {
+			gc.ImagePullSecrets = pulumi.ToStringArray(split(secrets))
+		}
+	}
+
+	// Install Romeo
+	rist, err := sdk.NewRomeoInstall(ctx, "install", &sdk.RomeoInstallArgs{
+		Namespace: pulumi.String(cfg.Get("namespace")),
+		APIServer: apiServer,
+		Harden:    cfg.GetBool("harden"),
+		Webhook:   cfg.GetBool("webhook"),
+		GC:        gc,
+	}, opts...)
+	if err != nil {
+		return err
+	}
+
+	// Export romeo outputs
+	ctx.Export("kubeconfig", rist.Kubeconfig)
+	ctx.Export("namespace", rist.Namespace)
+
+	return nil
+}
+
+type partialKubeconfig struct {
+	Clusters []struct {
+		Cluster struct {
+			Server string `yaml:"server"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+}
+
+func extractAPIServer(kubeconfig string) (string, error) {
+	kc := partialKubeconfig{}
+	if err := yaml.Unmarshal([]byte(kubeconfig), &kc); err != nil {
+		return "", err
+	}
+	if len(kc.Clusters) != 1 {
+		return "", errors.New("could not infer api-server from kubeconfig")
+	}
+	return kc.Clusters[0].Cluster.Server, nil
+}
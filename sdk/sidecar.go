@@ -0,0 +1,373 @@
+package sdk
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ctfer-io/romeo/sdk/instrument"
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apps/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+type (
+	// RomeoSidecar runs the Romeo webserver inside the pods of the
+	// application under test, next to its containers: they share an
+	// emptyDir as GOCOVERDIR, so no PersistentVolumeClaim is required.
+	// It creates no Kubernetes resource, but the container and volume to
+	// add to the pods, as [Sidecar] does.
+	//
+	// As the coverages live as long as the pod, it only fits the
+	// long-running workloads (Deployments, StatefulSets and DaemonSets),
+	// e.g. for single-service tests.
+	RomeoSidecar struct {
+		pulumi.ResourceState
+
+		// Container of the Romeo webserver, to add to the pod.
+		Container corev1.ContainerOutput `pulumi:"container"`
+
+		// Volume the coverages are written into, to add to the pod.
+		Volume corev1.VolumeOutput `pulumi:"volume"`
+
+		// Port the Romeo webserver serves on, in the pod.
+		Port pulumi.IntOutput `pulumi:"port"`
+
+		// imagePullSecrets to add to the pod, to pull the Romeo Docker
+		// image with.
+		imagePullSecrets pulumi.StringArrayOutput
+
+		// MountPath the volume is mounted at, in all the containers of the
+		// pod. It is the GOCOVERDIR of the application containers.
+		MountPath pulumi.StringOutput `pulumi:"mountPath"`
+	}
+
+	// RomeoSidecarArgs contains all the arguments to build a Romeo sidecar.
+	RomeoSidecarArgs struct {
+		Tag pulumi.StringInput
+		tag pulumi.StringOutput
+
+		// Registry define from where to fetch the Romeo Docker images.
+		// If set empty, defaults to Docker Hub.
+		Registry pulumi.StringInput
+		registry pulumi.StringOutput
+
+		// Digest pins the Romeo Docker image (e.g. "sha256:...").
+		Digest pulumi.StringInput
+
+		// ImagePullSecrets are the names of the Secrets of the application
+		// namespace to pull the Romeo Docker image with, added to its pods.
+		ImagePullSecrets pulumi.StringArrayInput
+
+		// Port to serve the Romeo webserver on, in the pod. It must differ
+		// from the ones of the application. Defaults to 8090.
+		Port pulumi.IntInput
+		port pulumi.IntOutput
+
+		// MountPath to mount the volume at. Defaults to "/etc/coverout".
+		MountPath pulumi.StringInput
+		mountPath pulumi.StringOutput
+
+		// SizeLimit of the emptyDir, if any (e.g. "50M").
+		SizeLimit pulumi.StringInput
+	}
+)
+
+const (
+	// SidecarToken is the type token of [*RomeoSidecar].
+	SidecarToken = "ctfer-io:romeo:sidecar"
+
+	defaultSidecarPort = 8090
+	sidecarContainer   = "romeo"
+	sidecarVolume      = "romeo-coverdir"
+)
+
+// NewRomeoSidecar builds the Romeo webserver container and volume to add
+// to the pods of an application.
+func NewRomeoSidecar(
+	ctx *pulumi.Context,
+	name string,
+	args *RomeoSidecarArgs,
+	opts ...pulumi.ResourceOption,
+) (*RomeoSidecar, error) {
+	if args != nil && args.Digest != nil && !validDigest(args.Digest) {
+		return nil, fmt.Errorf("invalid image digest %q, expected sha256:<hex>", args.Digest)
+	}
+
+	rsc := &RomeoSidecar{}
+
+	args = rsc.defaults(args)
+	if err := ctx.RegisterComponentResource(SidecarToken, name, rsc, opts...); err != nil {
+		return nil, err
+	}
+	if err := rsc.outputs(ctx, args); err != nil {
+		return nil, err
+	}
+
+	return rsc, nil
+}
+
+func (rsc *RomeoSidecar) defaults(args *RomeoSidecarArgs) *RomeoSidecarArgs {
+	if args == nil {
+		args = &RomeoSidecarArgs{}
+	}
+
+	// Default tag to dev
+	args.tag = pulumi.String(defaultTag).ToStringOutput()
+	if args.Tag != nil {
+		args.tag = args.Tag.ToStringOutput().ApplyT(func(tag string) string {
+			if tag == "" {
+				return defaultTag
+			}
+			return tag
+		}).(pulumi.StringOutput)
+	}
+
+	// Define private registry if any
+	args.registry = pulumi.String("").ToStringOutput()
+	if args.Registry != nil {
+		args.registry = args.Registry.ToStringOutput().ApplyT(func(in string) string {
+			if in != "" && !strings.HasSuffix(in, "/") {
+				in += "/"
+			}
+			return in
+		}).(pulumi.StringOutput)
+	}
+
+	// Default port to 8090
+	args.port = pulumi.Int(defaultSidecarPort).ToIntOutput()
+	if args.Port != nil {
+		args.port = args.Port.ToIntOutput().ApplyT(func(port int) int {
+			if port == 0 {
+				return defaultSidecarPort
+			}
+			return port
+		}).(pulumi.IntOutput)
+	}
+
+	// Default mount path to /etc/coverout
+	args.mountPath = pulumi.String(instrument.DefaultMountPath).ToStringOutput()
+	if args.MountPath != nil {
+		args.mountPath = args.MountPath.ToStringOutput().ApplyT(func(path string) string {
+			if path == "" {
+				return instrument.DefaultMountPath
+			}
+			return path
+		}).(pulumi.StringOutput)
+	}
+
+	return args
+}
+
+func (rsc *RomeoSidecar) outputs(ctx *pulumi.Context, args *RomeoSidecarArgs) error {
+	rsc.Port = args.port
+	rsc.MountPath = args.mountPath
+	rsc.Container = pulumi.All(romeoImage(args.registry, args.tag, args.Digest), args.port, args.mountPath).ApplyT(func(all []any) corev1.Container {
+		image, port, mountPath := all[0].(string), all[1].(int), all[2].(string)
+		return sidecar(image, port, mountPath)
+	}).(corev1.ContainerOutput)
+
+	rsc.imagePullSecrets = pulumi.StringArray{}.ToStringArrayOutput()
+	if args.ImagePullSecrets != nil {
+		rsc.imagePullSecrets = args.ImagePullSecrets.ToStringArrayOutput()
+	}
+
+	volume := corev1.VolumeArgs{
+		Name:     pulumi.String(sidecarVolume),
+		EmptyDir: corev1.EmptyDirVolumeSourceArgs{},
+	}
+	if args.SizeLimit != nil {
+		volume.EmptyDir = corev1.EmptyDirVolumeSourceArgs{
+			SizeLimit: args.SizeLimit,
+		}
+	}
+	rsc.Volume = volume.ToVolumeOutput()
+
+	return ctx.RegisterResourceOutputs(rsc, pulumi.Map{
+		"container": rsc.Container,
+		"volume":    rsc.Volume,
+		"port":      rsc.Port,
+		"mountPath": rsc.MountPath,
+	})
+}
+
+// sidecar returns the Romeo webserver container, serving the coverages
+// of the mount path.
+func sidecar(image string, port int, mountPath string) corev1.Container {
+	return corev1.Container{
+		Name:  sidecarContainer,
+		Image: &image,
+		Ports: []corev1.ContainerPort{
+			{
+				ContainerPort: port,
+				Name:          pulumi.StringRef("romeo-api"),
+			},
+		},
+		Env: []corev1.EnvVar{
+			{
+				Name:  "COVERDIR",
+				Value: &mountPath,
+			},
+			{
+				Name:  "PORT",
+				Value: pulumi.StringRef(strconv.Itoa(port)),
+			},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      sidecarVolume,
+				MountPath: mountPath,
+			},
+		},
+		LivenessProbe: &corev1.Probe{
+			HttpGet: &corev1.HTTPGetAction{
+				Path: pulumi.StringRef("/healthz"),
+				Port: "romeo-api",
+			},
+		},
+		ReadinessProbe: &corev1.Probe{
+			HttpGet: &corev1.HTTPGetAction{
+				Path: pulumi.StringRef("/readyz"),
+				Port: "romeo-api",
+			},
+		},
+	}
+}
+
+// Sidecar returns a resource option that adds the Romeo sidecar to the
+// pods of the Deployments, StatefulSets and DaemonSets it applies to, and
+// those of their children (e.g. when passed to a component).
+// Their containers get the volume mounted, with GOCOVERDIR pointing to it.
+// Only the InstrumentArgs MatchLabels apply, the sidecar defines the mount
+// path.
+//
+//	app, err := NewApp(ctx, "app", appArgs, sdk.Sidecar(romeo, nil))
+func Sidecar(rsc *RomeoSidecar, args *InstrumentArgs) pulumi.ResourceOption {
+	return pulumi.Transformations([]pulumi.ResourceTransformation{
+		rsc.Transformation(args),
+	})
+}
+
+// Transformation returns the resource transformation behind [Sidecar].
+func (rsc *RomeoSidecar) Transformation(args *InstrumentArgs) pulumi.ResourceTransformation {
+	if args == nil {
+		args = &InstrumentArgs{}
+	}
+
+	return func(rta *pulumi.ResourceTransformationArgs) *pulumi.ResourceTransformationResult {
+		switch props := rta.Props.(type) {
+		case *appsv1.DeploymentArgs:
+			if props.Spec == nil {
+				return nil
+			}
+			props.Spec = rsc.add(props.Metadata, props.Spec.ToDeploymentSpecPtrOutput(), args.MatchLabels, func(v any) *corev1.PodTemplateSpec {
+				if spec := v.(*appsv1.DeploymentSpec); spec != nil {
+					return &spec.Template
+				}
+				return nil
+			}).ApplyT(func(v any) *appsv1.DeploymentSpec {
+				return v.(*appsv1.DeploymentSpec)
+			}).(appsv1.DeploymentSpecPtrOutput)
+
+		case *appsv1.StatefulSetArgs:
+			if props.Spec == nil {
+				return nil
+			}
+			props.Spec = rsc.add(props.Metadata, props.Spec.ToStatefulSetSpecPtrOutput(), args.MatchLabels, func(v any) *corev1.PodTemplateSpec {
+				if spec := v.(*appsv1.StatefulSetSpec); spec != nil {
+					return &spec.Template
+				}
+				return nil
+			}).ApplyT(func(v any) *appsv1.StatefulSetSpec {
+				return v.(*appsv1.StatefulSetSpec)
+			}).(appsv1.StatefulSetSpecPtrOutput)
+
+		case *appsv1.DaemonSetArgs:
+			if props.Spec == nil {
+				return nil
+			}
+			props.Spec = rsc.add(props.Metadata, props.Spec.ToDaemonSetSpecPtrOutput(), args.MatchLabels, func(v any) *corev1.PodTemplateSpec {
+				if spec := v.(*appsv1.DaemonSetSpec); spec != nil {
+					return &spec.Template
+				}
+				return nil
+			}).ApplyT(func(v any) *appsv1.DaemonSetSpec {
+				return v.(*appsv1.DaemonSetSpec)
+			}).(appsv1.DaemonSetSpecPtrOutput)
+
+		default:
+			return nil
+		}
+
+		return &pulumi.ResourceTransformationResult{
+			Props: rta.Props,
+			Opts:  rta.Opts,
+		}
+	}
+}
+
+// add adds the sidecar to the pod template tpl returns out of the
+// workload spec, if the workload matches the labels.
+func (rsc *RomeoSidecar) add(
+	meta metav1.ObjectMetaPtrInput,
+	spec pulumi.Output,
+	matchLabels map[string]string,
+	tpl func(spec any) *corev1.PodTemplateSpec,
+) pulumi.AnyOutput {
+	return pulumi.All(metaOutput(meta), spec, rsc.Container, rsc.Volume, rsc.MountPath, rsc.imagePullSecrets).ApplyT(func(all []any) any {
+		if !matches(all[0].(*metav1.ObjectMeta), matchLabels) {
+			return all[1]
+		}
+		if t := tpl(all[1]); t != nil {
+			addSidecar(t, all[2].(corev1.Container), all[3].(corev1.Volume), all[4].(string), all[5].([]string))
+		}
+		return all[1]
+	}).(pulumi.AnyOutput)
+}
+
+// addSidecar adds the Romeo container and volume to the pod template,
+// and mounts the volume in its containers with GOCOVERDIR pointing to it.
+// The image pull secrets are added to the pod ones, if not already.
+func addSidecar(tpl *corev1.PodTemplateSpec, container corev1.Container, volume corev1.Volume, mountPath string, pullSecrets []string) {
+	if tpl.Spec == nil {
+		return
+	}
+	for _, c := range tpl.Spec.Containers {
+		if c.Name == sidecarContainer {
+			return
+		}
+	}
+
+	for i := range tpl.Spec.Containers {
+		c := &tpl.Spec.Containers[i]
+
+		env := []corev1.EnvVar{}
+		for _, e := range c.Env {
+			if e.Name != "GOCOVERDIR" {
+				env = append(env, e)
+			}
+		}
+		c.Env = append(env, corev1.EnvVar{
+			Name:  "GOCOVERDIR",
+			Value: &mountPath,
+		})
+		c.VolumeMounts = append(c.VolumeMounts, corev1.VolumeMount{
+			Name:      sidecarVolume,
+			MountPath: mountPath,
+		})
+	}
+	tpl.Spec.Containers = append(tpl.Spec.Containers, container)
+	tpl.Spec.Volumes = append(tpl.Spec.Volumes, volume)
+
+	for _, name := range pullSecrets {
+		if !slices.ContainsFunc(tpl.Spec.ImagePullSecrets, func(ref corev1.LocalObjectReference) bool {
+			return ref.Name != nil && *ref.Name == name
+		}) {
+			tpl.Spec.ImagePullSecrets = append(tpl.Spec.ImagePullSecrets, corev1.LocalObjectReference{
+				Name: pulumi.StringRef(name),
+			})
+		}
+	}
+}
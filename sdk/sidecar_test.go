@@ -0,0 +1,157 @@
+package sdk_test
+
+import (
+	"testing"
+
+	"github.com/ctfer-io/romeo/sdk"
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apps/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_U_Sidecar(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Labels            map[string]string
+		SidecarArgs       *sdk.RomeoSidecarArgs
+		Args              *sdk.InstrumentArgs
+		ExpectSidecar     bool
+		ExpectPort        float64
+		ExpectPath        string
+		ExpectImage       string
+		ExpectPullSecrets []string
+	}{
+		"all": {
+			SidecarArgs:   nil,
+			Args:          nil,
+			ExpectSidecar: true,
+			ExpectPort:    8090,
+			ExpectPath:    "/etc/coverout",
+			ExpectImage:   "ctferio/romeo:dev",
+		},
+		"private-registry": {
+			SidecarArgs: &sdk.RomeoSidecarArgs{
+				Registry:         pulumi.String("registry.example.com/mirror"),
+				Tag:              pulumi.String("v1.0.0"),
+				Digest:           pulumi.String("sha256:abababababababababababababababababababababababababababababababab"),
+				ImagePullSecrets: pulumi.ToStringArray([]string{"pull"}),
+			},
+			ExpectSidecar:     true,
+			ExpectPort:        8090,
+			ExpectPath:        "/etc/coverout",
+			ExpectImage:       "registry.example.com/mirror/ctferio/romeo:v1.0.0@sha256:abababababababababababababababababababababababababababababababab",
+			ExpectPullSecrets: []string{"pull"},
+		},
+		"matching": {
+			Labels: map[string]string{
+				"app": "app",
+			},
+			SidecarArgs: &sdk.RomeoSidecarArgs{
+				Port:      pulumi.Int(9000),
+				MountPath: pulumi.String("/coverout"),
+				SizeLimit: pulumi.String("50M"),
+			},
+			Args: &sdk.InstrumentArgs{
+				MatchLabels: map[string]string{
+					"app": "app",
+				},
+			},
+			ExpectSidecar: true,
+			ExpectPort:    9000,
+			ExpectPath:    "/coverout",
+			ExpectImage:   "ctferio/romeo:dev",
+		},
+		"not-matching": {
+			Labels: map[string]string{
+				"app": "other",
+			},
+			Args: &sdk.InstrumentArgs{
+				MatchLabels: map[string]string{
+					"app": "app",
+				},
+			},
+			ExpectSidecar: false,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			assert := assert.New(t)
+			require := require.New(t)
+
+			mocks := &deploymentMocks{
+				inputs: map[string]resource.PropertyMap{},
+			}
+			err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+				romeo, err := sdk.NewRomeoSidecar(ctx, "romeo-test", tt.SidecarArgs)
+				require.NoError(err)
+
+				_, err = appsv1.NewDeployment(ctx, "app", &appsv1.DeploymentArgs{
+					Metadata: metav1.ObjectMetaArgs{
+						Labels: pulumi.ToStringMap(tt.Labels),
+					},
+					Spec: appsv1.DeploymentSpecArgs{
+						Template: corev1.PodTemplateSpecArgs{
+							Spec: corev1.PodSpecArgs{
+								Containers: corev1.ContainerArray{
+									corev1.ContainerArgs{
+										Name:  pulumi.String("app"),
+										Image: pulumi.String("app"),
+									},
+								},
+							},
+						},
+					},
+				}, sdk.Sidecar(romeo, tt.Args))
+				require.NoError(err)
+
+				return nil
+			}, pulumi.WithMocks("project", "stack", mocks))
+			require.NoError(err)
+
+			inputs, ok := mocks.inputs["app"]
+			require.True(ok)
+			spec := inputs["spec"].ObjectValue()["template"].ObjectValue()["spec"].ObjectValue()
+			containers := spec["containers"].ArrayValue()
+			if !tt.ExpectSidecar {
+				assert.Len(containers, 1)
+				assert.False(spec.HasValue("volumes"))
+				return
+			}
+
+			require.Len(containers, 2)
+			app := containers[0].ObjectValue()
+			env := app["env"].ArrayValue()
+			require.Len(env, 1)
+			assert.Equal("GOCOVERDIR", env[0].ObjectValue()["name"].StringValue())
+			assert.Equal(tt.ExpectPath, env[0].ObjectValue()["value"].StringValue())
+
+			romeo := containers[1].ObjectValue()
+			assert.Equal("romeo", romeo["name"].StringValue())
+			assert.Equal(tt.ExpectPort, romeo["ports"].ArrayValue()[0].ObjectValue()["containerPort"].NumberValue())
+			assert.Equal(tt.ExpectImage, romeo["image"].StringValue())
+
+			pullSecrets := []string{}
+			if spec.HasValue("imagePullSecrets") {
+				for _, ref := range spec["imagePullSecrets"].ArrayValue() {
+					pullSecrets = append(pullSecrets, ref.ObjectValue()["name"].StringValue())
+				}
+			}
+			if tt.ExpectPullSecrets == nil {
+				tt.ExpectPullSecrets = []string{}
+			}
+			assert.Equal(tt.ExpectPullSecrets, pullSecrets)
+
+			volumes := spec["volumes"].ArrayValue()
+			require.Len(volumes, 1)
+			assert.True(volumes[0].ObjectValue().HasValue("emptyDir"))
+		})
+	}
+}
@@ -0,0 +1,437 @@
+package sdk
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/ctfer-io/romeo/sdk/instrument"
+	"github.com/pkg/errors"
+	admissionregistrationv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/admissionregistration/v1"
+	appsv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/apps/v1"
+	corev1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/core/v1"
+	metav1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/meta/v1"
+	netwv1 "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes/networking/v1"
+	"github.com/pulumi/pulumi-tls/sdk/v4/go/tls"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+type (
+	// RomeoWebhook registers a mutating admission webhook that instruments
+	// the pods labelled for coverage in a Romeo environment namespace:
+	// they get the environment claim mounted, with a per-pod GOCOVERDIR
+	// subdirectory.
+	RomeoWebhook struct {
+		pulumi.ResourceState
+
+		key    *tls.PrivateKey
+		cert   *tls.SelfSignedCert
+		sec    *corev1.Secret
+		dep    *appsv1.Deployment
+		svc    *corev1.Service
+		mwc    *admissionregistrationv1.MutatingWebhookConfiguration
+		netpol *netwv1.NetworkPolicy
+
+		// PodLabels to set on the pods to instrument.
+		PodLabels pulumi.StringMapOutput
+	}
+
+	// RomeoWebhookArgs contains all the arguments to deploy a Romeo webhook.
+	RomeoWebhookArgs struct {
+		// Namespace of the Romeo environment, the only one whose pods are
+		// instrumented. Required.
+		Namespace pulumi.StringInput
+
+		// ClaimName of the Romeo environment to mount in the instrumented
+		// pods. Required.
+		ClaimName pulumi.StringInput
+
+		// MountPath to mount the claim at in the instrumented containers.
+		// Defaults to "/etc/coverout".
+		MountPath pulumi.StringInput
+
+		Tag pulumi.StringInput
+		tag pulumi.StringOutput
+
+		// Registry define from where to fetch the Romeo Docker images.
+		// If set empty, defaults to Docker Hub.
+		Registry pulumi.StringInput
+		registry pulumi.StringOutput
+
+		// Digest pins the Romeo Docker image (e.g. "sha256:...").
+		Digest pulumi.StringInput
+
+		// ImagePullSecrets are the names of the Secrets to pull the Romeo
+		// Docker image with, e.g. the [RomeoEnvironment] ones.
+		ImagePullSecrets pulumi.StringArrayInput
+
+		// Harden grants the Kubernetes API server to reach the webhook,
+		// when the namespace denies all traffic by default.
+		Harden bool
+
+		// Restricted runs the webhook complying with the restricted Pod
+		// Security Standard, e.g. in the namespace of a restricted
+		// [RomeoEnvironment].
+		Restricted bool
+	}
+)
+
+const (
+	// WebhookToken is the type token of [*RomeoWebhook].
+	WebhookToken = "ctfer-io:romeo:webhook"
+
+	// InstrumentLabel marks the pods to instrument for coverage.
+	InstrumentLabel = instrument.Label
+
+	webhookPort = 9443
+	webhookCert = "/etc/webhook/tls"
+)
+
+// NewRomeoWebhook deploys the Romeo webhook server and registers it to
+// the Kubernetes API server.
+func NewRomeoWebhook(
+	ctx *pulumi.Context,
+	name string,
+	args *RomeoWebhookArgs,
+	opts ...pulumi.ResourceOption,
+) (*RomeoWebhook, error) {
+	if args == nil {
+		return nil, errors.New("no arguments")
+	}
+	if args.Namespace == nil {
+		return nil, errors.New("no namespace defined")
+	}
+	if args.ClaimName == nil {
+		return nil, errors.New("no claim name defined")
+	}
+	if args.Digest != nil && !validDigest(args.Digest) {
+		return nil, fmt.Errorf("invalid image digest %q, expected sha256:<hex>", args.Digest)
+	}
+
+	rwh := &RomeoWebhook{}
+	args = rwh.defaults(args)
+	if err := ctx.RegisterComponentResource(WebhookToken, name, rwh, opts...); err != nil {
+		return nil, err
+	}
+	opts = append(opts, pulumi.Parent(rwh))
+	if err := rwh.provision(ctx, name, args, opts...); err != nil {
+		return nil, err
+	}
+	if err := rwh.outputs(ctx); err != nil {
+		return nil, err
+	}
+
+	return rwh, nil
+}
+
+func (rwh *RomeoWebhook) defaults(args *RomeoWebhookArgs) *RomeoWebhookArgs {
+	args.Namespace = existingNamespace(args.Namespace)
+
+	// Default tag to dev
+	args.tag = pulumi.String(defaultTag).ToStringOutput()
+	if args.Tag != nil {
+		args.tag = args.Tag.ToStringOutput().ApplyT(func(tag string) string {
+			if tag == "" {
+				return defaultTag
+			}
+			return tag
+		}).(pulumi.StringOutput)
+	}
+
+	// Define private registry if any
+	args.registry = pulumi.String("").ToStringOutput()
+	if args.Registry != nil {
+		args.registry = args.Registry.ToStringOutput().ApplyT(func(in string) string {
+			if in != "" && !strings.HasSuffix(in, "/") {
+				in += "/"
+			}
+			return in
+		}).(pulumi.StringOutput)
+	}
+
+	return args
+}
+
+func (rwh *RomeoWebhook) provision(
+	ctx *pulumi.Context,
+	name string,
+	args *RomeoWebhookArgs,
+	opts ...pulumi.ResourceOption,
+) (err error) {
+	labels := pulumi.StringMap{
+		"app.kubernetes.io/name":      pulumi.String("romeo-webhook"),
+		"app.kubernetes.io/version":   args.tag,
+		"app.kubernetes.io/component": pulumi.String(name),
+		"app.kubernetes.io/part-of":   pulumi.String("romeo"),
+	}
+
+	// => Service (to reach the webhook from the API server)
+	rwh.svc, err = corev1.NewService(ctx, "romeo-webhook-svc-"+name, &corev1.ServiceArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Namespace: args.Namespace,
+			Labels:    labels,
+		},
+		Spec: &corev1.ServiceSpecArgs{
+			Type:     pulumi.String("ClusterIP"),
+			Selector: labels,
+			Ports: corev1.ServicePortArray{
+				corev1.ServicePortArgs{
+					TargetPort: pulumi.Int(webhookPort),
+					Port:       pulumi.Int(443),
+					Name:       pulumi.String("webhook"),
+				},
+			},
+		},
+	}, opts...)
+	if err != nil {
+		return
+	}
+
+	// => Certificate (self-signed, as the API server is given its CA bundle)
+	rwh.key, err = tls.NewPrivateKey(ctx, "romeo-webhook-key-"+name, &tls.PrivateKeyArgs{
+		Algorithm:  pulumi.String("ECDSA"),
+		EcdsaCurve: pulumi.String("P256"),
+	}, opts...)
+	if err != nil {
+		return
+	}
+	host := pulumi.Sprintf("%s.%s.svc", rwh.svc.Metadata.Name().Elem(), args.Namespace)
+	rwh.cert, err = tls.NewSelfSignedCert(ctx, "romeo-webhook-cert-"+name, &tls.SelfSignedCertArgs{
+		PrivateKeyPem: rwh.key.PrivateKeyPem,
+		Subject: tls.SelfSignedCertSubjectArgs{
+			CommonName: host,
+		},
+		DnsNames: pulumi.StringArray{
+			host,
+		},
+		IsCaCertificate:     pulumi.Bool(true),
+		ValidityPeriodHours: pulumi.Int(24 * 365),
+		AllowedUses: pulumi.ToStringArray([]string{
+			"cert_signing",
+			"digital_signature",
+			"key_encipherment",
+			"server_auth",
+		}),
+	}, opts...)
+	if err != nil {
+		return
+	}
+
+	// => Secret (the webhook TLS material)
+	rwh.sec, err = corev1.NewSecret(ctx, "romeo-webhook-tls-"+name, &corev1.SecretArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Namespace: args.Namespace,
+			Labels:    labels,
+		},
+		Type: pulumi.String("kubernetes.io/tls"),
+		StringData: pulumi.StringMap{
+			"tls.crt": rwh.cert.CertPem,
+			"tls.key": rwh.key.PrivateKeyPem,
+		},
+	}, opts...)
+	if err != nil {
+		return
+	}
+
+	// => Deployment
+	envs := corev1.EnvVarArray{
+		corev1.EnvVarArgs{
+			Name:  pulumi.String("TLS_CERT"),
+			Value: pulumi.String(webhookCert + "/tls.crt"),
+		},
+		corev1.EnvVarArgs{
+			Name:  pulumi.String("TLS_KEY"),
+			Value: pulumi.String(webhookCert + "/tls.key"),
+		},
+		corev1.EnvVarArgs{
+			Name:  pulumi.String("CLAIM_NAME"),
+			Value: args.ClaimName,
+		},
+	}
+	if args.MountPath != nil {
+		envs = append(envs, corev1.EnvVarArgs{
+			Name:  pulumi.String("MOUNT_PATH"),
+			Value: args.MountPath,
+		})
+	}
+	// If required, comply with the restricted Pod Security Standard
+	var securityContext corev1.SecurityContextPtrInput
+	var podSecurityContext corev1.PodSecurityContextPtrInput
+	if args.Restricted {
+		securityContext, podSecurityContext = restricted()
+	}
+	rwh.dep, err = appsv1.NewDeployment(ctx, "romeo-webhook-dep-"+name, &appsv1.DeploymentArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Namespace: args.Namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DeploymentSpecArgs{
+			Selector: metav1.LabelSelectorArgs{
+				MatchLabels: labels,
+			},
+			Replicas: pulumi.Int(1),
+			Template: corev1.PodTemplateSpecArgs{
+				Metadata: metav1.ObjectMetaArgs{
+					Namespace: args.Namespace,
+					Labels:    labels,
+				},
+				Spec: corev1.PodSpecArgs{
+					Containers: corev1.ContainerArray{
+						corev1.ContainerArgs{
+							Name:  pulumi.String("romeo-webhook"),
+							Image: romeoImage(args.registry, args.tag, args.Digest),
+							Args: pulumi.ToStringArray([]string{
+								"webhook",
+							}),
+							Ports: corev1.ContainerPortArray{
+								corev1.ContainerPortArgs{
+									ContainerPort: pulumi.Int(webhookPort),
+									Name:          pulumi.String("webhook"),
+								},
+							},
+							Env:             envs,
+							SecurityContext: securityContext,
+							VolumeMounts: corev1.VolumeMountArray{
+								corev1.VolumeMountArgs{
+									Name:      pulumi.String("tls"),
+									MountPath: pulumi.String(webhookCert),
+									ReadOnly:  pulumi.Bool(true),
+								},
+							},
+							ReadinessProbe: corev1.ProbeArgs{
+								HttpGet: corev1.HTTPGetActionArgs{
+									Path:   pulumi.String("/healthz"),
+									Port:   pulumi.String("webhook"),
+									Scheme: pulumi.String("HTTPS"),
+								},
+							},
+						},
+					},
+					Volumes: corev1.VolumeArray{
+						corev1.VolumeArgs{
+							Name: pulumi.String("tls"),
+							Secret: corev1.SecretVolumeSourceArgs{
+								SecretName: rwh.sec.Metadata.Name().Elem(),
+							},
+						},
+					},
+					SecurityContext:  podSecurityContext,
+					ImagePullSecrets: imagePullSecrets(args.ImagePullSecrets),
+				},
+			},
+		},
+	}, opts...)
+	if err != nil {
+		return
+	}
+
+	// => NetworkPolicy (grant the API server to reach the webhook), if required
+	if args.Harden {
+		rwh.netpol, err = netwv1.NewNetworkPolicy(ctx, "romeo-webhook-netpol-"+name, &netwv1.NetworkPolicyArgs{
+			Metadata: metav1.ObjectMetaArgs{
+				Namespace: args.Namespace,
+				Labels:    labels,
+			},
+			Spec: netwv1.NetworkPolicySpecArgs{
+				PodSelector: metav1.LabelSelectorArgs{
+					MatchLabels: labels,
+				},
+				PolicyTypes: pulumi.ToStringArray([]string{
+					"Ingress",
+				}),
+				Ingress: netwv1.NetworkPolicyIngressRuleArray{
+					netwv1.NetworkPolicyIngressRuleArgs{
+						From: netwv1.NetworkPolicyPeerArray{
+							netwv1.NetworkPolicyPeerArgs{
+								IpBlock: netwv1.IPBlockArgs{
+									Cidr: pulumi.String("0.0.0.0/0"),
+								},
+							},
+						},
+						Ports: netwv1.NetworkPolicyPortArray{
+							netwv1.NetworkPolicyPortArgs{
+								Port: pulumi.Int(webhookPort),
+							},
+						},
+					},
+				},
+			},
+		}, opts...)
+		if err != nil {
+			return
+		}
+	}
+
+	// => MutatingWebhookConfiguration
+	// Only the labelled pods of the environment namespace are instrumented,
+	// and the pods are not blocked if the webhook is unavailable.
+	rwh.mwc, err = admissionregistrationv1.NewMutatingWebhookConfiguration(ctx, "romeo-webhook-mwc-"+name, &admissionregistrationv1.MutatingWebhookConfigurationArgs{
+		Metadata: metav1.ObjectMetaArgs{
+			Labels: labels,
+		},
+		Webhooks: admissionregistrationv1.MutatingWebhookArray{
+			admissionregistrationv1.MutatingWebhookArgs{
+				Name: pulumi.String("instrument.romeo.ctfer.io"),
+				AdmissionReviewVersions: pulumi.ToStringArray([]string{
+					"v1",
+				}),
+				ClientConfig: admissionregistrationv1.WebhookClientConfigArgs{
+					CaBundle: rwh.cert.CertPem.ApplyT(func(pem string) string {
+						return base64.StdEncoding.EncodeToString([]byte(pem))
+					}).(pulumi.StringOutput),
+					Service: admissionregistrationv1.ServiceReferenceArgs{
+						Name:      rwh.svc.Metadata.Name().Elem(),
+						Namespace: args.Namespace,
+						Path:      pulumi.String("/mutate"),
+						Port:      pulumi.Int(443),
+					},
+				},
+				Rules: admissionregistrationv1.RuleWithOperationsArray{
+					admissionregistrationv1.RuleWithOperationsArgs{
+						ApiGroups: pulumi.ToStringArray([]string{
+							"",
+						}),
+						ApiVersions: pulumi.ToStringArray([]string{
+							"v1",
+						}),
+						Operations: pulumi.ToStringArray([]string{
+							"CREATE",
+						}),
+						Resources: pulumi.ToStringArray([]string{
+							"pods",
+						}),
+						Scope: pulumi.String("Namespaced"),
+					},
+				},
+				NamespaceSelector: metav1.LabelSelectorArgs{
+					MatchLabels: pulumi.StringMap{
+						"kubernetes.io/metadata.name": args.Namespace,
+					},
+				},
+				ObjectSelector: metav1.LabelSelectorArgs{
+					MatchLabels: pulumi.StringMap{
+						InstrumentLabel: pulumi.String("true"),
+					},
+				},
+				FailurePolicy:  pulumi.String("Ignore"),
+				SideEffects:    pulumi.String("None"),
+				TimeoutSeconds: pulumi.Int(5),
+			},
+		},
+	}, append(opts, pulumi.DependsOn([]pulumi.Resource{rwh.dep}))...)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (rwh *RomeoWebhook) outputs(ctx *pulumi.Context) error {
+	rwh.PodLabels = pulumi.StringMap{
+		InstrumentLabel: pulumi.String("true"),
+	}.ToStringMapOutput()
+
+	return ctx.RegisterResourceOutputs(rwh, pulumi.Map{
+		"podLabels": rwh.PodLabels,
+	})
+}
@@ -0,0 +1,89 @@
+package sdk_test
+
+import (
+	"testing"
+
+	"github.com/ctfer-io/romeo/sdk"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_U_RomeoWebhook(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Args             *sdk.RomeoWebhookArgs
+		ExpectErr        bool
+		ExpectRestricted bool
+	}{
+		"nil": {
+			Args:      nil,
+			ExpectErr: true,
+		},
+		"no-claim-name": {
+			Args: &sdk.RomeoWebhookArgs{
+				Namespace: pulumi.String("romeo"),
+			},
+			ExpectErr: true,
+		},
+		"webhook": {
+			Args: &sdk.RomeoWebhookArgs{
+				Namespace: pulumi.String("romeo"),
+				ClaimName: pulumi.String("claim"),
+			},
+		},
+		"hardened": {
+			Args: &sdk.RomeoWebhookArgs{
+				Namespace: pulumi.String("romeo"),
+				ClaimName: pulumi.String("claim"),
+				MountPath: pulumi.String("/coverout"),
+				Registry:  pulumi.String("localhost:5000"),
+				Harden:    true,
+			},
+		},
+		"restricted": {
+			Args: &sdk.RomeoWebhookArgs{
+				Namespace:  pulumi.String("romeo"),
+				ClaimName:  pulumi.String("claim"),
+				Restricted: true,
+			},
+			ExpectRestricted: true,
+		},
+		"private-registry": {
+			Args: &sdk.RomeoWebhookArgs{
+				Namespace:        pulumi.String("romeo"),
+				ClaimName:        pulumi.String("claim"),
+				Digest:           pulumi.String("sha256:abababababababababababababababababababababababababababababababab"),
+				ImagePullSecrets: pulumi.ToStringArray([]string{"pull"}),
+			},
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			rec := &recordMocks{}
+			err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+				_, err := sdk.NewRomeoWebhook(ctx, "romeo-test", tt.Args)
+				if tt.ExpectErr {
+					require.Error(err)
+				} else {
+					require.NoError(err)
+				}
+
+				return nil
+			}, pulumi.WithMocks("project", "stack", rec))
+			assert.NoError(err)
+			if tt.ExpectErr {
+				return
+			}
+
+			deps := rec.of("kubernetes:apps/v1:Deployment")
+			require.Len(deps, 1)
+			assertRestricted(assert, field(deps[0], "spec", "template", "spec"), tt.ExpectRestricted)
+		})
+	}
+}
@@ -4,8 +4,9 @@ import * as fs from 'fs/promises'
 import * as path from 'path'
 import * as os from 'os'
 import AdmZip from 'adm-zip'
-import { execFile } from 'child_process'
+import { ChildProcess, execFile, spawn } from 'child_process'
 import { promisify } from 'util'
+import { resolveInput } from './fs'
 
 const execFileAsync = promisify(execFile)
 
@@ -13,6 +14,23 @@ type MergedResponse = {
     merged: string
 }
 
+type Service = {
+    metadata: {
+        name: string
+        labels?: Record<string, string>
+    }
+    spec: {
+        selector?: Record<string, string>
+        ports: { name?: string; port: number }[]
+    }
+}
+
+type Pod = {
+    spec: {
+        containers: { env?: { name: string; value?: string }[] }[]
+    }
+}
+
 function isPathInside(parent: string, child: string): boolean {
     const relative = path.relative(parent, child)
     return (
@@ -40,18 +58,159 @@ async function unzipToDir(zipBuffer: Buffer, targetDir: string): Promise<void> {
     }
 }
 
+// cleanBasePath returns the base path with a leading slash and without a
+// trailing one, or an empty string, as the Romeo webserver does.
+function cleanBasePath(basePath: string): string {
+    const trimmed = basePath.replace(/^\/+|\/+$/g, '')
+    return trimmed ? `/${trimmed}` : ''
+}
+
+// kubectlArgs returns the kubectl arguments of the kubeconfig and namespace
+// inputs, if any. Else, kubectl uses its defaults (e.g. KUBECONFIG).
+async function kubectlArgs(): Promise<string[]> {
+    const args: string[] = []
+    const kubeconfig = core.getInput('kubeconfig')
+    if (kubeconfig) {
+        const dir = await fs.mkdtemp(
+            path.join(os.tmpdir(), 'romeo-kubeconfig-')
+        )
+        const file = path.join(dir, 'kubeconfig')
+        await fs.writeFile(file, resolveInput(kubeconfig), { mode: 0o600 })
+        args.push(`--kubeconfig=${file}`)
+    }
+    const namespace = core.getInput('namespace')
+    if (namespace) {
+        args.push(`--namespace=${namespace}`)
+    }
+    return args
+}
+
+// portForward discovers the Romeo Service, and port-forwards to it through
+// the Kubernetes API server with kubectl, as `romeo download` does.
+// It returns the local URL to reach the Romeo webserver at, under its base
+// path if any (e.g. when exposed through a Gateway), and the kubectl process
+// to kill once done.
+async function portForward(): Promise<{ url: string; process: ChildProcess }> {
+    const args = await kubectlArgs()
+
+    let selector = core.getInput('selector')
+    const instance = core.getInput('instance')
+    if (instance) {
+        selector += `,instance=${instance}`
+    }
+    const { stdout: svcsOut } = await execFileAsync('kubectl', [
+        ...args,
+        'get',
+        'services',
+        `--selector=${selector}`,
+        '--output=json'
+    ])
+    const svcs = (JSON.parse(svcsOut) as { items: Service[] }).items
+    if (svcs.length === 0) {
+        throw new Error(`No Romeo service found matching ${selector}`)
+    }
+    if (svcs.length > 1) {
+        const names = svcs.map(
+            svc =>
+                `${svc.metadata.name} (instance=${svc.metadata.labels?.instance})`
+        )
+        throw new Error(
+            `Many Romeo services found matching ${selector}, please specify an instance among: ${names.join(', ')}`
+        )
+    }
+    const svc = svcs[0]
+    const port = (
+        svc.spec.ports.find(p => p.name === 'api') ?? svc.spec.ports[0]
+    )?.port
+    if (!port) {
+        throw new Error(`Service ${svc.metadata.name} has no port`)
+    }
+
+    // The webserver serves under its BASE_PATH, if any
+    const podSelector = Object.entries(svc.spec.selector ?? {})
+        .map(([k, v]) => `${k}=${v}`)
+        .join(',')
+    const { stdout: podsOut } = await execFileAsync('kubectl', [
+        ...args,
+        'get',
+        'pods',
+        `--selector=${podSelector}`,
+        '--output=json'
+    ])
+    const pods = (JSON.parse(podsOut) as { items: Pod[] }).items
+    const basePath =
+        pods[0]?.spec.containers
+            .flatMap(c => c.env ?? [])
+            .find(env => env.name === 'BASE_PATH')?.value ?? ''
+
+    // Open the port-forward on a random local port
+    const pf = spawn('kubectl', [
+        ...args,
+        'port-forward',
+        '--address=127.0.0.1',
+        `service/${svc.metadata.name}`,
+        `:${port}`
+    ])
+    let stderr = ''
+    pf.stderr.on('data', (data: Buffer) => {
+        stderr += data.toString()
+    })
+    const local = await new Promise<string>((resolve, reject) => {
+        pf.stdout.on('data', (data: Buffer) => {
+            const match = /Forwarding from 127\.0\.0\.1:(\d+)/.exec(
+                data.toString()
+            )
+            if (match) {
+                resolve(match[1])
+            }
+        })
+        pf.on('error', reject)
+        pf.on('exit', code => {
+            reject(
+                new Error(
+                    `kubectl port-forward exited with code ${code}: ${stderr}`
+                )
+            )
+        })
+    })
+    core.info(
+        `Port-forwarding to Romeo service ${svc.metadata.name} on local port ${local}`
+    )
+
+    return {
+        url: `http://127.0.0.1:${local}${cleanBasePath(basePath)}`,
+        process: pf
+    }
+}
+
 async function run(): Promise<void> {
     try {
-        const url = core.getInput('server', { required: true })
         const strategy = core.getInput('strategy')
 
+        // Reach out the Romeo environment, through a port-forward if no server is defined
+        let url = core.getInput('server')
+        let pf: ChildProcess | undefined
+        if (!url) {
+            core.info(
+                'No server defined, port-forwarding through the Kubernetes API server...'
+            )
+            const forwarded = await portForward()
+            url = forwarded.url
+            pf = forwarded.process
+        }
+
         // Fetch JSON
-        const response = await fetch(`${url}/api/v1/coverout`)
-        if (!response.ok) {
-            throw new Error(`Failed to fetch: ${response.statusText}`)
+        let json: MergedResponse
+        try {
+            const response = await fetch(`${url}/api/v1/coverout`)
+            if (!response.ok) {
+                throw new Error(`Failed to fetch: ${response.statusText}`)
+            }
+            json = (await response.json()) as MergedResponse
+        } finally {
+            pf?.kill()
         }
 
-        const json = (await response.json()) as MergedResponse
         const base64Zip = json.merged
 
         if (!base64Zip || typeof base64Zip !== 'string') {
@@ -1,4 +1,7 @@
 import * as core from '@actions/core'
+import { Stack } from '@pulumi/pulumi/automation'
+import fetch from 'node-fetch'
+import * as fsp from 'fs/promises'
 import * as stateHelper from './state-helper'
 import * as fs from './fs'
 import * as iac from './iac'
@@ -19,9 +22,45 @@ async function run(): Promise<void> {
             'env:harden': {
                 value: core.getInput('harden', { required: false })
             },
+            'env:allow-cidrs': {
+                value: core.getInput('allow-cidrs')
+            },
+            'env:allow-namespace-selector': {
+                value: core.getInput('allow-namespace-selector')
+            },
+            'env:allow-pod-selector': {
+                value: core.getInput('allow-pod-selector')
+            },
+            'env:allow-apps-cidrs': {
+                value: core.getInput('allow-apps-cidrs')
+            },
+            'env:allow-apps-namespace-selector': {
+                value: core.getInput('allow-apps-namespace-selector')
+            },
+            'env:allow-apps-pod-selector': {
+                value: core.getInput('allow-apps-pod-selector')
+            },
             'env:tag': {
                 value: core.getInput('tag')
             },
+            'env:pod-security-level': {
+                value: core.getInput('pod-security-level')
+            },
+            'env:restricted': {
+                value: core.getInput('restricted')
+            },
+            'env:cpu-request': {
+                value: core.getInput('cpu-request')
+            },
+            'env:memory-request': {
+                value: core.getInput('memory-request')
+            },
+            'env:cpu-limit': {
+                value: core.getInput('cpu-limit')
+            },
+            'env:memory-limit': {
+                value: core.getInput('memory-limit')
+            },
             'env:storage-class-name': {
                 value: core.getInput('storage-class-name')
             },
@@ -33,6 +72,94 @@ async function run(): Promise<void> {
             },
             'env:registry': {
                 value: core.getInput('registry')
+            },
+            'env:digest': {
+                value: core.getInput('digest')
+            },
+            'env:image-pull-secrets': {
+                value: core.getInput('image-pull-secrets')
+            },
+            'env:registry-username': {
+                value: core.getInput('registry-username')
+            },
+            'env:registry-password': {
+                value: core.getInput('registry-password'),
+                secret: true
+            },
+            'env:pvc-access-mode': {
+                value: core.getInput('pvc-access-mode')
+            },
+            'env:co-locate': {
+                value: core.getInput('co-locate')
+            },
+            'env:expose': {
+                value: core.getInput('expose')
+            },
+            'env:node-address': {
+                value: core.getInput('node-address')
+            },
+            'env:webhook': {
+                value: core.getInput('webhook')
+            },
+            'env:instance': {
+                value: core.getInput('instance')
+            },
+            'env:ttl': {
+                value: core.getInput('ttl')
+            },
+            'env:ingress-host': {
+                value: core.getInput('ingress-host')
+            },
+            'env:ingress-tls-secret-name': {
+                value: core.getInput('ingress-tls-secret-name')
+            },
+            'env:ingress-class-name': {
+                value: core.getInput('ingress-class-name')
+            },
+            'env:gateway-name': {
+                value: core.getInput('gateway-name')
+            },
+            'env:gateway-namespace': {
+                value: core.getInput('gateway-namespace')
+            },
+            'env:gateway-section-name': {
+                value: core.getInput('gateway-section-name')
+            },
+            'env:gateway-hostname': {
+                value: core.getInput('gateway-hostname')
+            },
+            'env:gateway-path-prefix': {
+                value: core.getInput('gateway-path-prefix')
+            },
+            'env:gateway-address': {
+                value: core.getInput('gateway-address')
+            },
+            'env:preserve-sink': {
+                value: core.getInput('preserve-sink')
+            },
+            'env:preserve-claim-name': {
+                value: core.getInput('preserve-claim-name')
+            },
+            'env:preserve-s3-endpoint': {
+                value: core.getInput('preserve-s3-endpoint')
+            },
+            'env:preserve-s3-region': {
+                value: core.getInput('preserve-s3-region')
+            },
+            'env:preserve-s3-insecure': {
+                value: core.getInput('preserve-s3-insecure')
+            },
+            'env:preserve-secret-name': {
+                value: core.getInput('preserve-secret-name')
+            },
+            'env:preserve-allow-cidrs': {
+                value: core.getInput('preserve-allow-cidrs')
+            },
+            'env:preserve-allow-namespace-selector': {
+                value: core.getInput('preserve-allow-namespace-selector')
+            },
+            'env:preserve-allow-pod-selector': {
+                value: core.getInput('preserve-allow-pod-selector')
             }
         })
 
@@ -41,16 +168,57 @@ async function run(): Promise<void> {
         core.setOutput('port', upRes.outputs['port'].value)
         core.setOutput('claim-name', upRes.outputs['claim-name'].value)
         core.setOutput('namespace', upRes.outputs['namespace'].value)
+        core.setOutput('url', upRes.outputs['url'].value)
+        core.setOutput('affinity', upRes.outputs['affinity']?.value)
     } catch (error) {
         core.setFailed(`${(error as Error)?.message ?? error}`)
     }
 }
 
+type MergedResponse = {
+    merged: string
+}
+
+// preserve writes the coverages to the preserve-file input, if any, before
+// the environment is destroyed. It only warns on failure, for the
+// environment to be destroyed anyway.
+async function preserve(stack: Stack): Promise<void> {
+    const file = core.getInput('preserve-file')
+    if (!file) {
+        return
+    }
+
+    try {
+        const outputs = await stack.outputs()
+        const url = outputs['url']?.value
+        if (!url) {
+            throw new Error('no url output to fetch the coverages from')
+        }
+
+        const response = await fetch(`${url}/api/v1/coverout`)
+        if (!response.ok) {
+            throw new Error(`Failed to fetch: ${response.statusText}`)
+        }
+        const json = (await response.json()) as MergedResponse
+        if (!json.merged || typeof json.merged !== 'string') {
+            throw new Error('Invalid or missing "merged" attribute in JSON.')
+        }
+
+        await fsp.writeFile(file, Buffer.from(json.merged, 'base64'))
+        core.info(`Preserved coverages to ${file}`)
+    } catch (error) {
+        core.warning(
+            `Preserving coverages: ${(error as Error)?.message ?? error}`
+        )
+    }
+}
+
 async function cleanup(): Promise<void> {
     var stackName = core.getInput('stack-name')
 
     try {
         const stack = await iac.getStack(stackName, 'environment')
+        await preserve(stack)
         await stack.destroy({ onOutput: core.info, remove: true })
     } catch (error) {
         core.warning(`${(error as Error)?.message ?? error}`)
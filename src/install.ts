@@ -22,6 +22,24 @@ async function run(): Promise<void> {
             },
             'install:harden': {
                 value: core.getInput('harden', { required: false })
+            },
+            'install:webhook': {
+                value: core.getInput('webhook', { required: false })
+            },
+            'install:gc-schedule': {
+                value: core.getInput('gc-schedule')
+            },
+            'install:tag': {
+                value: core.getInput('tag')
+            },
+            'install:registry': {
+                value: core.getInput('registry')
+            },
+            'install:digest': {
+                value: core.getInput('digest')
+            },
+            'install:image-pull-secrets': {
+                value: core.getInput('image-pull-secrets')
             }
         })
 
@@ -0,0 +1,98 @@
+package apiv1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Client of the v1 API.
+type Client struct {
+	server string
+	client *http.Client
+}
+
+// NewClient constructs a fresh [*Client] to reach the Romeo webserver
+// at the given server URL (e.g. http://localhost:8080).
+// If client is nil, defaults to [http.DefaultClient].
+func NewClient(server string, client *http.Client) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Client{
+		server: strings.TrimSuffix(server, "/"),
+		client: client,
+	}
+}
+
+// Coverout fetches the merged coverages.
+func (c *Client) Coverout(ctx context.Context) (*CoveroutResponse, error) {
+	resp := &CoveroutResponse{}
+	if err := c.get(ctx, "/api/v1/coverout", resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Diagnostics fetches the coverage directory diagnostics.
+func (c *Client) Diagnostics(ctx context.Context) (*DiagnosticsResponse, error) {
+	resp := &DiagnosticsResponse{}
+	if err := c.get(ctx, "/api/v1/diagnostics", resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Upload pushes a coverage file of a pod.
+func (c *Client) Upload(ctx context.Context, pod, file string, r io.Reader) (*UploadResponse, error) {
+	resp := &UploadResponse{}
+	if err := c.do(ctx, http.MethodPut, "/api/v1/coverages/"+url.PathEscape(pod)+"/"+url.PathEscape(file), r, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// get issues a GET request and decodes the response into dst.
+func (c *Client) get(ctx context.Context, path string, dst any) error {
+	return c.do(ctx, http.MethodGet, path, nil, dst)
+}
+
+// do issues a request and decodes the response into dst.
+// Problems are mapped back to their typed errors.
+func (c *Client) do(ctx context.Context, method, path string, body io.Reader, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.server+path, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		mt, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+		if mt != ProblemContentType {
+			return fmt.Errorf("unexpected status %s", res.Status)
+		}
+		p := &Problem{}
+		if err := json.NewDecoder(res.Body).Decode(p); err != nil {
+			return errors.Wrapf(err, "decoding problem of status %s", res.Status)
+		}
+		return p.Err()
+	}
+
+	return json.NewDecoder(res.Body).Decode(dst)
+}
@@ -1,15 +1,21 @@
 package apiv1
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"io/fs"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strings"
 
-	"github.com/ctfer-io/romeo/webserver"
+	"github.com/ctfer-io/romeo/webserver/storage"
 	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
@@ -18,29 +24,69 @@ type CoveroutResponse struct {
 	Merged string `json:"merged"`
 }
 
-var (
-	Coverdir = ""
-)
+// Coverout merges the coverage data of the storage and serves them zipped
+// and base 64 encoded.
+func (s *Server) Coverout(ctx *gin.Context) {
+	// Keep the temporary directories until the merge returns
+	if !s.begin(ctx) {
+		return
+	}
+	defer s.end()
+
+	// Limit concurrent merges
+	if !s.acquire(ctx) {
+		return
+	}
+	defer s.release()
 
-func Coverout(ctx *gin.Context) {
 	// Create temporary directory
-	tmpDir, rm := newTmpDir()
-	if rm == nil {
+	tmpDir, rm, err := s.newTmpDir()
+	if err != nil {
+		s.fail(ctx, err)
 		return
 	}
 	defer rm()
 
+	// Fetch the coverage data locally, for the merge engine to read them
+	coverdir, rmCoverdir, err := s.localCoverdir(ctx.Request.Context())
+	if err != nil {
+		s.fail(ctx, err)
+		return
+	}
+	defer rmCoverdir()
+
+	// Look for the directories to merge, as instrumented pods export
+	// their coverages in their own subdirectory
+	inputs, err := inputDirs(coverdir)
+	if err != nil {
+		s.fail(ctx, errors.Wrap(err, "looking for coverage directories"))
+		return
+	}
+
 	// Merge files
 	// TODO bind to Go's internals rather than executing it (smaller Docker images and avoid CLI flags injections)
-	cmd := exec.Command("go", "tool", "covdata", "merge", "-i="+Coverdir, "-o="+tmpDir)
+	stderr := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx.Request.Context(), "go", "tool", "covdata", "merge", "-i="+strings.Join(inputs, ","), "-o="+tmpDir)
+	cmd.Stderr = stderr
 	if err := cmd.Run(); err != nil {
-		internalErr(ctx, "merge returned non-zero status code")
+		s.fail(ctx, &ErrMerge{
+			Stderr: stderr.String(),
+			Err:    err,
+		})
 		return
 	}
 
 	merged, err := Encode(tmpDir)
 	if err != nil {
-		internalErr(ctx, err.Error())
+		s.fail(ctx, errors.Wrap(err, "encoding merged coverages"))
+		return
+	}
+	if s.maxSize > 0 && int64(len(merged)) > s.maxSize {
+		// The merged content is produced by the server, so it is not the
+		// client's fault.
+		p := NewProblem(ErrTooLargeContent{MaxSize: s.maxSize})
+		p.Status = http.StatusInternalServerError
+		WriteProblem(ctx, s.logger, p)
 		return
 	}
 
@@ -50,7 +96,52 @@ func Coverout(ctx *gin.Context) {
 	})
 }
 
-func newTmpDir() (string, func()) {
+// localCoverdir returns the local directory containing the coverage data
+// of the storage, fetching them into a temporary one if it is not local.
+// The returned function removes it once merged.
+func (s *Server) localCoverdir(ctx context.Context) (string, func(), error) {
+	if local, ok := s.storage.(storage.Local); ok {
+		return local.Dir(), func() {}, nil
+	}
+
+	tmpDir, rm, err := s.newTmpDir()
+	if err != nil {
+		return "", nil, err
+	}
+	if err := storage.Fetch(ctx, s.storage, tmpDir); err != nil {
+		rm()
+		return "", nil, errors.Wrap(err, "fetching coverage data")
+	}
+	return tmpDir, rm, nil
+}
+
+// inputDirs returns the directories under root that contain coverage
+// meta-data files, as "go tool covdata" does not look into subdirectories.
+// If none does, returns root such that the merge reports it.
+func inputDirs(root string) ([]string, error) {
+	dirs := []string{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !metaRegex.MatchString(d.Name()) {
+			return nil
+		}
+		if dir := filepath.Dir(path); !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(dirs) == 0 {
+		dirs = append(dirs, root)
+	}
+	return dirs, nil
+}
+
+func (s *Server) newTmpDir() (string, func(), error) {
 	// Generate random name
 	b := make([]byte, 8)
 	_, _ = rand.Read(b)
@@ -58,27 +149,27 @@ func newTmpDir() (string, func()) {
 
 	// Create directory
 	if err := os.Mkdir(tmpDir, os.ModePerm); err != nil {
-		webserver.Logger.Error("creating temporary directory failed",
-			zap.String("directory", tmpDir),
-			zap.Error(err),
-		)
-		return "", nil
+		return "", nil, errors.Wrapf(err, "creating temporary directory %s", tmpDir)
 	}
+	s.tmpMx.Lock()
+	s.tmpDirs[tmpDir] = struct{}{}
+	s.tmpMx.Unlock()
 
 	return tmpDir, func() {
-		// Delete directory
-		if err := os.Remove(tmpDir); err != nil {
-			webserver.Logger.Error("deleting temporary directory failed",
-				zap.String("directory", tmpDir),
-				zap.Error(err),
-			)
-		}
-	}
+		s.tmpMx.Lock()
+		delete(s.tmpDirs, tmpDir)
+		s.tmpMx.Unlock()
+
+		s.removeTmpDir(tmpDir)
+	}, nil
 }
 
-func internalErr(ctx *gin.Context, err string) {
-	webserver.Logger.Error("internal error", zap.String("err", err))
-	ctx.JSON(http.StatusInternalServerError, gin.H{
-		"error": err,
-	})
+func (s *Server) removeTmpDir(tmpDir string) {
+	// Delete directory and the merged files it contains
+	if err := os.RemoveAll(tmpDir); err != nil {
+		s.logger.Error("deleting temporary directory failed",
+			zap.String("directory", tmpDir),
+			zap.Error(err),
+		)
+	}
 }
@@ -0,0 +1,140 @@
+package apiv1
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os/exec"
+	"path"
+	"regexp"
+
+	"github.com/ctfer-io/romeo/webserver/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// DiagnosticsResponse is the response to a GET /diagnostics call
+type DiagnosticsResponse struct {
+	Files []FileDiagnostic `json:"files"`
+}
+
+// FileDiagnostic describes a file of the coverage storage.
+type FileDiagnostic struct {
+	// Name of the file, relative to the coverage storage root.
+	Name string `json:"name"`
+
+	// Kind of the file, either "meta", "counters" or "unknown".
+	Kind string `json:"kind"`
+
+	// Size of the file in bytes.
+	Size int64 `json:"size"`
+
+	// Status of the file parsing, either "ok", "invalid" or "ignored".
+	Status string `json:"status"`
+
+	// Error describing why the file is invalid, if so.
+	Error string `json:"error,omitempty"`
+}
+
+const (
+	FileKindMeta     = "meta"
+	FileKindCounters = "counters"
+	FileKindUnknown  = "unknown"
+
+	FileStatusOK      = "ok"
+	FileStatusInvalid = "invalid"
+	FileStatusIgnored = "ignored"
+)
+
+var (
+	// Magic numbers of the coverage files, as defined by the Go
+	// internal/coverage package.
+	metaMagic     = []byte{0x00, 0x63, 0x76, 0x6d}
+	countersMagic = []byte{0x00, 0x63, 0x77, 0x6d}
+
+	metaRegex     = regexp.MustCompile(`^covmeta\.[0-9a-f]+$`)
+	countersRegex = regexp.MustCompile(`^covcounters\.[0-9a-f]+\.[0-9]+\.[0-9]+$`)
+)
+
+// Diagnostics lists the files of the coverage storage along with
+// their parse status.
+func (s *Server) Diagnostics(ctx *gin.Context) {
+	objs, err := s.storage.List(ctx.Request.Context())
+	if err != nil {
+		s.fail(ctx, errors.Wrap(err, "listing coverage storage"))
+		return
+	}
+
+	files := make([]FileDiagnostic, 0, len(objs))
+	for _, obj := range objs {
+		files = append(files, s.diagnose(ctx.Request.Context(), obj))
+	}
+
+	ctx.JSON(http.StatusOK, DiagnosticsResponse{
+		Files: files,
+	})
+}
+
+func (s *Server) diagnose(ctx context.Context, obj storage.Object) FileDiagnostic {
+	fd := FileDiagnostic{
+		Name: obj.Name,
+		Size: obj.Size,
+	}
+
+	var magic []byte
+	switch base := path.Base(obj.Name); {
+	case metaRegex.MatchString(base):
+		fd.Kind = FileKindMeta
+		magic = metaMagic
+	case countersRegex.MatchString(base):
+		fd.Kind = FileKindCounters
+		magic = countersMagic
+	default:
+		fd.Kind = FileKindUnknown
+		fd.Status = FileStatusIgnored
+		return fd
+	}
+
+	if err := s.checkMagic(ctx, obj.Name, magic); err != nil {
+		fd.Status = FileStatusInvalid
+		fd.Error = err.Error()
+		return fd
+	}
+	fd.Status = FileStatusOK
+	return fd
+}
+
+func (s *Server) checkMagic(ctx context.Context, name string, magic []byte) error {
+	r, err := s.storage.Open(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Close() }()
+
+	b := make([]byte, len(magic))
+	if _, err := io.ReadFull(r, b); err != nil {
+		return errors.Wrap(err, "reading header")
+	}
+	if !bytes.Equal(b, magic) {
+		return errors.New("invalid magic number")
+	}
+	return nil
+}
+
+// Ready checks the server is able to serve merges i.e. the coverage
+// storage is readable and the merge engine is available.
+func (s *Server) Ready(ctx context.Context) error {
+	if err := s.storage.Ready(ctx); err != nil {
+		return errors.Wrapf(ErrNotReady, "reading coverage storage: %s", err)
+	}
+
+	if !s.engineOK.Load() {
+		cmd := exec.CommandContext(ctx, "go", "tool", "-n", "covdata")
+		if err := cmd.Run(); err != nil {
+			return errors.Wrapf(ErrNotReady, "merge engine unavailable: %s", err)
+		}
+		s.engineOK.Store(true)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package apiv1
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BuildInfo is the response to a GET /version call.
+// It describes the webserver build, for clients to check compatibility.
+type BuildInfo struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	Date    string `json:"date"`
+	BuiltBy string `json:"builtBy"`
+}
+
+// Version serves the webserver build information.
+func (s *Server) Version(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, s.buildInfo)
+}
+
+// OpenAPI serves the OpenAPI 3 document of the v1 API.
+func (s *Server) OpenAPI(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, s.OpenAPIDocument())
+}
+
+// OpenAPIDocument generates the OpenAPI 3 document of the v1 API, from
+// the registered routes and their response types.
+func (s *Server) OpenAPIDocument() map[string]any {
+	gen := &schemaGen{
+		schemas: map[string]any{},
+	}
+	problem := gen.ref(reflect.TypeOf(Problem{}))
+
+	paths := map[string]any{}
+	for _, r := range s.routes() {
+		op := map[string]any{
+			"operationId": r.operationID,
+			"summary":     r.summary,
+			"responses": map[string]any{
+				"200": map[string]any{
+					"description": "OK",
+					"content": map[string]any{
+						"application/json": map[string]any{
+							"schema": gen.ref(reflect.TypeOf(r.response)),
+						},
+					},
+				},
+				"default": map[string]any{
+					"description": "Problem",
+					"content": map[string]any{
+						ProblemContentType: map[string]any{
+							"schema": problem,
+						},
+					},
+				},
+			},
+		}
+		if r.body != "" {
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					r.body: map[string]any{
+						"schema": map[string]any{"type": "string", "format": "binary"},
+					},
+				},
+			}
+		}
+
+		// Path parameters are ":name" for gin, "{name}" for OpenAPI
+		elems := strings.Split(r.path, "/")
+		params := []any{}
+		for i, elem := range elems {
+			if name, ok := strings.CutPrefix(elem, ":"); ok {
+				elems[i] = "{" + name + "}"
+				params = append(params, map[string]any{
+					"name":     name,
+					"in":       "path",
+					"required": true,
+					"schema":   map[string]any{"type": "string"},
+				})
+			}
+		}
+		if len(params) != 0 {
+			op["parameters"] = params
+		}
+		path := strings.Join(elems, "/")
+
+		item, ok := paths[path].(map[string]any)
+		if !ok {
+			item = map[string]any{}
+			paths[path] = item
+		}
+		item[strings.ToLower(r.method)] = op
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Romeo webserver",
+			"description": "O Romeo, Romeo, whatfore art coverages Romeo?",
+			"version":     s.buildInfo.Version,
+			"license": map[string]any{
+				"name": "Apache-2.0",
+			},
+		},
+		"servers": []any{
+			map[string]any{
+				"url": s.basePath + "/api/v1",
+			},
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": gen.schemas,
+		},
+	}
+}
+
+// schemaGen generates OpenAPI schemas out of Go types, based on their
+// JSON encoding.
+type schemaGen struct {
+	schemas map[string]any
+}
+
+// ref returns a reference to the schema of a named struct, or the inline
+// schema of other types.
+func (gen *schemaGen) ref(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t.Name() == "" {
+		return gen.schema(t)
+	}
+
+	if _, ok := gen.schemas[t.Name()]; !ok {
+		gen.schemas[t.Name()] = nil // avoid infinite recursion
+		gen.schemas[t.Name()] = gen.schema(t)
+	}
+	return map[string]any{
+		"$ref": "#/components/schemas/" + t.Name(),
+	}
+}
+
+func (gen *schemaGen) schema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{
+			"type":  "array",
+			"items": gen.ref(t.Elem()),
+		}
+	case reflect.Map:
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": gen.ref(t.Elem()),
+		}
+	case reflect.Struct:
+		props := map[string]any{}
+		required := []string{}
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = gen.ref(f.Type)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		return map[string]any{
+			"type":       "object",
+			"properties": props,
+			"required":   required,
+		}
+	default:
+		return map[string]any{}
+	}
+}
@@ -0,0 +1,186 @@
+package apiv1
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ProblemContentType is the content type of the error responses,
+// as defined by RFC 7807.
+const ProblemContentType = "application/problem+json"
+
+// Stable error codes, exposed as the "code" extension member of a
+// [*Problem]. They won't change across versions.
+const (
+	CodeInternal        = "internal"
+	CodeNotFound        = "not-found"
+	CodeNotReady        = "not-ready"
+	CodeMergeFailed     = "merge-failed"
+	CodeTooManyMerges   = "too-many-merges"
+	CodePathTainted     = "path-tainted"
+	CodeTooLargeContent = "too-large-content"
+)
+
+const problemTypePrefix = "urn:ctfer-io:romeo:problem:"
+
+// Problem is an RFC 7807 problem details object, with Romeo's extension
+// members.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// Code is the stable error code, see the Code* constants.
+	Code string `json:"code"`
+
+	// Diagnostics are the merge engine outputs, if any.
+	Diagnostics string `json:"diagnostics,omitempty"`
+
+	// Path that is tainted, for a [CodePathTainted] problem.
+	Path string `json:"path,omitempty"`
+
+	// MaxSize that has been exceeded, for a [CodeTooLargeContent] problem.
+	MaxSize int64 `json:"maxSize,omitempty"`
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return fmt.Sprintf("%s (%s): %s", p.Title, p.Code, p.Detail)
+	}
+	return fmt.Sprintf("%s (%s)", p.Title, p.Code)
+}
+
+var _ error = (*Problem)(nil)
+
+// NewProblem builds the [*Problem] describing an error, with the
+// corresponding status code.
+func NewProblem(err error) *Problem {
+	var (
+		prb   *Problem
+		merr  *ErrMerge
+		pterr *ErrPathTainted
+		tlerr ErrTooLargeContent
+	)
+	switch {
+	case errors.As(err, &prb):
+		return prb
+	case errors.As(err, &merr):
+		p := newProblem(http.StatusInternalServerError, CodeMergeFailed, "Merge failed", merr.Err)
+		p.Diagnostics = merr.Stderr
+		return p
+	case errors.As(err, &pterr):
+		p := newProblem(http.StatusBadRequest, CodePathTainted, "Path tainted", err)
+		p.Path = pterr.Path
+		return p
+	case errors.As(err, &tlerr):
+		p := newProblem(http.StatusRequestEntityTooLarge, CodeTooLargeContent, "Too large content", err)
+		p.MaxSize = tlerr.MaxSize
+		return p
+	case errors.Is(err, ErrTooManyMerges):
+		return newProblem(http.StatusServiceUnavailable, CodeTooManyMerges, "Too many merges", err)
+	case errors.Is(err, ErrNotReady):
+		return newProblem(http.StatusServiceUnavailable, CodeNotReady, "Not ready", err)
+	default:
+		return newProblem(http.StatusInternalServerError, CodeInternal, "Internal error", err)
+	}
+}
+
+// WriteProblem answers the request with the problem, and logs it.
+func WriteProblem(ctx *gin.Context, logger *zap.Logger, p *Problem) {
+	if p.Instance == "" {
+		p.Instance = ctx.Request.URL.Path
+	}
+
+	lvl := zap.WarnLevel
+	if p.Status >= http.StatusInternalServerError {
+		lvl = zap.ErrorLevel
+	}
+	logger.Log(lvl, "request failed",
+		zap.String("code", p.Code),
+		zap.Int("status", p.Status),
+		zap.String("detail", p.Detail),
+		zap.String("diagnostics", p.Diagnostics),
+	)
+
+	ctx.Header("Content-Type", ProblemContentType)
+	ctx.AbortWithStatusJSON(p.Status, p)
+}
+
+// NotFound builds the [*Problem] of an unknown route.
+func NotFound(path string) *Problem {
+	p := newProblem(http.StatusNotFound, CodeNotFound, "Not found", errors.Errorf("no route for %s", path))
+	p.Instance = path
+	return p
+}
+
+func newProblem(status int, code, title string, err error) *Problem {
+	return &Problem{
+		Type:   problemTypePrefix + code,
+		Title:  title,
+		Status: status,
+		Detail: err.Error(),
+		Code:   code,
+	}
+}
+
+// Err maps the problem back to its typed error, or returns the problem
+// itself if none matches.
+func (p *Problem) Err() error {
+	switch p.Code {
+	case CodeMergeFailed:
+		return &ErrMerge{
+			Stderr: p.Diagnostics,
+			Err:    errors.New(p.Detail),
+		}
+	case CodePathTainted:
+		return &ErrPathTainted{
+			Path: p.Path,
+		}
+	case CodeTooLargeContent:
+		return ErrTooLargeContent{
+			MaxSize: p.MaxSize,
+		}
+	case CodeTooManyMerges:
+		return ErrTooManyMerges
+	case CodeNotReady:
+		return errors.Wrap(ErrNotReady, p.Detail)
+	}
+	return p
+}
+
+var (
+	// ErrTooManyMerges is returned when the maximum number of concurrent
+	// merges is reached. Retry later.
+	ErrTooManyMerges = errors.New("too many merges in progress")
+
+	// ErrNotReady is returned when the webserver is not able to serve merges.
+	ErrNotReady = errors.New("not ready")
+)
+
+// ErrMerge is returned when the merge engine fails.
+type ErrMerge struct {
+	// Stderr of the merge engine.
+	Stderr string
+
+	Err error
+}
+
+func (err ErrMerge) Error() string {
+	if stderr := strings.TrimSpace(err.Stderr); stderr != "" {
+		return fmt.Sprintf("merge failed: %s: %s", err.Err, stderr)
+	}
+	return fmt.Sprintf("merge failed: %s", err.Err)
+}
+
+func (err ErrMerge) Unwrap() error {
+	return err.Err
+}
+
+var _ error = (*ErrMerge)(nil)
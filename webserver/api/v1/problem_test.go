@@ -0,0 +1,100 @@
+package apiv1_test
+
+import (
+	"net/http"
+	"testing"
+
+	apiv1 "github.com/ctfer-io/romeo/webserver/api/v1"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_U_Problem(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Err          error
+		ExpectStatus int
+		ExpectCode   string
+		// Check the problem maps back to the typed error
+		Check func(assert *assert.Assertions, err error)
+	}{
+		"internal": {
+			Err:          errors.New("something went wrong"),
+			ExpectStatus: http.StatusInternalServerError,
+			ExpectCode:   apiv1.CodeInternal,
+			Check: func(assert *assert.Assertions, err error) {
+				var p *apiv1.Problem
+				assert.ErrorAs(err, &p)
+			},
+		},
+		"merge-failed": {
+			Err: &apiv1.ErrMerge{
+				Stderr: "no applicable files found",
+				Err:    errors.New("exit status 1"),
+			},
+			ExpectStatus: http.StatusInternalServerError,
+			ExpectCode:   apiv1.CodeMergeFailed,
+			Check: func(assert *assert.Assertions, err error) {
+				var merr *apiv1.ErrMerge
+				if assert.ErrorAs(err, &merr) {
+					assert.Equal("no applicable files found", merr.Stderr)
+				}
+			},
+		},
+		"path-tainted": {
+			Err: &apiv1.ErrPathTainted{
+				Path: "../etc",
+			},
+			ExpectStatus: http.StatusBadRequest,
+			ExpectCode:   apiv1.CodePathTainted,
+			Check: func(assert *assert.Assertions, err error) {
+				var pterr *apiv1.ErrPathTainted
+				if assert.ErrorAs(err, &pterr) {
+					assert.Equal("../etc", pterr.Path)
+				}
+			},
+		},
+		"too-large-content": {
+			Err: errors.Wrap(apiv1.ErrTooLargeContent{
+				MaxSize: 42,
+			}, "decoding"),
+			ExpectStatus: http.StatusRequestEntityTooLarge,
+			ExpectCode:   apiv1.CodeTooLargeContent,
+			Check: func(assert *assert.Assertions, err error) {
+				var tlerr apiv1.ErrTooLargeContent
+				if assert.ErrorAs(err, &tlerr) {
+					assert.Equal(int64(42), tlerr.MaxSize)
+				}
+			},
+		},
+		"too-many-merges": {
+			Err:          apiv1.ErrTooManyMerges,
+			ExpectStatus: http.StatusServiceUnavailable,
+			ExpectCode:   apiv1.CodeTooManyMerges,
+			Check: func(assert *assert.Assertions, err error) {
+				assert.ErrorIs(err, apiv1.ErrTooManyMerges)
+			},
+		},
+		"not-ready": {
+			Err:          errors.Wrap(apiv1.ErrNotReady, "reading coverdir"),
+			ExpectStatus: http.StatusServiceUnavailable,
+			ExpectCode:   apiv1.CodeNotReady,
+			Check: func(assert *assert.Assertions, err error) {
+				assert.ErrorIs(err, apiv1.ErrNotReady)
+			},
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			assert := assert.New(t)
+
+			p := apiv1.NewProblem(tt.Err)
+			assert.Equal(tt.ExpectStatus, p.Status)
+			assert.Equal(tt.ExpectCode, p.Code)
+
+			tt.Check(assert, p.Err())
+		})
+	}
+}
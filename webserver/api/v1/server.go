@@ -0,0 +1,218 @@
+package apiv1
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ctfer-io/romeo/webserver/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Config of a v1 API [*Server].
+type Config struct {
+	// Coverdir is the directory containing the coverage data to merge.
+	Coverdir string
+
+	// Storage of the coverage data to merge, e.g. an S3-compatible bucket.
+	// Defaults to the Coverdir one.
+	Storage storage.Storage
+
+	// Logger to use. Defaults to a no-op logger.
+	Logger *zap.Logger
+
+	// MaxSize is the maximum size of the encoded merged coverages.
+	// Zero means no limit.
+	MaxSize int64
+
+	// MaxMerges is the maximum number of merges running concurrently.
+	// Zero means no limit.
+	MaxMerges int
+
+	// MaxUploadSize is the maximum size of an uploaded coverage file.
+	// Defaults to [DefaultMaxUploadSize].
+	MaxUploadSize int64
+
+	// BuildInfo of the webserver, served for clients to check compatibility.
+	BuildInfo BuildInfo
+
+	// BasePath the API is served under, e.g. "/romeo".
+	BasePath string
+}
+
+// Server serves the v1 API on top of a single coverage storage.
+// Many servers can coexist in a single process.
+type Server struct {
+	storage       storage.Storage
+	logger        *zap.Logger
+	maxSize       int64
+	maxUploadSize int64
+	merges        chan struct{}
+	buildInfo     BuildInfo
+	basePath      string
+
+	tmpMx    sync.Mutex
+	tmpDirs  map[string]struct{}
+	closed   bool
+	inflight sync.WaitGroup
+
+	engineOK atomic.Bool
+}
+
+// NewServer constructs a fresh [*Server].
+func NewServer(cfg Config) *Server {
+	s := &Server{
+		storage:       cfg.Storage,
+		logger:        cfg.Logger,
+		maxSize:       cfg.MaxSize,
+		maxUploadSize: cfg.MaxUploadSize,
+		buildInfo:     cfg.BuildInfo,
+		basePath:      cfg.BasePath,
+		tmpDirs:       map[string]struct{}{},
+	}
+	if s.storage == nil {
+		s.storage = storage.NewFilesystem(cfg.Coverdir)
+	}
+	if s.logger == nil {
+		s.logger = zap.NewNop()
+	}
+	if s.maxUploadSize <= 0 {
+		s.maxUploadSize = DefaultMaxUploadSize
+	}
+	if cfg.MaxMerges > 0 {
+		s.merges = make(chan struct{}, cfg.MaxMerges)
+	}
+	return s
+}
+
+// route of the v1 API, documented in its OpenAPI document.
+type route struct {
+	method      string
+	path        string
+	operationID string
+	summary     string
+	handler     gin.HandlerFunc
+	// body is the content type of the request body, if any
+	body string
+	// response is a value of the type served on success
+	response any
+}
+
+func (s *Server) routes() []route {
+	return []route{
+		{
+			method:      http.MethodGet,
+			path:        "/coverout",
+			operationID: "coverout",
+			summary:     "Merge the coverage data, zip and encode them base 64.",
+			handler:     s.Coverout,
+			response:    CoveroutResponse{},
+		}, {
+			method:      http.MethodGet,
+			path:        "/diagnostics",
+			operationID: "diagnostics",
+			summary:     "List the coverage storage files, their size and parse status.",
+			handler:     s.Diagnostics,
+			response:    DiagnosticsResponse{},
+		}, {
+			method:      http.MethodPut,
+			path:        "/coverages/:pod/:file",
+			operationID: "upload",
+			summary:     "Upload a coverage file of a pod, e.g. pushed by an instrumented application.",
+			handler:     s.Upload,
+			body:        "application/octet-stream",
+			response:    UploadResponse{},
+		}, {
+			method:      http.MethodGet,
+			path:        "/version",
+			operationID: "version",
+			summary:     "Get the webserver build information.",
+			handler:     s.Version,
+			response:    BuildInfo{},
+		}, {
+			method:      http.MethodGet,
+			path:        "/openapi.json",
+			operationID: "openapi",
+			summary:     "Get the OpenAPI 3 document of the v1 API.",
+			handler:     s.OpenAPI,
+			response:    map[string]any{},
+		},
+	}
+}
+
+// Register the v1 API routes on the router.
+func (s *Server) Register(r gin.IRouter) {
+	for _, rt := range s.routes() {
+		r.Handle(rt.method, rt.path, rt.handler)
+	}
+}
+
+// Close waits for the in-flight merges to return, then removes the
+// temporary directories of those that did not complete, e.g. when
+// interrupted by a shutdown. Merges are no longer served afterwards.
+// It should be called once the server does not serve requests anymore, as
+// the interrupted requests contexts are then done.
+func (s *Server) Close() error {
+	s.tmpMx.Lock()
+	s.closed = true
+	s.tmpMx.Unlock()
+
+	s.inflight.Wait()
+
+	s.tmpMx.Lock()
+	defer s.tmpMx.Unlock()
+
+	for tmpDir := range s.tmpDirs {
+		s.removeTmpDir(tmpDir)
+		delete(s.tmpDirs, tmpDir)
+	}
+	return nil
+}
+
+// begin tracks an in-flight merge, such that [*Server.Close] waits for it,
+// or answers the request and returns false if the server is closed.
+// The merge ends with [*Server.end].
+func (s *Server) begin(ctx *gin.Context) bool {
+	s.tmpMx.Lock()
+	defer s.tmpMx.Unlock()
+
+	if s.closed {
+		s.fail(ctx, errors.Wrap(ErrNotReady, "server closed"))
+		return false
+	}
+	s.inflight.Add(1)
+	return true
+}
+
+func (s *Server) end() {
+	s.inflight.Done()
+}
+
+// acquire a merge slot, or answers the request and returns false
+// if none is available.
+func (s *Server) acquire(ctx *gin.Context) bool {
+	if s.merges == nil {
+		return true
+	}
+	select {
+	case s.merges <- struct{}{}:
+		return true
+	default:
+		ctx.Header("Retry-After", "1")
+		s.fail(ctx, ErrTooManyMerges)
+		return false
+	}
+}
+
+// fail answers the request with the problem describing the error.
+func (s *Server) fail(ctx *gin.Context, err error) {
+	WriteProblem(ctx, s.logger, NewProblem(err))
+}
+
+func (s *Server) release() {
+	if s.merges != nil {
+		<-s.merges
+	}
+}
@@ -0,0 +1,67 @@
+package apiv1
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxUploadSize is the default maximum size of an uploaded coverage
+// file.
+const DefaultMaxUploadSize = 64 << 20
+
+// podRegex matches the names of the pods, which can't escape the storage.
+var podRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+
+// UploadResponse is the response to a PUT /coverages/{pod}/{file} call
+type UploadResponse struct {
+	// Name of the stored file, relative to the coverage storage root.
+	Name string `json:"name"`
+
+	// Size of the stored file in bytes.
+	Size int64 `json:"size"`
+}
+
+// Upload stores a coverage file of a pod, such that instrumented
+// applications could push their coverages rather than writing them on a
+// shared volume.
+func (s *Server) Upload(ctx *gin.Context) {
+	// Only accept coverage files, in the pod subdirectory
+	pod, file := ctx.Param("pod"), ctx.Param("file")
+	name := path.Join(pod, file)
+	if !podRegex.MatchString(pod) || !(metaRegex.MatchString(file) || countersRegex.MatchString(file)) {
+		s.fail(ctx, &ErrPathTainted{Path: name})
+		return
+	}
+
+	// Read the whole file before storing it, such that a too large one
+	// is not partially stored
+	if ctx.Request.ContentLength > s.maxUploadSize {
+		s.fail(ctx, ErrTooLargeContent{MaxSize: s.maxUploadSize})
+		return
+	}
+	b, err := io.ReadAll(io.LimitReader(ctx.Request.Body, s.maxUploadSize+1))
+	if err != nil {
+		s.fail(ctx, errors.Wrap(err, "reading upload"))
+		return
+	}
+	if int64(len(b)) > s.maxUploadSize {
+		s.fail(ctx, ErrTooLargeContent{MaxSize: s.maxUploadSize})
+		return
+	}
+
+	if err := s.storage.Put(ctx.Request.Context(), name, bytes.NewReader(b), int64(len(b))); err != nil {
+		s.fail(ctx, errors.Wrapf(err, "storing %s", name))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, UploadResponse{
+		Name: name,
+		Size: int64(len(b)),
+	})
+}
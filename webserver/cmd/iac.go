@@ -0,0 +1,495 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ctfer-io/romeo/sdk"
+	"github.com/ctfer-io/romeo/sdk/programs"
+	"github.com/ctfer-io/romeo/webserver/iac"
+	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/urfave/cli/v3"
+)
+
+// stackFlags are the flags to select the stack of a program with.
+func stackFlags(name string) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "stack-name",
+			Usage: "Pulumi stack name. Useful when deploying many of them within the same context.",
+			Value: name,
+		},
+		&cli.StringFlag{
+			Name:    "backend",
+			Usage:   "Pulumi backend URL to store the state into.",
+			Sources: cli.EnvVars("PULUMI_BACKEND_URL"),
+			Value:   iac.DefaultBackend,
+		},
+	}
+}
+
+// environmentFlags are the flags configuring a Romeo environment.
+func environmentFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "namespace",
+			Usage: "Namespace in which to deploy. If not defined, creates one.",
+		},
+		&cli.BoolFlag{
+			Name:  "harden",
+			Usage: "Harden the created namespace, and grant access to the Romeo webserver.",
+		},
+		&cli.StringFlag{
+			Name:  "allow-cidrs",
+			Usage: "Comma-separated CIDRs allowed to reach the Romeo webserver when hardened. Defaults to all IP addresses if no allow flag is set.",
+		},
+		&cli.StringFlag{
+			Name:  "allow-namespace-selector",
+			Usage: "Comma-separated key=value labels of the namespaces whose pods are allowed to reach the Romeo webserver when hardened.",
+		},
+		&cli.StringFlag{
+			Name:  "allow-pod-selector",
+			Usage: "Comma-separated key=value labels of the pods allowed to reach the Romeo webserver when hardened.",
+		},
+		&cli.StringFlag{
+			Name:  "allow-apps-cidrs",
+			Usage: "Comma-separated CIDRs of the in-cluster applications allowed to reach the Romeo webserver when hardened.",
+		},
+		&cli.StringFlag{
+			Name:  "allow-apps-namespace-selector",
+			Usage: "Comma-separated key=value labels of the namespaces whose applications are allowed to reach the Romeo webserver when hardened.",
+		},
+		&cli.StringFlag{
+			Name:  "allow-apps-pod-selector",
+			Usage: "Comma-separated key=value labels of the in-cluster applications pods allowed to reach the Romeo webserver when hardened.",
+		},
+		&cli.StringFlag{
+			Name:  "pod-security-level",
+			Usage: "Pod Security level the created namespace enforces, either privileged, baseline or restricted.",
+			Value: "baseline",
+		},
+		&cli.BoolFlag{
+			Name:  "restricted",
+			Usage: "Run the Romeo webserver as a non-root user, with a read-only root filesystem, all capabilities dropped and the RuntimeDefault seccomp profile.",
+		},
+		&cli.StringFlag{
+			Name:  "cpu-request",
+			Usage: "CPU request of the Romeo webserver container (e.g. 100m).",
+		},
+		&cli.StringFlag{
+			Name:  "memory-request",
+			Usage: "Memory request of the Romeo webserver container (e.g. 128Mi).",
+		},
+		&cli.StringFlag{
+			Name:  "cpu-limit",
+			Usage: "CPU limit of the Romeo webserver container.",
+		},
+		&cli.StringFlag{
+			Name:  "memory-limit",
+			Usage: "Memory limit of the Romeo webserver container.",
+		},
+		&cli.StringFlag{
+			Name:  "tag",
+			Usage: "Romeo webserver Docker image tag.",
+			Value: "latest",
+		},
+		&cli.StringFlag{
+			Name:  "storage-class-name",
+			Usage: "StorageClass name of the PersistentVolumeClaim. Defaults to the cluster default StorageClass.",
+		},
+		&cli.StringFlag{
+			Name:  "storage-size",
+			Usage: "Storage size of the PersistentVolumeClaim.",
+			Value: "50M",
+		},
+		&cli.StringFlag{
+			Name:  "claim-name",
+			Usage: "If defined, turns on the Romeo webserver coverage export in the given PersistentVolumeClaim name.",
+		},
+		&cli.StringFlag{
+			Name:  "pvc-access-mode",
+			Usage: "Access mode of the PersistentVolumeClaim. Defaults to the most workable one for the StorageClass provisioner.",
+		},
+		&cli.BoolFlag{
+			Name:  "co-locate",
+			Usage: "Export the affinity for the coverage-monitored pods to run on the same node as the Romeo webserver, e.g. to share a ReadWriteOnce volume.",
+		},
+		&cli.StringFlag{
+			Name:  "registry",
+			Usage: "OCI registry to download the Romeo images from.",
+		},
+		&cli.StringFlag{
+			Name:  "digest",
+			Usage: "Digest (sha256:...) to pin the Romeo image to.",
+		},
+		&cli.StringFlag{
+			Name:  "image-pull-secrets",
+			Usage: "Comma-separated names of existing Secrets to pull the Romeo images with.",
+		},
+		&cli.StringFlag{
+			Name:  "registry-username",
+			Usage: "Username to pull the Romeo images from the registry with.",
+		},
+		&cli.StringFlag{
+			Name:    "registry-password",
+			Usage:   "Password (or token) to pull the Romeo images from the registry with.",
+			Sources: cli.EnvVars("REGISTRY_PASSWORD"),
+		},
+		&cli.StringFlag{
+			Name:  "expose",
+			Usage: "How to expose the Romeo webserver, either ClusterIP, NodePort, LoadBalancer, Ingress or Gateway.",
+			Value: "NodePort",
+		},
+		&cli.StringFlag{
+			Name:  "node-address",
+			Usage: "Address the Kubernetes nodes are reachable at, used to build the URL when exposed through a NodePort.",
+			Value: "localhost",
+		},
+		&cli.StringFlag{
+			Name:  "ingress-host",
+			Usage: "Host to serve Romeo on, when exposed through an Ingress.",
+		},
+		&cli.StringFlag{
+			Name:  "ingress-tls-secret-name",
+			Usage: "Secret containing the TLS certificate of the Ingress host. If defined, the URL is served over HTTPS.",
+		},
+		&cli.StringFlag{
+			Name:  "ingress-class-name",
+			Usage: "IngressClass name. Defaults to the cluster default one.",
+		},
+		&cli.StringFlag{
+			Name:  "gateway-name",
+			Usage: "Name of an existing Gateway to attach Romeo to through an HTTPRoute, when exposed through a Gateway.",
+		},
+		&cli.StringFlag{
+			Name:  "gateway-namespace",
+			Usage: "Namespace of the Gateway. Defaults to the environment namespace.",
+		},
+		&cli.StringFlag{
+			Name:  "gateway-section-name",
+			Usage: "Gateway listener to attach to. Defaults to all listeners.",
+		},
+		&cli.StringFlag{
+			Name:  "gateway-hostname",
+			Usage: "Hostname the HTTPRoute matches requests on.",
+		},
+		&cli.StringFlag{
+			Name:  "gateway-path-prefix",
+			Usage: "Path prefix the HTTPRoute matches requests on, and the webserver serves under.",
+		},
+		&cli.StringFlag{
+			Name:  "gateway-address",
+			Usage: "Address the Gateway is reachable at (e.g. https://gw.example.com), used to build the URL.",
+		},
+		&cli.StringFlag{
+			Name:  "preserve-sink",
+			Usage: "Sink to write the coverages to when the environment is destroyed, either a file path or an S3-compatible object (s3://bucket/key).",
+		},
+		&cli.StringFlag{
+			Name:  "preserve-claim-name",
+			Usage: "PersistentVolumeClaim to mount at /etc/preserve for the file sinks to outlive the environment. Defaults the sink to /etc/preserve/coverout.zip.",
+		},
+		&cli.StringFlag{
+			Name:  "preserve-s3-endpoint",
+			Usage: "S3-compatible API endpoint of the sink (e.g. minio.minio:9000).",
+		},
+		&cli.StringFlag{
+			Name:  "preserve-s3-region",
+			Usage: "Region of the sink S3 bucket, if required by the endpoint.",
+		},
+		&cli.BoolFlag{
+			Name:  "preserve-s3-insecure",
+			Usage: "Reach the sink S3-compatible API over plain HTTP.",
+		},
+		&cli.StringFlag{
+			Name:  "preserve-secret-name",
+			Usage: "Secret exposed as environment variables to write to the sink, e.g. AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.",
+		},
+		&cli.StringFlag{
+			Name:  "preserve-allow-cidrs",
+			Usage: "Comma-separated CIDRs of the sink the Romeo webserver is allowed to reach when hardened, e.g. an in-cluster S3-compatible API.",
+		},
+		&cli.StringFlag{
+			Name:  "preserve-allow-namespace-selector",
+			Usage: "Comma-separated key=value labels of the namespaces whose pods serve the sink the Romeo webserver is allowed to reach when hardened.",
+		},
+		&cli.StringFlag{
+			Name:  "preserve-allow-pod-selector",
+			Usage: "Comma-separated key=value labels of the pods serving the sink the Romeo webserver is allowed to reach when hardened.",
+		},
+		&cli.StringFlag{
+			Name:  "instance",
+			Usage: "Name of the environment resources (PersistentVolumeClaim, Deployment, Service, ...). If not defined, they are named after random strings.",
+		},
+		&cli.StringFlag{
+			Name:    "ttl",
+			Usage:   "Duration (e.g. 24h) after which the environment is considered orphaned, thus garbage collected by `romeo gc`. Set it to 0 for it to never expire.",
+			Sources: cli.EnvVars("TTL"),
+			Value:   sdk.DefaultTTL,
+		},
+	}
+}
+
+var envCommand = &cli.Command{
+	Name:  "env",
+	Usage: "Manage a Romeo environment through the Pulumi Automation API (requires the Pulumi CLI).",
+	Commands: []*cli.Command{
+		{
+			Name:  "up",
+			Usage: "Deploy or update a Romeo environment, and print its outputs.",
+			Flags: append(append(stackFlags("env"), environmentFlags()...), []cli.Flag{
+				&cli.StringFlag{
+					Name:  "kubeconfig",
+					Usage: "Kubeconfig (path or content) to deploy with. Defaults to the Kubernetes loading rules (e.g. KUBECONFIG).",
+				},
+				&cli.BoolFlag{
+					Name:  "webhook",
+					Usage: "Deploy the mutating webhook that instruments the pods labelled with romeo.ctfer.io/instrument=true in the namespace.",
+				},
+			}...),
+			Action: up(iac.Environment),
+		},
+		{
+			Name:   "down",
+			Usage:  "Destroy a Romeo environment.",
+			Flags:  stackFlags("env"),
+			Action: down(iac.Environment),
+		},
+		{
+			Name:   "status",
+			Usage:  "Print the outputs of a Romeo environment.",
+			Flags:  stackFlags("env"),
+			Action: status(iac.Environment),
+		},
+		{
+			Name:  "render",
+			Usage: "Render the Kubernetes manifests of a Romeo environment, e.g. to kubectl apply them or vendor them.",
+			Flags: append(environmentFlags(), &cli.StringFlag{
+				Name:  "directory",
+				Usage: "Directory to write the manifests into, one file per manifest along with a kustomization.yaml. Defaults to stdout.",
+			}),
+			Action: render,
+		},
+	},
+}
+
+var installCommand = &cli.Command{
+	Name:  "install",
+	Usage: "Manage a Romeo install through the Pulumi Automation API (requires the Pulumi CLI).",
+	Commands: []*cli.Command{
+		{
+			Name:  "up",
+			Usage: "Deploy or update a Romeo install, and print its outputs.",
+			Flags: append(stackFlags("install"), []cli.Flag{
+				&cli.StringFlag{
+					Name:  "kubeconfig",
+					Usage: "Kubeconfig (path or content) to deploy with. Defaults to the Kubernetes loading rules (e.g. KUBECONFIG).",
+				},
+				&cli.StringFlag{
+					Name:  "namespace",
+					Usage: "Namespace to install Romeo into. If not defined, creates one.",
+				},
+				&cli.StringFlag{
+					Name:  "api-server",
+					Usage: "Kubernetes API server URL to write in the generated kubeconfig. Inferred from the kubeconfig whenever possible.",
+				},
+				&cli.BoolFlag{
+					Name:  "harden",
+					Usage: "Harden the created namespace.",
+				},
+				&cli.BoolFlag{
+					Name:  "webhook",
+					Usage: "Grant to deploy the Romeo webhook, i.e. to manage the cluster-wide MutatingWebhookConfigurations.",
+				},
+				&cli.StringFlag{
+					Name:  "gc-schedule",
+					Usage: "If defined, deploys a CronJob garbage collecting the orphaned Romeo environments of the namespace on this schedule (e.g. @hourly).",
+				},
+				&cli.StringFlag{
+					Name:  "tag",
+					Usage: "Romeo Docker image tag of the garbage collection CronJob.",
+					Value: "latest",
+				},
+				&cli.StringFlag{
+					Name:  "registry",
+					Usage: "OCI registry to download the Romeo images from.",
+				},
+				&cli.StringFlag{
+					Name:  "digest",
+					Usage: "Digest (sha256:...) to pin the Romeo image of the garbage collection CronJob to.",
+				},
+				&cli.StringFlag{
+					Name:  "image-pull-secrets",
+					Usage: "Comma-separated names of existing Secrets, in the namespace, to pull the Romeo images with.",
+				},
+			}...),
+			Action: up(iac.Install),
+		},
+		{
+			Name:   "down",
+			Usage:  "Destroy a Romeo install.",
+			Flags:  stackFlags("install"),
+			Action: down(iac.Install),
+		},
+	},
+}
+
+func up(prog iac.Program) cli.ActionFunc {
+	return func(ctx context.Context, cmd *cli.Command) error {
+		config, err := stackConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		outputs, err := iac.Up(ctx, prog, stackOptions(cmd), config)
+		if err != nil {
+			return err
+		}
+		return printOutputs(outputs)
+	}
+}
+
+// stackConfig returns the stack configuration of the command flags, but the
+// stack ones and the help one urfave/cli appends.
+func stackConfig(cmd *cli.Command) (iac.Config, error) {
+	config := iac.Config{}
+	for _, flag := range cmd.Flags {
+		name := flag.Names()[0]
+		switch name {
+		case "stack-name", "backend", "help":
+			continue
+		case "kubeconfig":
+			kubeconfig, err := resolveKubeconfig(cmd.String(name))
+			if err != nil {
+				return nil, err
+			}
+			config[name] = auto.ConfigValue{Value: kubeconfig, Secret: true}
+		case "registry-password":
+			config[name] = auto.ConfigValue{Value: cmd.String(name), Secret: true}
+		default:
+			config[name] = auto.ConfigValue{Value: fmt.Sprint(cmd.Value(name))}
+		}
+	}
+	return config, nil
+}
+
+func render(_ context.Context, cmd *cli.Command) error {
+	cfg := &programs.EnvironmentConfig{
+		Namespace:            cmd.String("namespace"),
+		Harden:               cmd.Bool("harden"),
+		PodSecurityLevel:     cmd.String("pod-security-level"),
+		Restricted:           cmd.Bool("restricted"),
+		Tag:                  cmd.String("tag"),
+		StorageClassName:     cmd.String("storage-class-name"),
+		StorageSize:          cmd.String("storage-size"),
+		ClaimName:            cmd.String("claim-name"),
+		PVCAccessMode:        cmd.String("pvc-access-mode"),
+		CoLocate:             cmd.Bool("co-locate"),
+		Registry:             cmd.String("registry"),
+		Digest:               cmd.String("digest"),
+		Expose:               cmd.String("expose"),
+		NodeAddress:          cmd.String("node-address"),
+		Instance:             cmd.String("instance"),
+		TTL:                  cmd.String("ttl"),
+		IngressHost:          cmd.String("ingress-host"),
+		IngressTLSSecretName: cmd.String("ingress-tls-secret-name"),
+		IngressClassName:     cmd.String("ingress-class-name"),
+		GatewayName:          cmd.String("gateway-name"),
+		GatewayNamespace:     cmd.String("gateway-namespace"),
+		GatewaySectionName:   cmd.String("gateway-section-name"),
+		GatewayHostname:      cmd.String("gateway-hostname"),
+		GatewayPathPrefix:    cmd.String("gateway-path-prefix"),
+		GatewayAddress:       cmd.String("gateway-address"),
+		PreserveSink:         cmd.String("preserve-sink"),
+		PreserveClaimName:    cmd.String("preserve-claim-name"),
+		PreserveS3Endpoint:   cmd.String("preserve-s3-endpoint"),
+		PreserveS3Region:     cmd.String("preserve-s3-region"),
+		PreserveS3Insecure:   cmd.Bool("preserve-s3-insecure"),
+		PreserveSecretName:   cmd.String("preserve-secret-name"),
+		ImagePullSecrets:     cmd.String("image-pull-secrets"),
+		RegistryUsername:     cmd.String("registry-username"),
+		RegistryPassword:     cmd.String("registry-password"),
+		CPURequest:           cmd.String("cpu-request"),
+		MemoryRequest:        cmd.String("memory-request"),
+		CPULimit:             cmd.String("cpu-limit"),
+		MemoryLimit:          cmd.String("memory-limit"),
+
+		AllowCIDRs:                 cmd.String("allow-cidrs"),
+		AllowNamespaceSelector:     cmd.String("allow-namespace-selector"),
+		AllowPodSelector:           cmd.String("allow-pod-selector"),
+		AllowAppsCIDRs:             cmd.String("allow-apps-cidrs"),
+		AllowAppsNamespaceSelector: cmd.String("allow-apps-namespace-selector"),
+		AllowAppsPodSelector:       cmd.String("allow-apps-pod-selector"),
+
+		PreserveAllowCIDRs:             cmd.String("preserve-allow-cidrs"),
+		PreserveAllowNamespaceSelector: cmd.String("preserve-allow-namespace-selector"),
+		PreserveAllowPodSelector:       cmd.String("preserve-allow-pod-selector"),
+	}
+
+	manifests, err := iac.RenderEnvironment(cfg.Args())
+	if err != nil {
+		return err
+	}
+	if dir := cmd.String("directory"); dir != "" {
+		return iac.WriteKustomization(dir, manifests)
+	}
+	return iac.WriteManifests(os.Stdout, manifests)
+}
+
+func down(prog iac.Program) cli.ActionFunc {
+	return func(ctx context.Context, cmd *cli.Command) error {
+		return iac.Down(ctx, prog, stackOptions(cmd))
+	}
+}
+
+func status(prog iac.Program) cli.ActionFunc {
+	return func(ctx context.Context, cmd *cli.Command) error {
+		outputs, err := iac.Status(ctx, prog, stackOptions(cmd))
+		if err != nil {
+			return err
+		}
+		return printOutputs(outputs)
+	}
+}
+
+func stackOptions(cmd *cli.Command) *iac.StackOptions {
+	// Progress goes to stderr, such that outputs can be piped
+	return &iac.StackOptions{
+		Name:     cmd.String("stack-name"),
+		Backend:  cmd.String("backend"),
+		Progress: os.Stderr,
+	}
+}
+
+func printOutputs(outputs map[string]any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(outputs)
+}
+
+// resolveKubeconfig returns the content of the kubeconfig if it is a path to
+// a file, else returns it as is.
+func resolveKubeconfig(in string) (string, error) {
+	path := in
+	if rest, ok := strings.CutPrefix(path, "~"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", errors.Wrap(err, "getting home directory")
+		}
+		path = filepath.Join(home, rest)
+	}
+
+	if fi, err := os.Stat(path); err != nil || fi.IsDir() {
+		return in, nil
+	}
+	b, err := os.ReadFile(path) //nolint:gosec // G304 -- the kubeconfig path is user-provided on purpose
+	if err != nil {
+		return "", errors.Wrap(err, "reading kubeconfig")
+	}
+	return string(b), nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ctfer-io/romeo/webserver/iac"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func Test_U_StackConfig(t *testing.T) {
+	t.Parallel()
+
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, os.WriteFile(kubeconfig, []byte("apiVersion: v1\nkind: Config\n"), 0o600))
+
+	var tests = map[string]struct {
+		Args         []string
+		ExpectConfig iac.Config
+	}{
+		"defaults": {
+			Args: []string{"up"},
+			ExpectConfig: iac.Config{
+				"namespace":  {Value: ""},
+				"harden":     {Value: "false"},
+				"kubeconfig": {Value: "", Secret: true},
+				"tag":        {Value: "latest"},
+				"ttl":        {Value: "24h"},
+			},
+		},
+		"set": {
+			Args: []string{"up", "--stack-name", "other", "--namespace", "romeo", "--harden", "--kubeconfig", kubeconfig, "--tag", "v1", "--ttl", "1h"},
+			ExpectConfig: iac.Config{
+				"namespace":  {Value: "romeo"},
+				"harden":     {Value: "true"},
+				"kubeconfig": {Value: "apiVersion: v1\nkind: Config\n", Secret: true},
+				"tag":        {Value: "v1"},
+				"ttl":        {Value: "1h"},
+			},
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			assert := assert.New(t)
+			require := require.New(t)
+
+			var config iac.Config
+			cmd := &cli.Command{
+				Name: "up",
+				Flags: append(append(stackFlags("env"), environmentFlags()...), &cli.StringFlag{
+					Name: "kubeconfig",
+				}),
+				Action: func(_ context.Context, cmd *cli.Command) (err error) {
+					config, err = stackConfig(cmd)
+					return
+				},
+			}
+			require.NoError(cmd.Run(context.Background(), tt.Args))
+
+			// Neither the stack flags nor the help one are configuration
+			for key, value := range tt.ExpectConfig {
+				assert.Equal(value, config[key], key)
+			}
+			for _, key := range []string{"stack-name", "backend", "help"} {
+				assert.NotContains(config, key)
+			}
+		})
+	}
+}
+
+func Test_U_ResolveKubeconfig(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "kubeconfig")
+	require.NoError(t, os.WriteFile(path, []byte("apiVersion: v1\nkind: Config\n"), 0o600))
+
+	var tests = map[string]struct {
+		Input  string
+		Expect string
+	}{
+		"path": {
+			Input:  path,
+			Expect: "apiVersion: v1\nkind: Config\n",
+		},
+		"content": {
+			Input:  "apiVersion: v1\nkind: Config\n",
+			Expect: "apiVersion: v1\nkind: Config\n",
+		},
+		"empty": {
+			Input:  "",
+			Expect: "",
+		},
+		"directory": {
+			Input:  dir,
+			Expect: dir,
+		},
+		"missing": {
+			Input:  filepath.Join(dir, "missing"),
+			Expect: filepath.Join(dir, "missing"),
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			assert := assert.New(t)
+
+			kubeconfig, err := resolveKubeconfig(tt.Input)
+			assert.NoError(err)
+			assert.Equal(tt.Expect, kubeconfig)
+		})
+	}
+}
+
+func Test_U_Render(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Args      []string
+		ExpectTTL string
+	}{
+		"default-ttl": {
+			Args:      []string{"render", "--instance", "demo"},
+			ExpectTTL: "24h",
+		},
+		"ttl": {
+			Args:      []string{"render", "--instance", "demo", "--ttl", "1h"},
+			ExpectTTL: "1h",
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			assert := assert.New(t)
+			require := require.New(t)
+
+			dir := t.TempDir()
+			cmd := &cli.Command{
+				Name: "render",
+				Flags: append(environmentFlags(), &cli.StringFlag{
+					Name: "directory",
+				}),
+				Action: render,
+			}
+			require.NoError(cmd.Run(context.Background(), append(tt.Args, "--directory", dir)))
+
+			// The TTL annotation is what `romeo gc` keys on
+			for _, file := range []string{"deployment-demo.yaml", "persistentvolumeclaim-demo.yaml", "service-demo.yaml"} {
+				b, err := os.ReadFile(filepath.Join(dir, file))
+				require.NoError(err)
+				assert.Contains(string(b), "romeo.ctfer.io/ttl: "+tt.ExpectTTL+"\n", file)
+			}
+		})
+	}
+}
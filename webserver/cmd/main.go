@@ -2,22 +2,30 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/mail"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/ctfer-io/romeo/webserver"
 	apiv1 "github.com/ctfer-io/romeo/webserver/api/v1"
-	ginzap "github.com/gin-contrib/zap"
+	"github.com/ctfer-io/romeo/webserver/gc"
+	"github.com/ctfer-io/romeo/webserver/instrument"
+	"github.com/ctfer-io/romeo/webserver/operator"
+	"github.com/ctfer-io/romeo/webserver/sink"
+	"github.com/ctfer-io/romeo/webserver/storage"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
 	"github.com/urfave/cli/v3"
 	"go.uber.org/zap"
+	"k8s.io/client-go/metadata"
 )
 
 var (
@@ -35,15 +43,61 @@ func main() {
 			cli.HelpFlag,
 			cli.VersionFlag,
 			&cli.StringFlag{
-				Name:        "coverdir",
-				Sources:     cli.EnvVars("COVERDIR"),
-				Destination: &apiv1.Coverdir,
+				Name:    "coverdir",
+				Sources: cli.EnvVars("COVERDIR"),
+			},
+			&cli.StringFlag{
+				Name:    "storage",
+				Usage:   "Storage of the coverage data, either a directory or an S3-compatible prefix (s3://bucket/prefix). Defaults to the coverdir.",
+				Sources: cli.EnvVars("STORAGE"),
+			},
+			&cli.StringFlag{
+				Name:    "storage-s3-endpoint",
+				Usage:   "S3-compatible API endpoint of the storage (e.g. minio.minio:9000). Credentials are read from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.",
+				Sources: cli.EnvVars("STORAGE_S3_ENDPOINT"),
+			},
+			&cli.StringFlag{
+				Name:    "storage-s3-region",
+				Usage:   "Region of the storage S3 bucket, if required by the endpoint.",
+				Sources: cli.EnvVars("STORAGE_S3_REGION"),
+			},
+			&cli.BoolFlag{
+				Name:    "storage-s3-insecure",
+				Usage:   "Reach the storage S3-compatible API over plain HTTP.",
+				Sources: cli.EnvVars("STORAGE_S3_INSECURE"),
 			},
 			&cli.IntFlag{
 				Name:    "port",
 				Sources: cli.EnvVars("PORT"),
 				Value:   8080,
 			},
+			&cli.StringFlag{
+				Name:    "base-path",
+				Usage:   "Path prefix to serve all routes under, e.g. when exposed behind a Gateway.",
+				Sources: cli.EnvVars("BASE_PATH"),
+			},
+			&cli.DurationFlag{
+				Name:    "grace-period",
+				Usage:   "Duration to drain in-flight requests on termination, before interrupting them.",
+				Sources: cli.EnvVars("GRACE_PERIOD"),
+				Value:   25 * time.Second, // below Kubernetes default terminationGracePeriodSeconds
+			},
+			&cli.Int64Flag{
+				Name:    "max-size",
+				Usage:   "Maximum size (in bytes) of the encoded merged coverages, 0 for no limit.",
+				Sources: cli.EnvVars("MAX_SIZE"),
+			},
+			&cli.IntFlag{
+				Name:    "max-merges",
+				Usage:   "Maximum number of concurrent merges, 0 for no limit.",
+				Sources: cli.EnvVars("MAX_MERGES"),
+			},
+			&cli.Int64Flag{
+				Name:    "max-upload-size",
+				Usage:   "Maximum size (in bytes) of a coverage file uploaded by an instrumented application.",
+				Sources: cli.EnvVars("MAX_UPLOAD_SIZE"),
+				Value:   apiv1.DefaultMaxUploadSize,
+			},
 		},
 		Commands: []*cli.Command{
 			{
@@ -51,10 +105,29 @@ func main() {
 				Usage: "Download the Romeo data from an environment, after running your tests.",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
-						Name:     "server",
-						Usage:    "Server URL to reach out the Romeo environment.",
-						Required: true,
-						Sources:  cli.EnvVars("SERVER"),
+						Name:    "server",
+						Usage:   "Server URL to reach out the Romeo environment. If not set, port-forwards through the Kubernetes API server.",
+						Sources: cli.EnvVars("SERVER"),
+					},
+					&cli.StringFlag{
+						Name:  "kubeconfig",
+						Usage: "Kubeconfig (path or content) to port-forward with. Defaults to the Kubernetes loading rules (e.g. KUBECONFIG).",
+					},
+					&cli.StringFlag{
+						Name:    "namespace",
+						Usage:   "Namespace the Romeo environment lays into. Defaults to the kubeconfig context one.",
+						Sources: cli.EnvVars("NAMESPACE"),
+					},
+					&cli.StringFlag{
+						Name:    "selector",
+						Usage:   "Label selector to find the Romeo Service with.",
+						Sources: cli.EnvVars("SELECTOR"),
+						Value:   webserver.DefaultSelector,
+					},
+					&cli.StringFlag{
+						Name:    "instance",
+						Usage:   "Instance of the Romeo environment, required when many lay in the namespace.",
+						Sources: cli.EnvVars("INSTANCE"),
 					},
 					&cli.StringFlag{
 						Name:     "directory",
@@ -65,6 +138,157 @@ func main() {
 				},
 				Action: download,
 			},
+			{
+				Name:  "preserve",
+				Usage: "Merge the coverages of an environment and write them to a sink, e.g. before it is destroyed.",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "server",
+						Usage:    "Server URL to reach out the Romeo environment.",
+						Required: true,
+						Sources:  cli.EnvVars("SERVER"),
+					},
+					&cli.StringFlag{
+						Name:     "sink",
+						Usage:    "Sink to write the coverages zip archive to, either a file path (e.g. on another PersistentVolumeClaim) or an S3-compatible object (s3://bucket/key).",
+						Required: true,
+						Sources:  cli.EnvVars("SINK"),
+					},
+					&cli.StringFlag{
+						Name:    "s3-endpoint",
+						Usage:   "S3-compatible API endpoint (e.g. minio.minio:9000). Credentials are read from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.",
+						Sources: cli.EnvVars("S3_ENDPOINT"),
+					},
+					&cli.StringFlag{
+						Name:    "s3-region",
+						Usage:   "Region of the S3 bucket, if required by the endpoint.",
+						Sources: cli.EnvVars("S3_REGION"),
+					},
+					&cli.BoolFlag{
+						Name:    "s3-insecure",
+						Usage:   "Reach the S3-compatible API over plain HTTP.",
+						Sources: cli.EnvVars("S3_INSECURE"),
+					},
+				},
+				Action: preserve,
+			},
+			{
+				Name:  "webhook",
+				Usage: "Serve the mutating admission webhook instrumenting the labelled pods for coverage.",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:    "port",
+						Sources: cli.EnvVars("PORT"),
+						Value:   9443,
+					},
+					&cli.StringFlag{
+						Name:     "tls-cert",
+						Usage:    "Path to the TLS certificate to serve the webhook with.",
+						Required: true,
+						Sources:  cli.EnvVars("TLS_CERT"),
+					},
+					&cli.StringFlag{
+						Name:     "tls-key",
+						Usage:    "Path to the TLS private key to serve the webhook with.",
+						Required: true,
+						Sources:  cli.EnvVars("TLS_KEY"),
+					},
+					&cli.StringFlag{
+						Name:     "claim-name",
+						Usage:    "Claim name of the Romeo environment to mount in the instrumented pods.",
+						Required: true,
+						Sources:  cli.EnvVars("CLAIM_NAME"),
+					},
+					&cli.StringFlag{
+						Name:    "mount-path",
+						Usage:   "Path to mount the claim at in the instrumented containers.",
+						Sources: cli.EnvVars("MOUNT_PATH"),
+						Value:   instrument.DefaultMountPath,
+					},
+				},
+				Action: webhook,
+			},
+			{
+				Name:      "instrument",
+				Usage:     "Instrument the workloads of Kubernetes manifests for coverage, and write them to stdout.",
+				ArgsUsage: "[files...] (defaults to stdin)",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "claim-name",
+						Usage:    "Claim name of the Romeo environment to mount in the instrumented workloads.",
+						Required: true,
+						Sources:  cli.EnvVars("CLAIM_NAME"),
+					},
+					&cli.StringFlag{
+						Name:    "mount-path",
+						Usage:   "Path to mount the claim at in the instrumented containers.",
+						Sources: cli.EnvVars("MOUNT_PATH"),
+						Value:   instrument.DefaultMountPath,
+					},
+					&cli.StringFlag{
+						Name:    "selector",
+						Usage:   "Label selector of the Deployments, StatefulSets, Jobs and CronJobs to instrument. Defaults to all of them.",
+						Sources: cli.EnvVars("SELECTOR"),
+					},
+				},
+				Action: instrumentManifests,
+			},
+			{
+				Name:  "gc",
+				Usage: "Garbage collect the orphaned Romeo environments, i.e. older than the TTL they are annotated with.",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "kubeconfig",
+						Usage: "Kubeconfig (path or content) to collect with. Defaults to the Kubernetes loading rules (e.g. KUBECONFIG), then to the in-cluster one.",
+					},
+					&cli.StringFlag{
+						Name:    "namespace",
+						Usage:   "Namespace to collect the environments in. Defaults to all namespaces, including the ones created by the environments.",
+						Sources: cli.EnvVars("NAMESPACE"),
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Only report the expired resources, without deleting them.",
+					},
+				},
+				Action: collect,
+			},
+			envCommand,
+			installCommand,
+			{
+				Name:  "operator",
+				Usage: "Reconcile the CoverageEnvironment and CoverageSnapshot custom resources, from within the cluster.",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "health-probe-address",
+						Usage:   "Address to serve the /healthz and /readyz probes on.",
+						Sources: cli.EnvVars("HEALTH_PROBE_ADDRESS"),
+						Value:   ":8081",
+					},
+					&cli.StringFlag{
+						Name:    "metrics-address",
+						Usage:   "Address to serve the Prometheus metrics on, \"0\" to disable them.",
+						Sources: cli.EnvVars("METRICS_ADDRESS"),
+						Value:   "0",
+					},
+					&cli.BoolFlag{
+						Name:    "leader-elect",
+						Usage:   "Elect a leader among the operator replicas, such that a single one reconciles at a time.",
+						Sources: cli.EnvVars("LEADER_ELECT"),
+					},
+					&cli.StringFlag{
+						Name:    "leader-election-namespace",
+						Usage:   "Namespace of the leader election lease. Defaults to the operator one.",
+						Sources: cli.EnvVars("LEADER_ELECTION_NAMESPACE"),
+					},
+				},
+				Action: runOperator,
+			},
+			{
+				Name:   "openapi",
+				Usage:  "Print the OpenAPI 3 document of the API, e.g. to generate clients.",
+				Action: openapi,
+			},
 		},
 		Action: run,
 		Authors: []any{
@@ -93,8 +317,9 @@ func main() {
 		select {
 		case <-sigs:
 			webserver.Logger.Info("signal interruption catched")
+			// Restore default behavior such that a second signal forces exit
+			signal.Stop(sigs)
 			cancel()
-			os.Exit(1)
 		case <-ctx.Done():
 			return
 		}
@@ -106,42 +331,145 @@ func main() {
 	}
 }
 
-func run(_ context.Context, cmd *cli.Command) error {
+func run(ctx context.Context, cmd *cli.Command) error {
 	gin.SetMode(gin.ReleaseMode)
-	router := gin.New()
-	router.Use(ginzap.Ginzap(webserver.Logger, time.RFC3339, true))
-	router.Use(ginzap.RecoveryWithZap(webserver.Logger, true))
+	var st storage.Storage
+	if raw := cmd.String("storage"); raw != "" {
+		var err error
+		st, err = storage.New(raw, &storage.S3Options{
+			Endpoint: cmd.String("storage-s3-endpoint"),
+			Region:   cmd.String("storage-s3-region"),
+			Insecure: cmd.Bool("storage-s3-insecure"),
+		})
+		if err != nil {
+			return err
+		}
+	}
 
-	apiv1g := router.Group("/api/v1")
-	apiv1g.GET("/coverout", apiv1.Coverout)
+	h, err := webserver.NewHandler(&webserver.Options{
+		Coverdir:      cmd.String("coverdir"),
+		Storage:       st,
+		Logger:        webserver.Logger,
+		MaxSize:       cmd.Int64("max-size"),
+		MaxMerges:     cmd.Int("max-merges"),
+		MaxUploadSize: cmd.Int64("max-upload-size"),
+		BuildInfo:     buildInfo(),
+		BasePath:      cmd.String("base-path"),
+	})
+	if err != nil {
+		return err
+	}
 
 	port := cmd.Int("port")
 	webserver.Logger.Info("api server listening",
 		zap.Int("port", port),
+		zap.String("base_path", webserver.CleanBasePath(cmd.String("base-path"))),
+	)
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           h,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	defer func() {
+		if err := h.Close(); err != nil {
+			webserver.Logger.Error("closing handler", zap.Error(err))
+		}
+	}()
+
+	return serve(ctx, srv, cmd.Duration("grace-period"), srv.ListenAndServe)
+}
+
+func runOperator(ctx context.Context, cmd *cli.Command) error {
+	webserver.Logger.Info("operator starting",
+		zap.Bool("leader_elect", cmd.Bool("leader-elect")),
 	)
-	return router.Run(fmt.Sprintf(":%d", port))
+	return operator.Run(ctx, operator.Options{
+		HealthProbeAddress:      cmd.String("health-probe-address"),
+		MetricsAddress:          cmd.String("metrics-address"),
+		LeaderElection:          cmd.Bool("leader-elect"),
+		LeaderElectionNamespace: cmd.String("leader-election-namespace"),
+	}, webserver.Logger)
 }
 
-func download(_ context.Context, cmd *cli.Command) error {
-	// Download coverages
-	server := cmd.String("server")
-	fmt.Printf("Downloading coverages from %s...\n", server)
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/api/v1/coverout", server), nil)
+func webhook(ctx context.Context, cmd *cli.Command) error {
+	gin.SetMode(gin.ReleaseMode)
+	wh, err := instrument.NewWebhook(instrument.Options{
+		ClaimName: cmd.String("claim-name"),
+		MountPath: cmd.String("mount-path"),
+	}, webserver.Logger)
 	if err != nil {
 		return err
 	}
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
+
+	port := cmd.Int("port")
+	webserver.Logger.Info("webhook server listening",
+		zap.Int("port", port),
+		zap.String("claim_name", cmd.String("claim-name")),
+	)
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           wh,
+		ReadHeaderTimeout: 10 * time.Second,
 	}
-	defer func() {
-		_ = res.Body.Close()
+
+	return serve(ctx, srv, cmd.Duration("grace-period"), func() error {
+		return srv.ListenAndServeTLS(cmd.String("tls-cert"), cmd.String("tls-key"))
+	})
+}
+
+// serve runs the server until the context is done, then shuts it down
+// gracefully.
+func serve(ctx context.Context, srv *http.Server, grace time.Duration, listen func() error) error {
+	errs := make(chan error, 1)
+	go func() {
+		if err := listen(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errs <- err
+		}
+		close(errs)
 	}()
 
-	// Decode them
-	resp := &apiv1.CoveroutResponse{}
-	if err := json.NewDecoder(res.Body).Decode(resp); err != nil {
+	select {
+	case err := <-errs:
 		return err
+	case <-ctx.Done():
+	}
+
+	// Drain in-flight requests, then interrupt those remaining
+	webserver.Logger.Info("server shutting down",
+		zap.Duration("grace_period", grace),
+	)
+	sctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	if err := srv.Shutdown(sctx); err != nil {
+		webserver.Logger.Warn("grace period exceeded, interrupting in-flight requests", zap.Error(err))
+		return srv.Close()
+	}
+	return nil
+}
+
+func download(ctx context.Context, cmd *cli.Command) error {
+	// Reach out the Romeo environment, through a port-forward if no server is defined
+	server := cmd.String("server")
+	if server == "" {
+		fmt.Println("No server defined, port-forwarding through the Kubernetes API server...")
+		local, stop, err := webserver.PortForward(ctx, &webserver.PortForwardOptions{
+			Kubeconfig: cmd.String("kubeconfig"),
+			Namespace:  cmd.String("namespace"),
+			Selector:   cmd.String("selector"),
+			Instance:   cmd.String("instance"),
+		})
+		if err != nil {
+			return errors.Wrap(err, "port-forwarding to Romeo")
+		}
+		defer stop()
+		server = local
+	}
+
+	// Download coverages
+	fmt.Printf("Downloading coverages from %s...\n", server)
+	resp, err := apiv1.NewClient(server, nil).Coverout(ctx)
+	if err != nil {
+		return errors.Wrap(err, "downloading coverages")
 	}
 
 	// Export to filesystem
@@ -154,3 +482,106 @@ func download(_ context.Context, cmd *cli.Command) error {
 	// Write coverdir as an output
 	return webserver.Output("directory", cd)
 }
+
+func preserve(ctx context.Context, cmd *cli.Command) error {
+	s, err := sink.New(cmd.String("sink"), &sink.S3Options{
+		Endpoint: cmd.String("s3-endpoint"),
+		Region:   cmd.String("s3-region"),
+		Insecure: cmd.Bool("s3-insecure"),
+	})
+	if err != nil {
+		return err
+	}
+
+	server := cmd.String("server")
+	fmt.Printf("Merging coverages of %s...\n", server)
+	resp, err := apiv1.NewClient(server, nil).Coverout(ctx)
+	if err != nil {
+		return errors.Wrap(err, "merging coverages")
+	}
+	bundle, err := base64.StdEncoding.DecodeString(resp.Merged)
+	if err != nil {
+		return errors.Wrap(err, "decoding coverages")
+	}
+
+	fmt.Printf("Writing coverages to %s\n", cmd.String("sink"))
+	return s.Write(ctx, bundle)
+}
+
+func collect(ctx context.Context, cmd *cli.Command) error {
+	cfg, err := webserver.RESTConfig(cmd.String("kubeconfig"))
+	if err != nil {
+		return err
+	}
+	client, err := metadata.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "building Kubernetes client")
+	}
+
+	report, err := gc.Collect(ctx, client, gc.Options{
+		Namespace: cmd.String("namespace"),
+		DryRun:    cmd.Bool("dry-run"),
+	})
+	if report != nil {
+		// Nothing was deleted if it failed
+		if werr := report.Write(os.Stdout, cmd.Bool("dry-run") || err != nil); werr != nil {
+			return werr
+		}
+	}
+	return err
+}
+
+func instrumentManifests(_ context.Context, cmd *cli.Command) error {
+	opts := instrument.ManifestsOptions{
+		Options: instrument.Options{
+			ClaimName: cmd.String("claim-name"),
+			MountPath: cmd.String("mount-path"),
+		},
+		Selector: cmd.String("selector"),
+	}
+
+	// Read the files as a single stream of documents
+	files := cmd.Args().Slice()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	readers := make([]io.Reader, 0, 2*len(files))
+	for i, file := range files {
+		if i != 0 {
+			readers = append(readers, strings.NewReader("\n---\n"))
+		}
+		if file == "-" {
+			readers = append(readers, os.Stdin)
+			continue
+		}
+		f, err := os.Open(file) //nolint:gosec // G304 -- the manifests path is user-provided on purpose
+		if err != nil {
+			return errors.Wrapf(err, "opening %s", file)
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		readers = append(readers, f)
+	}
+
+	return instrument.Manifests(io.MultiReader(readers...), os.Stdout, opts)
+}
+
+func openapi(_ context.Context, _ *cli.Command) error {
+	doc := apiv1.NewServer(apiv1.Config{
+		BuildInfo: buildInfo(),
+	}).OpenAPIDocument()
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+func buildInfo() apiv1.BuildInfo {
+	return apiv1.BuildInfo{
+		Version: version,
+		Commit:  commit,
+		Date:    date,
+		BuiltBy: builtBy,
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_U_Serve(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		ListenErr    error
+		Blocking     bool
+		GracePeriod  time.Duration
+		ExpectDrain  bool
+		ExpectStatus int
+	}{
+		"listen-error": {
+			ListenErr:   errors.New("address already in use"),
+			GracePeriod: time.Second,
+		},
+		"drained": {
+			// The grace period is well above the handler duration
+			Blocking:     false,
+			GracePeriod:  time.Second,
+			ExpectDrain:  true,
+			ExpectStatus: http.StatusOK,
+		},
+		"grace-period-exceeded": {
+			Blocking:    true,
+			GracePeriod: 20 * time.Millisecond,
+			ExpectDrain: false,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			assert := assert.New(t)
+			require := require.New(t)
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(err)
+
+			started := make(chan struct{})
+			drained := make(chan bool, 1)
+			srv := &http.Server{
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					close(started)
+					if tt.Blocking {
+						// e.g. a merge, interrupted once the grace period is exceeded
+						<-r.Context().Done()
+						drained <- false
+						return
+					}
+					time.Sleep(50 * time.Millisecond)
+					w.WriteHeader(http.StatusOK)
+					drained <- true
+				}),
+				ReadHeaderTimeout: time.Second,
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			served := make(chan error, 1)
+			go func() {
+				served <- serve(ctx, srv, tt.GracePeriod, func() error {
+					if tt.ListenErr != nil {
+						_ = ln.Close()
+						return tt.ListenErr
+					}
+					return srv.Serve(ln)
+				})
+			}()
+			if tt.ListenErr != nil {
+				assert.ErrorIs(<-served, tt.ListenErr)
+				return
+			}
+
+			// Shut down while a request is in-flight
+			status := make(chan int, 1)
+			go func() {
+				res, err := http.Get("http://" + ln.Addr().String())
+				if err != nil {
+					status <- 0
+					return
+				}
+				_ = res.Body.Close()
+				status <- res.StatusCode
+			}()
+			<-started
+			cancel()
+
+			assert.NoError(<-served)
+			assert.Equal(tt.ExpectDrain, <-drained)
+			if tt.ExpectDrain {
+				assert.Equal(tt.ExpectStatus, <-status)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+// Package gc garbage collects the orphaned Romeo environments, e.g. when a
+// workflow is cancelled before its cleanup.
+// Environments are considered orphaned once older than the TTL their
+// resources are annotated with by RomeoEnvironment.
+package gc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ctfer-io/romeo/sdk"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/metadata"
+)
+
+// Selector selects the Romeo resources.
+const Selector = "app.kubernetes.io/part-of=romeo"
+
+var (
+	namespaces = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+
+	// resources a Romeo environment is made of, in a namespace.
+	resources = []schema.GroupVersionResource{
+		{Group: "apps", Version: "v1", Resource: "deployments"},
+		{Version: "v1", Resource: "services"},
+		{Version: "v1", Resource: "persistentvolumeclaims"},
+		{Version: "v1", Resource: "secrets"},
+		{Group: "networking.k8s.io", Version: "v1", Resource: "networkpolicies"},
+		{Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"},
+		{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "httproutes"},
+	}
+)
+
+// Options of a garbage collection.
+type Options struct {
+	// Namespace to collect the environments in. If empty, collects in all
+	// namespaces, including the namespaces created by the environments.
+	Namespace string
+
+	// DryRun only reports the expired resources, without deleting them.
+	DryRun bool
+
+	// Now is the time to compare the expiries with. Defaults to the
+	// current time.
+	Now time.Time
+}
+
+// Resource is an expired Romeo resource.
+type Resource struct {
+	Resource  schema.GroupVersionResource
+	Namespace string
+	Name      string
+	Age       time.Duration
+	TTL       time.Duration
+}
+
+// Report of a garbage collection.
+type Report struct {
+	// Expired resources, deleted unless on a dry run.
+	Expired []Resource
+
+	// Invalid resources, whose TTL annotation could not be parsed.
+	// They are not deleted.
+	Invalid []Resource
+}
+
+// Collect finds the expired Romeo resources, then deletes them.
+// All deletions are first run on the server in dry-run mode, such that
+// nothing is deleted if any of them would fail.
+func Collect(ctx context.Context, client metadata.Interface, opts Options) (*Report, error) {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	report, err := find(ctx, client, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Dry-run first, then delete for real if required
+	runs := []bool{true}
+	if !opts.DryRun {
+		runs = append(runs, false)
+	}
+	for _, dryRun := range runs {
+		delOpts := metav1.DeleteOptions{
+			PropagationPolicy: ptr(metav1.DeletePropagationBackground),
+		}
+		if dryRun {
+			delOpts.DryRun = []string{metav1.DryRunAll}
+		}
+		for _, res := range report.Expired {
+			if err := client.Resource(res.Resource).Namespace(res.Namespace).Delete(ctx, res.Name, delOpts); err != nil && !apierrors.IsNotFound(err) {
+				return report, errors.Wrapf(err, "deleting %s %s", res.Resource.Resource, res.key())
+			}
+		}
+	}
+	return report, nil
+}
+
+func find(ctx context.Context, client metadata.Interface, opts Options) (*Report, error) {
+	report := &Report{}
+	listOpts := metav1.ListOptions{LabelSelector: Selector}
+
+	// The resources of an expired namespace are deleted along with it
+	expiredNs := map[string]struct{}{}
+	if opts.Namespace == "" {
+		list, err := client.Resource(namespaces).List(ctx, listOpts)
+		if err != nil {
+			return nil, errors.Wrap(err, "listing namespaces")
+		}
+		for _, item := range list.Items {
+			if report.add(namespaces, &item.ObjectMeta, opts.Now) {
+				expiredNs[item.Name] = struct{}{}
+			}
+		}
+	}
+
+	for _, gvr := range resources {
+		list, err := client.Resource(gvr).Namespace(opts.Namespace).List(ctx, listOpts)
+		if err != nil {
+			// The resource is not served, e.g. no Gateway API in the cluster
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "listing %s", gvr.Resource)
+		}
+		for _, item := range list.Items {
+			if _, ok := expiredNs[item.Namespace]; ok {
+				continue
+			}
+			report.add(gvr, &item.ObjectMeta, opts.Now)
+		}
+	}
+	return report, nil
+}
+
+// add adds the resource to the report if it expired or is invalid, and
+// returns whether it expired.
+func (report *Report) add(gvr schema.GroupVersionResource, meta *metav1.ObjectMeta, now time.Time) bool {
+	raw, ok := meta.Annotations[sdk.TTLAnnotation]
+	if !ok {
+		return false
+	}
+	res := Resource{
+		Resource:  gvr,
+		Namespace: meta.Namespace,
+		Name:      meta.Name,
+		Age:       now.Sub(meta.CreationTimestamp.Time),
+	}
+	ttl, err := time.ParseDuration(raw)
+	if err != nil {
+		report.Invalid = append(report.Invalid, res)
+		return false
+	}
+	res.TTL = ttl
+	if ttl <= 0 || res.Age < ttl {
+		return false
+	}
+	report.Expired = append(report.Expired, res)
+	return true
+}
+
+// Write writes the report as a table.
+func (report *Report) Write(w io.Writer, dryRun bool) error {
+	action := "DELETED"
+	if dryRun {
+		action = "WOULD DELETE"
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "RESOURCE\tNAME\tAGE\tTTL\tACTION")
+	for _, res := range report.Expired {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", res.Resource.Resource, res.key(), res.Age.Round(time.Second), res.TTL, action)
+	}
+	for _, res := range report.Invalid {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t-\tSKIPPED (invalid %s annotation)\n", res.Resource.Resource, res.key(), res.Age.Round(time.Second), sdk.TTLAnnotation)
+	}
+	return tw.Flush()
+}
+
+func (res Resource) key() string {
+	if res.Namespace == "" {
+		return res.Name
+	}
+	return res.Namespace + "/" + res.Name
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
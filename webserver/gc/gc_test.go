@@ -0,0 +1,149 @@
+package gc_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ctfer-io/romeo/sdk"
+	"github.com/ctfer-io/romeo/webserver/gc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/metadata/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var now = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func object(apiVersion, kind, namespace, name string, age time.Duration, ttl string) *metav1.PartialObjectMetadata {
+	obj := &metav1.PartialObjectMetadata{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: apiVersion,
+			Kind:       kind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         namespace,
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(now.Add(-age)),
+			Labels: map[string]string{
+				"app.kubernetes.io/part-of": "romeo",
+			},
+		},
+	}
+	if ttl != "" {
+		obj.Annotations = map[string]string{
+			sdk.TTLAnnotation: ttl,
+		}
+	}
+	return obj
+}
+
+func Test_U_Collect(t *testing.T) {
+	t.Parallel()
+
+	objects := []runtime.Object{
+		// Expired namespace, its content is deleted along with it
+		object("v1", "Namespace", "", "romeo-environment-abcdefgh", 48*time.Hour, "24h"),
+		object("apps/v1", "Deployment", "romeo-environment-abcdefgh", "old", 48*time.Hour, "24h"),
+		// Expired resources in an existing namespace
+		object("apps/v1", "Deployment", "ci", "old", 3*time.Hour, "2h"),
+		object("v1", "PersistentVolumeClaim", "ci", "old", 3*time.Hour, "2h"),
+		object("v1", "Secret", "ci", "old-pull", 3*time.Hour, "2h"),
+		// Not expired yet
+		object("apps/v1", "Deployment", "ci", "new", time.Hour, "2h"),
+		// Never expires
+		object("v1", "Service", "ci", "forever", 48*time.Hour, "0"),
+		// Not an environment, e.g. the install ones
+		object("v1", "Service", "ci", "install", 48*time.Hour, ""),
+		// Invalid TTL
+		object("v1", "Service", "ci", "invalid", 48*time.Hour, "one day"),
+	}
+
+	var tests = map[string]struct {
+		Options       gc.Options
+		ExpectExpired []string
+		ExpectInvalid []string
+		ExpectDeleted bool
+	}{
+		"dry-run": {
+			Options: gc.Options{
+				DryRun: true,
+			},
+			ExpectExpired: []string{"namespaces/romeo-environment-abcdefgh", "deployments/ci/old", "persistentvolumeclaims/ci/old", "secrets/ci/old-pull"},
+			ExpectInvalid: []string{"services/ci/invalid"},
+			ExpectDeleted: false,
+		},
+		"all-namespaces": {
+			Options:       gc.Options{},
+			ExpectExpired: []string{"namespaces/romeo-environment-abcdefgh", "deployments/ci/old", "persistentvolumeclaims/ci/old", "secrets/ci/old-pull"},
+			ExpectInvalid: []string{"services/ci/invalid"},
+			ExpectDeleted: true,
+		},
+		"namespace": {
+			Options: gc.Options{
+				Namespace: "ci",
+			},
+			ExpectExpired: []string{"deployments/ci/old", "persistentvolumeclaims/ci/old", "secrets/ci/old-pull"},
+			ExpectInvalid: []string{"services/ci/invalid"},
+			ExpectDeleted: true,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			assert := assert.New(t)
+			require := require.New(t)
+
+			scheme := fake.NewTestScheme()
+			require.NoError(metav1.AddMetaToScheme(scheme))
+			client := fake.NewSimpleMetadataClient(scheme, objects...)
+			// The fake client does not support dry-runs
+			dryRuns := 0
+			client.PrependReactor("delete", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				del := action.(k8stesting.DeleteAction)
+				if len(del.GetDeleteOptions().DryRun) != 0 {
+					dryRuns++
+					return true, nil, nil
+				}
+				return false, nil, nil
+			})
+
+			opts := tt.Options
+			opts.Now = now
+			report, err := gc.Collect(context.Background(), client, opts)
+			require.NoError(err)
+
+			assert.Equal(tt.ExpectExpired, keys(report.Expired))
+			assert.Equal(tt.ExpectInvalid, keys(report.Invalid))
+			assert.Equal(len(tt.ExpectExpired), dryRuns)
+
+			gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+			_, err = client.Resource(gvr).Namespace("ci").Get(context.Background(), "old", metav1.GetOptions{})
+			assert.Equal(tt.ExpectDeleted, err != nil)
+			_, err = client.Resource(gvr).Namespace("ci").Get(context.Background(), "new", metav1.GetOptions{})
+			assert.NoError(err)
+
+			buf := &bytes.Buffer{}
+			require.NoError(report.Write(buf, opts.DryRun))
+			assert.Contains(buf.String(), "ci/old")
+		})
+	}
+}
+
+func keys(resources []gc.Resource) []string {
+	out := []string{}
+	for _, res := range resources {
+		key := res.Resource.Resource + "/"
+		if res.Namespace != "" {
+			key += res.Namespace + "/"
+		}
+		out = append(out, key+res.Name)
+	}
+	return out
+}
@@ -0,0 +1,142 @@
+package webserver
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	apiv1 "github.com/ctfer-io/romeo/webserver/api/v1"
+	"github.com/ctfer-io/romeo/webserver/storage"
+	ginzap "github.com/gin-contrib/zap"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Options to configure a Romeo [*Handler].
+type Options struct {
+	// Coverdir is the directory containing the coverage data to merge.
+	Coverdir string
+
+	// Storage of the coverage data to merge, e.g. an S3-compatible bucket
+	// for them to outlive the environment. Defaults to the Coverdir one.
+	Storage storage.Storage
+
+	// Logger to use. Defaults to [Logger].
+	Logger *zap.Logger
+
+	// MaxSize is the maximum size of the encoded merged coverages.
+	// Zero means no limit.
+	MaxSize int64
+
+	// MaxMerges is the maximum number of merges running concurrently.
+	// Zero means no limit.
+	MaxMerges int
+
+	// MaxUploadSize is the maximum size of a coverage file uploaded by an
+	// instrumented application. Defaults to [apiv1.DefaultMaxUploadSize].
+	MaxUploadSize int64
+
+	// BuildInfo of the webserver, served for clients to check compatibility.
+	BuildInfo apiv1.BuildInfo
+
+	// BasePath to serve all routes under, e.g. "/romeo" when behind a
+	// reverse proxy that does not strip its path prefix.
+	// Defaults to serving at the root.
+	BasePath string
+}
+
+// Handler serves the Romeo API. It is a standard [http.Handler] thus
+// could be embedded in any Go HTTP server.
+type Handler struct {
+	router *gin.Engine
+	logger *zap.Logger
+	v1     *apiv1.Server
+}
+
+var _ http.Handler = (*Handler)(nil)
+
+// NewHandler constructs a fresh [*Handler] from the given options.
+func NewHandler(opts *Options) (*Handler, error) {
+	if opts == nil {
+		return nil, errors.New("no options")
+	}
+	if opts.Coverdir == "" && opts.Storage == nil {
+		return nil, errors.New("no coverdir nor storage defined")
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = Logger
+	}
+
+	basePath := CleanBasePath(opts.BasePath)
+
+	router := gin.New()
+	router.Use(ginzap.Ginzap(logger, time.RFC3339, true))
+	router.Use(ginzap.RecoveryWithZap(logger, true))
+	base := router.Group(basePath)
+
+	v1 := apiv1.NewServer(apiv1.Config{
+		Coverdir:      opts.Coverdir,
+		Storage:       opts.Storage,
+		Logger:        logger,
+		MaxSize:       opts.MaxSize,
+		MaxMerges:     opts.MaxMerges,
+		MaxUploadSize: opts.MaxUploadSize,
+		BuildInfo:     opts.BuildInfo,
+		BasePath:      basePath,
+	})
+	v1.Register(base.Group("/api/v1"))
+
+	h := &Handler{
+		router: router,
+		logger: logger,
+		v1:     v1,
+	}
+	base.GET("/healthz", h.healthz)
+	base.GET("/readyz", h.readyz)
+	router.NoRoute(func(ctx *gin.Context) {
+		apiv1.WriteProblem(ctx, logger, apiv1.NotFound(ctx.Request.URL.Path))
+	})
+
+	return h, nil
+}
+
+// CleanBasePath normalizes a base path, such that it is either empty or
+// starts with a "/" and does not end with one.
+func CleanBasePath(basePath string) string {
+	basePath = strings.Trim(basePath, "/")
+	if basePath == "" {
+		return ""
+	}
+	return "/" + basePath
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+// Close releases the resources held by the handler, such as the temporary
+// directories of interrupted merges, once they returned.
+// It should be called once the HTTP server has been shut down or closed.
+func (h *Handler) Close() error {
+	return h.v1.Close()
+}
+
+// healthz reports the webserver is alive.
+func (h *Handler) healthz(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+	})
+}
+
+// readyz reports the webserver is able to serve merges.
+func (h *Handler) readyz(ctx *gin.Context) {
+	if err := h.v1.Ready(ctx.Request.Context()); err != nil {
+		apiv1.WriteProblem(ctx, h.logger, apiv1.NewProblem(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+	})
+}
@@ -0,0 +1,416 @@
+package webserver_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ctfer-io/romeo/webserver"
+	apiv1 "github.com/ctfer-io/romeo/webserver/api/v1"
+	"github.com/ctfer-io/romeo/webserver/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_U_NewHandler(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Options   *webserver.Options
+		ExpectErr bool
+	}{
+		"nil": {
+			Options:   nil,
+			ExpectErr: true,
+		},
+		"no-coverdir": {
+			Options:   &webserver.Options{},
+			ExpectErr: true,
+		},
+		"coverdir": {
+			Options: &webserver.Options{
+				Coverdir: t.TempDir(),
+			},
+		},
+		"storage": {
+			Options: &webserver.Options{
+				Storage: storage.NewFilesystem(t.TempDir()),
+			},
+		},
+		"limits": {
+			Options: &webserver.Options{
+				Coverdir:  t.TempDir(),
+				MaxSize:   1 << 20,
+				MaxMerges: 1,
+			},
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			h, err := webserver.NewHandler(tt.Options)
+			if tt.ExpectErr {
+				require.Error(err)
+				return
+			}
+			require.NoError(err)
+
+			// Unknown routes are not served
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/unknown", nil))
+			assert.Equal(http.StatusNotFound, rec.Code)
+			assert.Equal(apiv1.ProblemContentType, rec.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func Test_U_Probes(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Coverdir      string
+		ExpectHealthz int
+		ExpectReadyz  int
+	}{
+		"existing-coverdir": {
+			Coverdir:      t.TempDir(),
+			ExpectHealthz: http.StatusOK,
+			ExpectReadyz:  http.StatusOK,
+		},
+		"missing-coverdir": {
+			Coverdir:      filepath.Join(t.TempDir(), "missing"),
+			ExpectHealthz: http.StatusOK,
+			ExpectReadyz:  http.StatusServiceUnavailable,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			h, err := webserver.NewHandler(&webserver.Options{
+				Coverdir: tt.Coverdir,
+			})
+			require.NoError(err)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			assert.Equal(tt.ExpectHealthz, rec.Code)
+
+			rec = httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			assert.Equal(tt.ExpectReadyz, rec.Code)
+		})
+	}
+}
+
+func Test_U_Diagnostics(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	coverdir := t.TempDir()
+	files := map[string][]byte{
+		"covmeta.0123abcd":             {0x00, 0x63, 0x76, 0x6d, 0x01},
+		"covcounters.0123abcd.42.1234": {0x00, 0x63, 0x77, 0x6d, 0x01},
+		"covcounters.0123abcd.43.1234": {0xde, 0xad, 0xbe, 0xef},
+		"README.md":                    []byte("not a coverage file"),
+	}
+	for name, content := range files {
+		require.NoError(os.WriteFile(filepath.Join(coverdir, name), content, 0600))
+	}
+
+	h, err := webserver.NewHandler(&webserver.Options{
+		Coverdir: coverdir,
+	})
+	require.NoError(err)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/diagnostics", nil))
+	require.Equal(http.StatusOK, rec.Code)
+
+	var resp apiv1.DiagnosticsResponse
+	require.NoError(json.NewDecoder(rec.Body).Decode(&resp))
+
+	status := map[string]string{}
+	for _, f := range resp.Files {
+		status[f.Name] = f.Kind + "/" + f.Status
+	}
+	assert.Equal(map[string]string{
+		"covmeta.0123abcd":             "meta/ok",
+		"covcounters.0123abcd.42.1234": "counters/ok",
+		"covcounters.0123abcd.43.1234": "counters/invalid",
+		"README.md":                    "unknown/ignored",
+	}, status)
+}
+
+// blockingStorage blocks listing its objects until the context is done,
+// such that merges are in-flight.
+type blockingStorage struct {
+	listing chan struct{}
+}
+
+func (st *blockingStorage) List(ctx context.Context) ([]storage.Object, error) {
+	close(st.listing)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (st *blockingStorage) Open(context.Context, string) (io.ReadCloser, error) {
+	return nil, os.ErrNotExist
+}
+
+func (st *blockingStorage) Put(context.Context, string, io.Reader, int64) error {
+	return nil
+}
+
+func (st *blockingStorage) Ready(context.Context) error {
+	return nil
+}
+
+func Test_U_CloseDuringMerge(t *testing.T) {
+	// Not parallel, as the merges temporary directories are created in
+	// TMPDIR
+	assert := assert.New(t)
+	require := require.New(t)
+
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	st := &blockingStorage{
+		listing: make(chan struct{}),
+	}
+	h, err := webserver.NewHandler(&webserver.Options{
+		Storage: st,
+	})
+	require.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	merged := make(chan int, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/coverout", nil).WithContext(ctx))
+		merged <- rec.Code
+	}()
+	<-st.listing
+
+	// The merge output and fetched coverages directories are in use
+	entries, err := os.ReadDir(tmp)
+	require.NoError(err)
+	assert.Len(entries, 2)
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- h.Close()
+	}()
+	select {
+	case <-closed:
+		require.FailNow("closed while a merge is in-flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	entries, err = os.ReadDir(tmp)
+	require.NoError(err)
+	assert.Len(entries, 2)
+
+	// Interrupt the merge, as closing the HTTP server does
+	cancel()
+	assert.Equal(http.StatusInternalServerError, <-merged)
+	require.NoError(<-closed)
+	entries, err = os.ReadDir(tmp)
+	require.NoError(err)
+	assert.Empty(entries)
+
+	// Merges are no longer served
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/coverout", nil))
+	assert.Equal(http.StatusServiceUnavailable, rec.Code)
+}
+
+func Test_U_Upload(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Path          string
+		Content       []byte
+		MaxUploadSize int64
+		ExpectStatus  int
+		ExpectCode    string
+		ExpectFile    string
+	}{
+		"meta": {
+			Path:         "/api/v1/coverages/app-0/covmeta.0123abcd",
+			Content:      []byte{0x00, 0x63, 0x76, 0x6d, 0x01},
+			ExpectStatus: http.StatusOK,
+			ExpectFile:   "app-0/covmeta.0123abcd",
+		},
+		"counters": {
+			Path:         "/api/v1/coverages/app-0/covcounters.0123abcd.42.1234",
+			Content:      []byte{0x00, 0x63, 0x77, 0x6d, 0x01},
+			ExpectStatus: http.StatusOK,
+			ExpectFile:   "app-0/covcounters.0123abcd.42.1234",
+		},
+		"escaping-pod": {
+			Path:         "/api/v1/coverages/../covmeta.0123abcd",
+			Content:      []byte{0x00},
+			ExpectStatus: http.StatusBadRequest,
+			ExpectCode:   apiv1.CodePathTainted,
+		},
+		"not-coverage": {
+			Path:         "/api/v1/coverages/app-0/main.go",
+			Content:      []byte("package main"),
+			ExpectStatus: http.StatusBadRequest,
+			ExpectCode:   apiv1.CodePathTainted,
+		},
+		"too-large": {
+			Path:          "/api/v1/coverages/app-0/covmeta.0123abcd",
+			Content:       []byte{0x00, 0x63, 0x76, 0x6d, 0x01},
+			MaxUploadSize: 4,
+			ExpectStatus:  http.StatusRequestEntityTooLarge,
+			ExpectCode:    apiv1.CodeTooLargeContent,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			assert := assert.New(t)
+			require := require.New(t)
+
+			coverdir := t.TempDir()
+			h, err := webserver.NewHandler(&webserver.Options{
+				Coverdir:      coverdir,
+				MaxUploadSize: tt.MaxUploadSize,
+			})
+			require.NoError(err)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, tt.Path, bytes.NewReader(tt.Content)))
+			require.Equal(tt.ExpectStatus, rec.Code)
+
+			if tt.ExpectCode != "" {
+				var p apiv1.Problem
+				require.NoError(json.NewDecoder(rec.Body).Decode(&p))
+				assert.Equal(tt.ExpectCode, p.Code)
+
+				// Nothing is stored
+				entries, err := os.ReadDir(coverdir)
+				require.NoError(err)
+				assert.Empty(entries)
+				return
+			}
+
+			var resp apiv1.UploadResponse
+			require.NoError(json.NewDecoder(rec.Body).Decode(&resp))
+			assert.Equal(tt.ExpectFile, resp.Name)
+			assert.Equal(int64(len(tt.Content)), resp.Size)
+
+			b, err := os.ReadFile(filepath.Join(coverdir, filepath.FromSlash(tt.ExpectFile)))
+			require.NoError(err)
+			assert.Equal(tt.Content, b)
+		})
+	}
+}
+
+func Test_U_OpenAPI(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	bi := apiv1.BuildInfo{
+		Version: "v1.2.3",
+		Commit:  "0123abcd",
+		Date:    "2025-01-01T00:00:00Z",
+		BuiltBy: "test",
+	}
+	h, err := webserver.NewHandler(&webserver.Options{
+		Coverdir:  t.TempDir(),
+		BuildInfo: bi,
+	})
+	require.NoError(err)
+
+	// Version is served as is
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/version", nil))
+	require.Equal(http.StatusOK, rec.Code)
+
+	var got apiv1.BuildInfo
+	require.NoError(json.NewDecoder(rec.Body).Decode(&got))
+	assert.Equal(bi, got)
+
+	// OpenAPI document describes all routes
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+	require.Equal(http.StatusOK, rec.Code)
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Info    struct {
+			Version string `json:"version"`
+		} `json:"info"`
+		Paths map[string]any `json:"paths"`
+	}
+	require.NoError(json.NewDecoder(rec.Body).Decode(&doc))
+	assert.Equal("3.0.3", doc.OpenAPI)
+	assert.Equal(bi.Version, doc.Info.Version)
+	for _, path := range []string{"/coverout", "/diagnostics", "/coverages/{pod}/{file}", "/version", "/openapi.json"} {
+		assert.Contains(doc.Paths, path)
+	}
+}
+
+func Test_U_BasePath(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		BasePath string
+		Expect   map[string]int
+	}{
+		"root": {
+			BasePath: "",
+			Expect: map[string]int{
+				"/healthz":        http.StatusOK,
+				"/api/v1/version": http.StatusOK,
+			},
+		},
+		"prefix": {
+			BasePath: "romeo/env-1/",
+			Expect: map[string]int{
+				"/romeo/env-1/healthz":        http.StatusOK,
+				"/romeo/env-1/api/v1/version": http.StatusOK,
+				"/healthz":                    http.StatusNotFound,
+				"/api/v1/version":             http.StatusNotFound,
+			},
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			h, err := webserver.NewHandler(&webserver.Options{
+				Coverdir: t.TempDir(),
+				BasePath: tt.BasePath,
+			})
+			require.NoError(err)
+
+			for path, status := range tt.Expect {
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+				assert.Equal(status, rec.Code, path)
+			}
+		})
+	}
+}
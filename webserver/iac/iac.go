@@ -0,0 +1,156 @@
+// Package iac drives the Romeo Pulumi programs through the Automation API,
+// as the GitHub Actions do, for any CI system or a developer workstation.
+// It requires the Pulumi CLI to be installed, but to render manifests.
+package iac
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/ctfer-io/romeo/sdk/programs"
+	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optdestroy"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optup"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/workspace"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+// DefaultBackend is the local file backend in the home directory, the one
+// `pulumi login --local` uses.
+const DefaultBackend = "file://~"
+
+// Program is a Romeo Pulumi program, with the project it reads its
+// configuration from.
+type Program struct {
+	Project string
+	Run     pulumi.RunFunc
+}
+
+var (
+	// Environment deploys a Romeo environment.
+	Environment = Program{
+		Project: programs.EnvironmentProject,
+		Run:     programs.Environment,
+	}
+
+	// Install deploys the resources required by Romeo environments.
+	Install = Program{
+		Project: programs.InstallProject,
+		Run:     programs.Install,
+	}
+)
+
+// StackOptions defines the stack to drive a [Program] with.
+type StackOptions struct {
+	// Name of the stack.
+	Name string
+
+	// Backend URL to store the state into. Defaults to [DefaultBackend].
+	Backend string
+
+	// Progress is where to stream the Pulumi progress to, if any.
+	Progress io.Writer
+}
+
+// Config of a stack, keyed without the project prefix.
+type Config map[string]auto.ConfigValue
+
+// Up creates or updates the stack with the given configuration, and returns
+// its outputs.
+func Up(ctx context.Context, prog Program, opts *StackOptions, config Config) (map[string]any, error) {
+	stack, err := auto.UpsertStackInlineSource(ctx, opts.Name, prog.Project, prog.Run, workspaceOptions(prog, opts)...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating or selecting stack %s", opts.Name)
+	}
+
+	cm := auto.ConfigMap{}
+	for key, value := range config {
+		cm[prog.Project+":"+key] = value
+	}
+	if err := stack.SetAllConfig(ctx, cm); err != nil {
+		return nil, errors.Wrap(err, "setting stack configuration")
+	}
+
+	res, err := stack.Up(ctx, optup.ProgressStreams(progress(opts)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "updating stack %s", opts.Name)
+	}
+	return values(res.Outputs), nil
+}
+
+// Down destroys the resources of the stack then removes it.
+// It does nothing if the stack does not exist.
+func Down(ctx context.Context, prog Program, opts *StackOptions) error {
+	stack, err := auto.SelectStackInlineSource(ctx, opts.Name, prog.Project, prog.Run, workspaceOptions(prog, opts)...)
+	if err != nil {
+		if auto.IsSelectStack404Error(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "selecting stack %s", opts.Name)
+	}
+
+	if _, err := stack.Destroy(ctx, optdestroy.ProgressStreams(progress(opts)), optdestroy.Remove()); err != nil {
+		return errors.Wrapf(err, "destroying stack %s", opts.Name)
+	}
+	return nil
+}
+
+// Status returns the outputs of the stack, as of its last update.
+func Status(ctx context.Context, prog Program, opts *StackOptions) (map[string]any, error) {
+	stack, err := auto.SelectStackInlineSource(ctx, opts.Name, prog.Project, prog.Run, workspaceOptions(prog, opts)...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "selecting stack %s", opts.Name)
+	}
+
+	outputs, err := stack.Outputs(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting stack %s outputs", opts.Name)
+	}
+	return values(outputs), nil
+}
+
+func workspaceOptions(prog Program, opts *StackOptions) []auto.LocalWorkspaceOption {
+	backend := opts.Backend
+	if backend == "" {
+		backend = DefaultBackend
+	}
+
+	wsOpts := []auto.LocalWorkspaceOption{
+		auto.Project(workspace.Project{
+			Name:    tokens.PackageName(prog.Project),
+			Runtime: workspace.NewProjectRuntimeInfo("go", nil),
+			Backend: &workspace.ProjectBackend{
+				URL: backend,
+			},
+		}),
+	}
+
+	// Use an empty passphrase for the secrets, as the GitHub Actions do,
+	// unless one is provided.
+	_, pass := os.LookupEnv("PULUMI_CONFIG_PASSPHRASE")
+	_, passFile := os.LookupEnv("PULUMI_CONFIG_PASSPHRASE_FILE")
+	if !pass && !passFile {
+		wsOpts = append(wsOpts, auto.EnvVars(map[string]string{
+			"PULUMI_CONFIG_PASSPHRASE": "",
+		}))
+	}
+	return wsOpts
+}
+
+func progress(opts *StackOptions) io.Writer {
+	if opts.Progress == nil {
+		return io.Discard
+	}
+	return opts.Progress
+}
+
+func values(outputs auto.OutputMap) map[string]any {
+	out := make(map[string]any, len(outputs))
+	for key, output := range outputs {
+		out[key] = output.Value
+	}
+	return out
+}
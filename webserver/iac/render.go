@@ -0,0 +1,179 @@
+package iac
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ctfer-io/romeo/sdk"
+	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// RenderEnvironment renders the Kubernetes manifests of a Romeo environment,
+// without deploying it nor requiring the Pulumi CLI.
+//
+// If the arguments have no instance, a random one is generated as it would
+// be when deploying. If they have no namespace, the manifests have none, for
+// kubectl or Kustomize to set it.
+func RenderEnvironment(args *sdk.RomeoEnvironmentArgs) ([]*unstructured.Unstructured, error) {
+	if args == nil {
+		args = &sdk.RomeoEnvironmentArgs{}
+	}
+	if args.Instance == nil {
+		args.Instance = pulumi.String(randomName())
+	}
+	// An output is considered an existing namespace, so is not created. As
+	// it can't be empty, a placeholder is rendered then removed.
+	if ns, ok := args.Namespace.(pulumi.String); args.Namespace == nil || ok && ns == "" {
+		args.Namespace = pulumi.String(renderNamespace).ToStringOutput()
+	}
+
+	mocks := &renderMocks{}
+	if err := pulumi.RunErr(func(ctx *pulumi.Context) error {
+		_, err := sdk.NewRomeoEnvironment(ctx, "romeo", args)
+		return err
+	}, pulumi.WithMocks("romeo", "render", mocks)); err != nil {
+		return nil, errors.Wrap(err, "rendering environment")
+	}
+
+	sort.Slice(mocks.manifests, func(i, j int) bool {
+		mi, mj := mocks.manifests[i], mocks.manifests[j]
+		if mi.GetKind() != mj.GetKind() {
+			return mi.GetKind() < mj.GetKind()
+		}
+		return mi.GetName() < mj.GetName()
+	})
+	return mocks.manifests, nil
+}
+
+// WriteManifests writes the manifests as a single YAML stream.
+func WriteManifests(w io.Writer, manifests []*unstructured.Unstructured) error {
+	for i, manifest := range manifests {
+		b, err := yaml.Marshal(manifest.Object)
+		if err != nil {
+			return errors.Wrapf(err, "marshalling %s %s", manifest.GetKind(), manifest.GetName())
+		}
+		if i != 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteKustomization writes the manifests in the directory, one file per
+// manifest, along with the kustomization.yaml referencing them.
+func WriteKustomization(dir string, manifests []*unstructured.Unstructured) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return errors.Wrapf(err, "creating directory %s", dir)
+	}
+
+	resources := make([]string, 0, len(manifests))
+	for _, manifest := range manifests {
+		b, err := yaml.Marshal(manifest.Object)
+		if err != nil {
+			return errors.Wrapf(err, "marshalling %s %s", manifest.GetKind(), manifest.GetName())
+		}
+		file := strings.ToLower(fmt.Sprintf("%s-%s.yaml", manifest.GetKind(), manifest.GetName()))
+		if err := os.WriteFile(filepath.Join(dir, file), b, 0o600); err != nil {
+			return errors.Wrapf(err, "writing %s", file)
+		}
+		resources = append(resources, file)
+	}
+
+	b, err := yaml.Marshal(map[string]any{
+		"apiVersion": "kustomize.config.k8s.io/v1beta1",
+		"kind":       "Kustomization",
+		"resources":  resources,
+	})
+	if err != nil {
+		return errors.Wrap(err, "marshalling kustomization")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), b, 0o600); err != nil {
+		return errors.Wrap(err, "writing kustomization")
+	}
+	return nil
+}
+
+// renderNamespace is the placeholder of the namespace, removed from the
+// rendered manifests.
+const renderNamespace = "romeo-render-namespace"
+
+// randomName returns 8 random lowercase letters, valid for any resource name.
+func randomName() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	for i := range b {
+		b[i] = 'a' + b[i]%26
+	}
+	return string(b)
+}
+
+// renderMocks records the Kubernetes resources a program registers,
+// rather than deploying them.
+type renderMocks struct {
+	mx        sync.Mutex
+	manifests []*unstructured.Unstructured
+}
+
+func (m *renderMocks) NewResource(args pulumi.MockResourceArgs) (string, resource.PropertyMap, error) {
+	if strings.HasPrefix(args.TypeToken, "kubernetes:") {
+		obj := prune(args.Inputs.Mappable())
+
+		m.mx.Lock()
+		m.manifests = append(m.manifests, &unstructured.Unstructured{Object: obj})
+		m.mx.Unlock()
+	}
+	return args.Name + "_id", args.Inputs, nil
+}
+
+func (m *renderMocks) Call(args pulumi.MockCallArgs) (resource.PropertyMap, error) {
+	return args.Args, nil
+}
+
+// prune removes the empty values of an object, as unset inputs are rendered
+// empty, and the placeholder namespace. Secrets are rendered as their values.
+func prune(obj map[string]any) map[string]any {
+	for key, value := range obj {
+		if s, ok := value.(*resource.Secret); ok {
+			value = s.Element.Mappable()
+			obj[key] = value
+		}
+		switch v := value.(type) {
+		case nil:
+			delete(obj, key)
+		case string:
+			if v == "" || key == "namespace" && v == renderNamespace {
+				delete(obj, key)
+			}
+		case map[string]any:
+			if obj[key] = prune(v); len(v) == 0 {
+				delete(obj, key)
+			}
+		case []any:
+			for i, item := range v {
+				if s, ok := item.(*resource.Secret); ok {
+					item = s.Element.Mappable()
+					v[i] = item
+				}
+				if m, ok := item.(map[string]any); ok {
+					prune(m)
+				}
+			}
+		}
+	}
+	return obj
+}
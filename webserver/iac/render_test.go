@@ -0,0 +1,151 @@
+package iac_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ctfer-io/romeo/sdk"
+	"github.com/ctfer-io/romeo/webserver/iac"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_U_RenderEnvironment(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Args            *sdk.RomeoEnvironmentArgs
+		ExpectErr       bool
+		ExpectKinds     []string
+		ExpectNamespace string
+	}{
+		"nil": {
+			Args:        nil,
+			ExpectKinds: []string{"Deployment", "PersistentVolumeClaim", "Service"},
+		},
+		"namespace-harden": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Namespace: pulumi.String("romeo"),
+				Harden:    true,
+			},
+			ExpectKinds:     []string{"Deployment", "NetworkPolicy", "PersistentVolumeClaim", "Service"},
+			ExpectNamespace: "romeo",
+		},
+		"ingress": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Expose: sdk.ExposeIngress,
+				Ingress: &sdk.RomeoIngressArgs{
+					Host: pulumi.String("romeo.example.com"),
+				},
+			},
+			ExpectKinds: []string{"Deployment", "Ingress", "PersistentVolumeClaim", "Service"},
+		},
+		"unsupported": {
+			Args: &sdk.RomeoEnvironmentArgs{
+				Expose: "Tunnel",
+			},
+			ExpectErr: true,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			manifests, err := iac.RenderEnvironment(tt.Args)
+			if tt.ExpectErr {
+				require.Error(err)
+				return
+			}
+			require.NoError(err)
+
+			kinds := []string{}
+			for _, manifest := range manifests {
+				kinds = append(kinds, manifest.GetKind())
+				assert.NotEmpty(manifest.GetName())
+				assert.Equal(tt.ExpectNamespace, manifest.GetNamespace())
+				assert.Equal(sdk.DefaultTTL, manifest.GetAnnotations()[sdk.TTLAnnotation])
+			}
+			assert.Equal(tt.ExpectKinds, kinds)
+		})
+	}
+}
+
+func Test_U_WriteManifests(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// Names are deterministic once the instance is defined
+	render := func() []byte {
+		manifests, err := iac.RenderEnvironment(&sdk.RomeoEnvironmentArgs{
+			Instance: pulumi.String("romeo"),
+		})
+		require.NoError(err)
+
+		buf := &bytes.Buffer{}
+		require.NoError(iac.WriteManifests(buf, manifests))
+		return buf.Bytes()
+	}
+	out := render()
+	assert.Equal(out, render())
+	assert.Len(strings.Split(string(out), "---\n"), 3)
+
+	// The Kustomize layout references all manifests
+	manifests, err := iac.RenderEnvironment(&sdk.RomeoEnvironmentArgs{
+		Instance: pulumi.String("romeo"),
+	})
+	require.NoError(err)
+	dir := t.TempDir()
+	require.NoError(iac.WriteKustomization(dir, manifests))
+
+	kust, err := os.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+	require.NoError(err)
+	for _, file := range []string{"deployment-romeo.yaml", "persistentvolumeclaim-romeo.yaml", "service-romeo.yaml"} {
+		assert.Contains(string(kust), file)
+		assert.FileExists(filepath.Join(dir, file))
+	}
+}
+
+func Test_U_RenderRegistryCredentials(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	manifests, err := iac.RenderEnvironment(&sdk.RomeoEnvironmentArgs{
+		Instance: pulumi.String("demo"),
+		Registry: pulumi.String("registry.example.com"),
+		RegistryCredentials: &sdk.RomeoRegistryCredentialsArgs{
+			Username: pulumi.String("u"),
+			// e.g. from a secret configuration
+			Password: pulumi.ToSecret(pulumi.String("p")).(pulumi.StringOutput),
+		},
+	})
+	require.NoError(err)
+
+	buf := &bytes.Buffer{}
+	require.NoError(iac.WriteManifests(buf, manifests))
+	docs := map[string]string{}
+	for _, doc := range strings.Split(buf.String(), "---\n") {
+		for _, line := range strings.Split(doc, "\n") {
+			if kind, ok := strings.CutPrefix(line, "kind: "); ok {
+				docs[kind] = doc
+			}
+		}
+	}
+
+	// The Secret is named after the instance and holds the docker config
+	require.Contains(docs, "Secret")
+	assert.Contains(docs["Secret"], "\n  name: demo\n")
+	assert.Contains(docs["Secret"], `.dockerconfigjson: '{"auths":{"registry.example.com":{"auth":"dTpw","password":"p","username":"u"}}}'`)
+	assert.NotContains(docs["Secret"], "Element")
+
+	// The Deployment pulls with it
+	require.Contains(docs, "Deployment")
+	assert.Contains(docs["Deployment"], "imagePullSecrets:\n      - name: demo\n")
+}
@@ -0,0 +1,38 @@
+package instrument
+
+import (
+	"github.com/ctfer-io/romeo/sdk/instrument"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The pods are instrumented as the SDK transformations do, such that they
+// could not drift.
+const (
+	// Label marks the pods to instrument for coverage, when set to "true".
+	Label = instrument.Label
+
+	// Annotation is set on the instrumented pods, such that they are not
+	// instrumented twice.
+	Annotation = instrument.Annotation
+
+	// DefaultMountPath is where the Romeo claim is mounted in the
+	// instrumented containers.
+	DefaultMountPath = instrument.DefaultMountPath
+
+	// VolumeName is the name of the Romeo claim volume.
+	VolumeName = instrument.VolumeName
+
+	// PodNameEnv is the environment variable containing the pod name, used
+	// to export the coverages in a per-pod subdirectory.
+	PodNameEnv = instrument.PodNameEnv
+)
+
+// Options to instrument pods with.
+type Options = instrument.Options
+
+// Pod instruments a pod, or a pod template, for coverage.
+// See [instrument.Pod].
+func Pod(meta *metav1.ObjectMeta, spec *corev1.PodSpec, opts Options) (bool, error) {
+	return instrument.Pod(meta, spec, opts)
+}
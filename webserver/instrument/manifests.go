@@ -0,0 +1,122 @@
+package instrument
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// templatePaths are the paths to the pod templates of the workloads
+// that could be instrumented, by kind.
+var templatePaths = map[string][]string{
+	"Deployment":  {"spec", "template"},
+	"StatefulSet": {"spec", "template"},
+	"Job":         {"spec", "template"},
+	"CronJob":     {"spec", "jobTemplate", "spec", "template"},
+}
+
+// ManifestsOptions to instrument Kubernetes manifests with.
+type ManifestsOptions struct {
+	Options
+
+	// Selector of the workloads to instrument, on their labels.
+	// If empty, instruments all the supported workloads.
+	Selector string
+}
+
+// Manifests reads the Kubernetes manifests (YAML or JSON, many documents
+// are supported) from r, instruments the pod templates of the selected
+// Deployments, StatefulSets, Jobs and CronJobs, and writes the result
+// to w as YAML.
+// Other documents are written as they are.
+func Manifests(r io.Reader, w io.Writer, opts ManifestsOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	sel, err := labels.Parse(opts.Selector)
+	if err != nil {
+		return errors.Wrapf(err, "parsing selector %s", opts.Selector)
+	}
+
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+	first := true
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "reading manifests")
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+
+		out, err := manifest(doc, sel, opts.Options)
+		if err != nil {
+			return err
+		}
+		if !first {
+			out = append([]byte("---\n"), out...)
+		}
+		first = false
+		if !bytes.HasSuffix(out, []byte("\n")) {
+			out = append(out, '\n')
+		}
+		if _, err := w.Write(out); err != nil {
+			return errors.Wrap(err, "writing manifests")
+		}
+	}
+}
+
+// manifest instruments a single document, if it is a selected workload.
+func manifest(doc []byte, sel labels.Selector, opts Options) ([]byte, error) {
+	obj := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal(doc, &obj.Object); err != nil {
+		return nil, errors.Wrap(err, "decoding manifest")
+	}
+	path, ok := templatePaths[obj.GetKind()]
+	if !ok || !sel.Matches(labels.Set(obj.GetLabels())) {
+		return doc, nil
+	}
+
+	raw, _, err := unstructured.NestedMap(obj.Object, path...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting pod template of %s %s", obj.GetKind(), obj.GetName())
+	}
+	tpl := &corev1.PodTemplateSpec{}
+	if err := convert(raw, tpl); err != nil {
+		return nil, errors.Wrapf(err, "decoding pod template of %s %s", obj.GetKind(), obj.GetName())
+	}
+	ok, err = Pod(&tpl.ObjectMeta, &tpl.Spec, opts)
+	if err != nil || !ok {
+		return doc, err
+	}
+
+	if err := convert(tpl, &raw); err != nil {
+		return nil, errors.Wrapf(err, "encoding pod template of %s %s", obj.GetKind(), obj.GetName())
+	}
+	// Don't pollute the manifest with a zero timestamp
+	unstructured.RemoveNestedField(raw, "metadata", "creationTimestamp")
+	if err := unstructured.SetNestedMap(obj.Object, raw, path...); err != nil {
+		return nil, errors.Wrapf(err, "setting pod template of %s %s", obj.GetKind(), obj.GetName())
+	}
+	return yaml.Marshal(obj.Object)
+}
+
+// convert an object into another through its JSON encoding.
+func convert(from, to any) error {
+	b, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, to)
+}
@@ -0,0 +1,122 @@
+package instrument_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ctfer-io/romeo/webserver/instrument"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+const manifests = `# Source: chart/templates/cm.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  key: value
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  labels:
+    app: app
+spec:
+  selector:
+    matchLabels:
+      app: app
+  template:
+    metadata:
+      labels:
+        app: app
+    spec:
+      containers:
+      - name: app
+        image: app
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: job
+spec:
+  schedule: "* * * * *"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: job
+            image: job
+          restartPolicy: Never
+`
+
+func Test_U_Manifests(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Options      instrument.ManifestsOptions
+		ExpectErr    bool
+		ExpectClaims int
+	}{
+		"no-claim": {
+			Options:   instrument.ManifestsOptions{},
+			ExpectErr: true,
+		},
+		"invalid-selector": {
+			Options: instrument.ManifestsOptions{
+				Options: instrument.Options{
+					ClaimName: "claim",
+				},
+				Selector: "app in (",
+			},
+			ExpectErr: true,
+		},
+		"all": {
+			Options: instrument.ManifestsOptions{
+				Options: instrument.Options{
+					ClaimName: "claim",
+				},
+			},
+			ExpectClaims: 2,
+		},
+		"selected": {
+			Options: instrument.ManifestsOptions{
+				Options: instrument.Options{
+					ClaimName: "claim",
+				},
+				Selector: "app=app",
+			},
+			ExpectClaims: 1,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			out := &bytes.Buffer{}
+			err := instrument.Manifests(strings.NewReader(manifests), out, tt.Options)
+			if tt.ExpectErr {
+				require.Error(err)
+				return
+			}
+			require.NoError(err)
+
+			// Documents are all kept, untouched ones as they were
+			docs := strings.Split(out.String(), "---\n")
+			require.Len(docs, 3)
+			assert.True(strings.HasPrefix(docs[0], "# Source: chart/templates/cm.yaml"))
+			for _, doc := range docs {
+				obj := &unstructured.Unstructured{}
+				require.NoError(yaml.Unmarshal([]byte(doc), &obj.Object))
+			}
+			assert.Equal(tt.ExpectClaims, strings.Count(out.String(), "claimName: claim"))
+		})
+	}
+}
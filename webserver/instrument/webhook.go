@@ -0,0 +1,134 @@
+package instrument
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	ginzap "github.com/gin-contrib/zap"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Webhook is the mutating admission webhook that instruments the pods
+// labelled with [Label] for coverage.
+// It is a standard [http.Handler], to serve over TLS.
+type Webhook struct {
+	router *gin.Engine
+	logger *zap.Logger
+	opts   Options
+}
+
+var _ http.Handler = (*Webhook)(nil)
+
+// NewWebhook constructs a fresh [*Webhook] instrumenting pods with the
+// given options. If logger is nil, defaults to a no-op one.
+func NewWebhook(opts Options, logger *zap.Logger) (*Webhook, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	router := gin.New()
+	router.Use(ginzap.Ginzap(logger, time.RFC3339, true))
+	router.Use(ginzap.RecoveryWithZap(logger, true))
+
+	wh := &Webhook{
+		router: router,
+		logger: logger,
+		opts:   opts,
+	}
+	router.POST("/mutate", wh.mutate)
+	router.GET("/healthz", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{
+			"status": "ok",
+		})
+	})
+	return wh, nil
+}
+
+func (wh *Webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	wh.router.ServeHTTP(w, r)
+}
+
+// patchOp is a JSON Patch (RFC 6902) operation.
+type patchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value"`
+}
+
+func (wh *Webhook) mutate(ctx *gin.Context) {
+	review := &admissionv1.AdmissionReview{}
+	if err := ctx.ShouldBindJSON(review); err != nil || review.Request == nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid admission review",
+		})
+		return
+	}
+
+	resp, err := wh.review(review.Request)
+	if err != nil {
+		// Never block the pod on an instrumentation failure
+		wh.logger.Error("instrumenting pod",
+			zap.String("namespace", review.Request.Namespace),
+			zap.String("uid", string(review.Request.UID)),
+			zap.Error(err),
+		)
+		resp = &admissionv1.AdmissionResponse{
+			Allowed:  true,
+			Warnings: []string{"romeo: " + err.Error()},
+		}
+	}
+	resp.UID = review.Request.UID
+
+	ctx.JSON(http.StatusOK, &admissionv1.AdmissionReview{
+		TypeMeta: review.TypeMeta,
+		Response: resp,
+	})
+}
+
+// review instruments the pod of an admission request, and returns the
+// response patching it accordingly.
+func (wh *Webhook) review(req *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, error) {
+	resp := &admissionv1.AdmissionResponse{
+		Allowed: true,
+	}
+	if req.Kind.Kind != "Pod" {
+		return resp, nil
+	}
+
+	pod := &corev1.Pod{}
+	if err := json.Unmarshal(req.Object.Raw, pod); err != nil {
+		return nil, errors.Wrap(err, "decoding pod")
+	}
+	ok, err := Pod(&pod.ObjectMeta, &pod.Spec, wh.opts)
+	if err != nil || !ok {
+		return resp, err
+	}
+
+	// Adding an existing member replaces it, so the patch holds whatever
+	// the pod already defines
+	patch, err := json.Marshal([]patchOp{
+		{Op: "add", Path: "/metadata/annotations", Value: pod.Annotations},
+		{Op: "add", Path: "/spec/volumes", Value: pod.Spec.Volumes},
+		{Op: "add", Path: "/spec/containers", Value: pod.Spec.Containers},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "encoding patch")
+	}
+	pt := admissionv1.PatchTypeJSONPatch
+	resp.Patch = patch
+	resp.PatchType = &pt
+
+	wh.logger.Info("pod instrumented",
+		zap.String("namespace", req.Namespace),
+		zap.String("generate_name", pod.GenerateName),
+	)
+	return resp, nil
+}
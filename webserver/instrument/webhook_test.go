@@ -0,0 +1,107 @@
+package instrument_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ctfer-io/romeo/webserver/instrument"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func Test_U_Webhook(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Kind        string
+		Pod         *corev1.Pod
+		ExpectPatch bool
+	}{
+		"pod": {
+			Kind: "Pod",
+			Pod: &corev1.Pod{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Name: "app"},
+					},
+				},
+			},
+			ExpectPatch: true,
+		},
+		"instrumented-pod": {
+			Kind: "Pod",
+			Pod: &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						instrument.Annotation: "true",
+					},
+				},
+			},
+			ExpectPatch: false,
+		},
+		"not-a-pod": {
+			Kind:        "Deployment",
+			Pod:         &corev1.Pod{},
+			ExpectPatch: false,
+		},
+	}
+
+	wh, err := instrument.NewWebhook(instrument.Options{
+		ClaimName: "claim",
+	}, nil)
+	require.NoError(t, err)
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			raw, err := json.Marshal(tt.Pod)
+			require.NoError(err)
+			b, err := json.Marshal(&admissionv1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "admission.k8s.io/v1",
+					Kind:       "AdmissionReview",
+				},
+				Request: &admissionv1.AdmissionRequest{
+					UID: "uid",
+					Kind: metav1.GroupVersionKind{
+						Kind: tt.Kind,
+					},
+					Object: runtime.RawExtension{
+						Raw: raw,
+					},
+				},
+			})
+			require.NoError(err)
+
+			rec := httptest.NewRecorder()
+			wh.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/mutate", bytes.NewReader(b)))
+			require.Equal(http.StatusOK, rec.Code)
+
+			review := &admissionv1.AdmissionReview{}
+			require.NoError(json.Unmarshal(rec.Body.Bytes(), review))
+			require.NotNil(review.Response)
+			assert.Equal("admission.k8s.io/v1", review.APIVersion)
+			assert.EqualValues("uid", review.Response.UID)
+			assert.True(review.Response.Allowed)
+			if !tt.ExpectPatch {
+				assert.Empty(review.Response.Patch)
+				return
+			}
+
+			require.NotNil(review.Response.PatchType)
+			assert.Equal(admissionv1.PatchTypeJSONPatch, *review.Response.PatchType)
+			ops := []map[string]any{}
+			require.NoError(json.Unmarshal(review.Response.Patch, &ops))
+			assert.Len(ops, 3)
+		})
+	}
+}
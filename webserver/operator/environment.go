@@ -0,0 +1,238 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ctfer-io/romeo/sdk/programs"
+	"github.com/ctfer-io/romeo/webserver/iac"
+	"github.com/ctfer-io/romeo/webserver/operator/v1alpha1"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	netwv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// FieldOwner is the field manager the operator applies the resources with.
+const FieldOwner = "romeo-operator"
+
+// webserverPort is the port the Romeo webserver Service listens on.
+const webserverPort = 8080
+
+// httpRouteGVK is the kind of the HTTPRoute exposing the webserver through
+// a Gateway, whose CRD may not be installed.
+var httpRouteGVK = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "HTTPRoute"}
+
+// ownedGVKs are the kinds of the resources a CoverageEnvironment may render,
+// thus the ones to prune once they are no longer rendered (e.g. the Ingress
+// after switching to a ClusterIP, or the NetworkPolicy once not hardened).
+var ownedGVKs = []schema.GroupVersionKind{
+	appsv1.SchemeGroupVersion.WithKind("Deployment"),
+	corev1.SchemeGroupVersion.WithKind("Service"),
+	corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"),
+	netwv1.SchemeGroupVersion.WithKind("NetworkPolicy"),
+	netwv1.SchemeGroupVersion.WithKind("Ingress"),
+	httpRouteGVK,
+}
+
+// EnvironmentReconciler reconciles the CoverageEnvironment resources into
+// the resources of a Romeo environment, as rendered from the
+// RomeoEnvironment component.
+type EnvironmentReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// SetupWithManager registers the reconciler in the manager.
+func (r *EnvironmentReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.CoverageEnvironment{}).
+		Owns(&appsv1.Deployment{}).
+		Owns(&corev1.Service{}).
+		Owns(&corev1.PersistentVolumeClaim{}).
+		Owns(&netwv1.NetworkPolicy{}).
+		Owns(&netwv1.Ingress{})
+	// Watching HTTPRoutes would block the manager start without the
+	// Gateway API CRDs, in which case they can't be rendered anyway.
+	if _, err := mgr.GetRESTMapper().RESTMapping(httpRouteGVK.GroupKind(), httpRouteGVK.Version); err == nil {
+		route := &unstructured.Unstructured{}
+		route.SetGroupVersionKind(httpRouteGVK)
+		b = b.Owns(route)
+	}
+	return b.
+		Named("coverageenvironment").
+		Complete(r)
+}
+
+// Reconcile applies the resources of the CoverageEnvironment, then reports
+// whether its webserver is ready.
+func (r *EnvironmentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	renv := &v1alpha1.CoverageEnvironment{}
+	if err := r.Get(ctx, req.NamespacedName, renv); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	manifests, err := Manifests(renv)
+	if err != nil {
+		// The spec is invalid, there is no point in retrying until it changes
+		setCondition(&renv.Status.Conditions, renv.Generation, v1alpha1.ConditionReady, metav1.ConditionFalse, "InvalidSpec", err.Error())
+		return ctrl.Result{}, r.updateStatus(ctx, renv)
+	}
+
+	for _, manifest := range manifests {
+		if err := controllerutil.SetControllerReference(renv, manifest, r.Scheme); err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "owning %s %s", manifest.GetKind(), manifest.GetName())
+		}
+		if err := r.Apply(ctx, client.ApplyConfigurationFromUnstructured(manifest), client.FieldOwner(FieldOwner), client.ForceOwnership); err != nil {
+			setCondition(&renv.Status.Conditions, renv.Generation, v1alpha1.ConditionReady, metav1.ConditionFalse, "ApplyFailed", err.Error())
+			if uerr := r.updateStatus(ctx, renv); uerr != nil {
+				return ctrl.Result{}, uerr
+			}
+			return ctrl.Result{}, errors.Wrapf(err, "applying %s %s", manifest.GetKind(), manifest.GetName())
+		}
+	}
+
+	if err := r.prune(ctx, renv, manifests); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	renv.Status.ClaimName = renv.Name
+	renv.Status.URL = fmt.Sprintf("http://%s.%s.svc.cluster.local:%d%s", renv.Name, renv.Namespace, webserverPort, basePath(manifests))
+
+	// The Deployment is owned, so its changes trigger a new reconciliation
+	dep := &appsv1.Deployment{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: renv.Namespace, Name: renv.Name}, dep); err != nil && !apierrors.IsNotFound(err) {
+		return ctrl.Result{}, errors.Wrap(err, "getting deployment")
+	}
+	if dep.Status.AvailableReplicas > 0 {
+		setCondition(&renv.Status.Conditions, renv.Generation, v1alpha1.ConditionReady, metav1.ConditionTrue, "Available", "Romeo webserver is available")
+	} else {
+		setCondition(&renv.Status.Conditions, renv.Generation, v1alpha1.ConditionReady, metav1.ConditionFalse, "Progressing", "Waiting for the Romeo webserver to be available")
+	}
+	return ctrl.Result{}, r.updateStatus(ctx, renv)
+}
+
+func (r *EnvironmentReconciler) updateStatus(ctx context.Context, renv *v1alpha1.CoverageEnvironment) error {
+	renv.Status.ObservedGeneration = renv.Generation
+	if err := r.Status().Update(ctx, renv); err != nil {
+		return errors.Wrap(err, "updating status")
+	}
+	return nil
+}
+
+// prune deletes the resources the CoverageEnvironment controls but that are
+// no longer rendered.
+func (r *EnvironmentReconciler) prune(ctx context.Context, renv *v1alpha1.CoverageEnvironment, manifests []*unstructured.Unstructured) error {
+	rendered := map[schema.GroupKind]map[string]struct{}{}
+	for _, manifest := range manifests {
+		gk := manifest.GroupVersionKind().GroupKind()
+		if rendered[gk] == nil {
+			rendered[gk] = map[string]struct{}{}
+		}
+		rendered[gk][manifest.GetName()] = struct{}{}
+	}
+
+	for _, gvk := range ownedGVKs {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := r.List(ctx, list, client.InNamespace(renv.Namespace), client.MatchingLabels{
+			"app.kubernetes.io/part-of": "romeo",
+			"instance":                  renv.Name,
+		}); err != nil {
+			if meta.IsNoMatchError(err) {
+				continue
+			}
+			return errors.Wrapf(err, "listing %s", gvk.Kind)
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			if _, ok := rendered[gvk.GroupKind()][obj.GetName()]; ok || !metav1.IsControlledBy(obj, renv) {
+				continue
+			}
+			if err := r.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+				return errors.Wrapf(err, "pruning %s %s", gvk.Kind, obj.GetName())
+			}
+		}
+	}
+	return nil
+}
+
+// Manifests renders the resources of the CoverageEnvironment, in its
+// namespace and named after it.
+func Manifests(renv *v1alpha1.CoverageEnvironment) ([]*unstructured.Unstructured, error) {
+	cfg := &programs.EnvironmentConfig{
+		Namespace:        renv.Namespace,
+		Harden:           renv.Spec.Harden,
+		Tag:              renv.Spec.Tag,
+		StorageClassName: renv.Spec.StorageClassName,
+		StorageSize:      renv.Spec.StorageSize,
+		ClaimName:        renv.Spec.ClaimName,
+		PVCAccessMode:    renv.Spec.PVCAccessMode,
+		Registry:         renv.Spec.Registry,
+		Expose:           renv.Spec.Expose,
+		NodeAddress:      renv.Spec.NodeAddress,
+		Instance:         renv.Name,
+		// The resources are owned thus collected along with the
+		// CoverageEnvironment, not by `romeo gc`
+		TTL: "0",
+	}
+	if ing := renv.Spec.Ingress; ing != nil {
+		cfg.IngressHost = ing.Host
+		cfg.IngressTLSSecretName = ing.TLSSecretName
+		cfg.IngressClassName = ing.ClassName
+	}
+	if gw := renv.Spec.Gateway; gw != nil {
+		cfg.GatewayName = gw.Name
+		cfg.GatewayNamespace = gw.Namespace
+		cfg.GatewaySectionName = gw.SectionName
+		cfg.GatewayHostname = gw.Hostname
+		cfg.GatewayPathPrefix = gw.PathPrefix
+		cfg.GatewayAddress = gw.Address
+	}
+	return iac.RenderEnvironment(cfg.Args())
+}
+
+// basePath returns the path the webserver serves under, as configured in
+// its Deployment.
+func basePath(manifests []*unstructured.Unstructured) string {
+	for _, manifest := range manifests {
+		if manifest.GetKind() != "Deployment" {
+			continue
+		}
+		containers, _, _ := unstructured.NestedSlice(manifest.Object, "spec", "template", "spec", "containers")
+		for _, container := range containers {
+			c, ok := container.(map[string]any)
+			if !ok {
+				continue
+			}
+			envs, _, _ := unstructured.NestedSlice(c, "env")
+			for _, env := range envs {
+				if e, ok := env.(map[string]any); ok && e["name"] == "BASE_PATH" {
+					value, _ := e["value"].(string)
+					return value
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func setCondition(conditions *[]metav1.Condition, generation int64, typ string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:               typ,
+		Status:             status,
+		ObservedGeneration: generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
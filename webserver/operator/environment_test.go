@@ -0,0 +1,245 @@
+package operator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ctfer-io/romeo/webserver/operator"
+	"github.com/ctfer-io/romeo/webserver/operator/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	netwv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func Test_U_EnvironmentReconcile(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Spec            v1alpha1.CoverageEnvironmentSpec
+		Available       bool
+		ExpectReady     metav1.ConditionStatus
+		ExpectReason    string
+		ExpectURL       string
+		ExpectNetpol    bool
+		ExpectResources bool
+	}{
+		"progressing": {
+			Spec:            v1alpha1.CoverageEnvironmentSpec{},
+			ExpectReady:     metav1.ConditionFalse,
+			ExpectReason:    "Progressing",
+			ExpectURL:       "http://cov.romeo.svc.cluster.local:8080",
+			ExpectResources: true,
+		},
+		"available-harden": {
+			Spec: v1alpha1.CoverageEnvironmentSpec{
+				Harden: true,
+				Expose: "ClusterIP",
+			},
+			Available:       true,
+			ExpectReady:     metav1.ConditionTrue,
+			ExpectReason:    "Available",
+			ExpectURL:       "http://cov.romeo.svc.cluster.local:8080",
+			ExpectNetpol:    true,
+			ExpectResources: true,
+		},
+		"invalid": {
+			Spec: v1alpha1.CoverageEnvironmentSpec{
+				Expose: "Ingress",
+			},
+			ExpectReady:  metav1.ConditionFalse,
+			ExpectReason: "InvalidSpec",
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			assert := assert.New(t)
+			require := require.New(t)
+
+			scheme, err := operator.NewScheme()
+			require.NoError(err)
+
+			renv := &v1alpha1.CoverageEnvironment{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "romeo",
+					Name:      "cov",
+				},
+				Spec: tt.Spec,
+			}
+			objs := []client.Object{renv}
+			if tt.Available {
+				objs = append(objs, &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "romeo",
+						Name:      "cov",
+					},
+					Status: appsv1.DeploymentStatus{
+						AvailableReplicas: 1,
+					},
+				})
+			}
+			c := fake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(objs...).
+				WithStatusSubresource(&v1alpha1.CoverageEnvironment{}).
+				Build()
+
+			r := &operator.EnvironmentReconciler{
+				Client: c,
+				Scheme: scheme,
+			}
+			key := types.NamespacedName{Namespace: "romeo", Name: "cov"}
+			_, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			require.NoError(err)
+
+			got := &v1alpha1.CoverageEnvironment{}
+			require.NoError(c.Get(context.Background(), key, got))
+			cond := meta.FindStatusCondition(got.Status.Conditions, v1alpha1.ConditionReady)
+			require.NotNil(cond)
+			assert.Equal(tt.ExpectReady, cond.Status)
+			assert.Equal(tt.ExpectReason, cond.Reason)
+			assert.Equal(tt.ExpectURL, got.Status.URL)
+
+			pvc := &corev1.PersistentVolumeClaim{}
+			err = c.Get(context.Background(), key, pvc)
+			if !tt.ExpectResources {
+				assert.Error(err)
+				return
+			}
+			require.NoError(err)
+			assert.Equal("cov", got.Status.ClaimName)
+			require.Len(pvc.OwnerReferences, 1)
+			assert.Equal("CoverageEnvironment", pvc.OwnerReferences[0].Kind)
+
+			svc := &corev1.Service{}
+			assert.NoError(c.Get(context.Background(), key, svc))
+
+			netpol := &netwv1.NetworkPolicy{}
+			err = c.Get(context.Background(), key, netpol)
+			if tt.ExpectNetpol {
+				assert.NoError(err)
+			} else {
+				assert.Error(err)
+			}
+		})
+	}
+}
+
+func Test_U_EnvironmentPrune(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Spec         v1alpha1.CoverageEnvironmentSpec
+		Owned        bool
+		ExpectPruned bool
+	}{
+		"stale": {
+			Spec: v1alpha1.CoverageEnvironmentSpec{
+				Expose: "ClusterIP",
+			},
+			Owned:        true,
+			ExpectPruned: true,
+		},
+		"rendered": {
+			Spec: v1alpha1.CoverageEnvironmentSpec{
+				Harden: true,
+				Expose: "Ingress",
+				Ingress: &v1alpha1.IngressSpec{
+					Host: "romeo.example.com",
+				},
+			},
+			Owned:        true,
+			ExpectPruned: false,
+		},
+		"not-owned": {
+			Spec: v1alpha1.CoverageEnvironmentSpec{
+				Expose: "ClusterIP",
+			},
+			Owned:        false,
+			ExpectPruned: false,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			assert := assert.New(t)
+			require := require.New(t)
+
+			scheme, err := operator.NewScheme()
+			require.NoError(err)
+
+			renv := &v1alpha1.CoverageEnvironment{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "romeo",
+					Name:      "cov",
+					UID:       "cov-uid",
+				},
+				Spec: tt.Spec,
+			}
+			labels := map[string]string{
+				"app.kubernetes.io/part-of": "romeo",
+				"instance":                  "cov",
+			}
+			// Left over from when the environment was exposed through an
+			// Ingress and hardened
+			ing := &netwv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "romeo",
+					Name:      "cov",
+					Labels:    labels,
+				},
+			}
+			netpol := &netwv1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "romeo",
+					Name:      "cov",
+					Labels:    labels,
+				},
+			}
+			if tt.Owned {
+				require.NoError(controllerutil.SetControllerReference(renv, ing, scheme))
+				require.NoError(controllerutil.SetControllerReference(renv, netpol, scheme))
+			}
+			c := fake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(renv, ing, netpol).
+				WithStatusSubresource(&v1alpha1.CoverageEnvironment{}).
+				Build()
+
+			r := &operator.EnvironmentReconciler{
+				Client: c,
+				Scheme: scheme,
+			}
+			key := types.NamespacedName{Namespace: "romeo", Name: "cov"}
+			_, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			require.NoError(err)
+
+			for _, obj := range []client.Object{&netwv1.Ingress{}, &netwv1.NetworkPolicy{}} {
+				err := c.Get(context.Background(), key, obj)
+				if tt.ExpectPruned {
+					assert.True(apierrors.IsNotFound(err))
+				} else {
+					assert.NoError(err)
+				}
+			}
+
+			// The rendered resources are kept
+			assert.NoError(c.Get(context.Background(), key, &corev1.Service{}))
+			assert.NoError(c.Get(context.Background(), key, &corev1.PersistentVolumeClaim{}))
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Package operator reconciles the romeo.ctfer.io custom resources:
+// CoverageEnvironment into the resources of a Romeo environment, and
+// CoverageSnapshot into a ConfigMap holding the merged coverages.
+package operator
+
+import (
+	"context"
+
+	"github.com/ctfer-io/romeo/webserver/operator/v1alpha1"
+	"github.com/go-logr/zapr"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+// Options of the operator.
+type Options struct {
+	// HealthProbeAddress to serve the /healthz and /readyz probes on.
+	HealthProbeAddress string
+
+	// MetricsAddress to serve the Prometheus metrics on, "0" to disable.
+	MetricsAddress string
+
+	// LeaderElection ensures a single operator replica reconciles at a time.
+	LeaderElection bool
+
+	// LeaderElectionNamespace is the namespace of the leader election
+	// lease. Defaults to the in-cluster one.
+	LeaderElectionNamespace string
+}
+
+// NewScheme returns the scheme of the resources the operator manages.
+func NewScheme() (*runtime.Scheme, error) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	return scheme, nil
+}
+
+// Run runs the operator until the context is done.
+func Run(ctx context.Context, opts Options, logger *zap.Logger) error {
+	ctrl.SetLogger(zapr.NewLogger(logger))
+
+	scheme, err := NewScheme()
+	if err != nil {
+		return errors.Wrap(err, "building scheme")
+	}
+	cfg, err := ctrl.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "getting kubeconfig")
+	}
+
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:                  scheme,
+		HealthProbeBindAddress:  opts.HealthProbeAddress,
+		Metrics:                 metricsserver.Options{BindAddress: opts.MetricsAddress},
+		LeaderElection:          opts.LeaderElection,
+		LeaderElectionID:        "operator.romeo.ctfer.io",
+		LeaderElectionNamespace: opts.LeaderElectionNamespace,
+	})
+	if err != nil {
+		return errors.Wrap(err, "creating manager")
+	}
+
+	if err := (&EnvironmentReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		return errors.Wrap(err, "setting up CoverageEnvironment controller")
+	}
+	if err := (&SnapshotReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		return errors.Wrap(err, "setting up CoverageSnapshot controller")
+	}
+
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		return errors.Wrap(err, "adding health check")
+	}
+	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+		return errors.Wrap(err, "adding ready check")
+	}
+
+	return mgr.Start(ctx)
+}
@@ -0,0 +1,139 @@
+package operator
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"time"
+
+	apiv1 "github.com/ctfer-io/romeo/webserver/api/v1"
+	"github.com/ctfer-io/romeo/webserver/operator/v1alpha1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// SnapshotKey is the key of the merged coverages (zip archive) in the
+	// snapshot ConfigMap binary data.
+	SnapshotKey = "coverout.zip"
+
+	// maxSnapshotSize is the size a ConfigMap can hold, minus a margin for
+	// its metadata.
+	maxSnapshotSize = 1<<20 - 16<<10
+
+	// snapshotRetry is the delay to check again for the environment to be
+	// ready.
+	snapshotRetry = 10 * time.Second
+)
+
+// SnapshotReconciler reconciles the CoverageSnapshot resources, merging the
+// coverages of their environment into a ConfigMap.
+type SnapshotReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// HTTPClient to reach the environments webservers with.
+	// Defaults to [http.DefaultClient].
+	HTTPClient *http.Client
+}
+
+// SetupWithManager registers the reconciler in the manager.
+func (r *SnapshotReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.CoverageSnapshot{}).
+		Owns(&corev1.ConfigMap{}).
+		Named("coveragesnapshot").
+		Complete(r)
+}
+
+// Reconcile merges the coverages of the environment once it is ready, and
+// stores them. A completed (or failed) snapshot is not reconciled again.
+func (r *SnapshotReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	snap := &v1alpha1.CoverageSnapshot{}
+	if err := r.Get(ctx, req.NamespacedName, snap); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if cond := meta.FindStatusCondition(snap.Status.Conditions, v1alpha1.ConditionComplete); cond != nil && cond.Status != metav1.ConditionUnknown {
+		return ctrl.Result{}, nil
+	}
+
+	// Wait for the environment to be ready
+	renv := &v1alpha1.CoverageEnvironment{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: snap.Namespace, Name: snap.Spec.Environment}, renv); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, errors.Wrap(err, "getting environment")
+		}
+		return r.wait(ctx, snap, "EnvironmentNotFound", fmt.Sprintf("CoverageEnvironment %s not found", snap.Spec.Environment))
+	}
+	if !meta.IsStatusConditionTrue(renv.Status.Conditions, v1alpha1.ConditionReady) || renv.Status.URL == "" {
+		return r.wait(ctx, snap, "EnvironmentNotReady", fmt.Sprintf("CoverageEnvironment %s is not ready", snap.Spec.Environment))
+	}
+
+	// Merge the coverages
+	resp, err := apiv1.NewClient(renv.Status.URL, r.HTTPClient).Coverout(ctx)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "merging coverages")
+	}
+	raw, err := base64.StdEncoding.DecodeString(resp.Merged)
+	if err != nil {
+		return r.fail(ctx, snap, "InvalidCoverages", err.Error())
+	}
+	if len(raw) > maxSnapshotSize {
+		return r.fail(ctx, snap, "TooLarge", fmt.Sprintf("merged coverages (%d bytes) exceed the ConfigMap capacity", len(raw)))
+	}
+
+	// Store them
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: snap.Namespace,
+			Name:      snap.Name,
+		},
+	}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, cm, func() error {
+		cm.Labels = map[string]string{
+			"app.kubernetes.io/component": "snapshot",
+			"app.kubernetes.io/part-of":   "romeo",
+			"instance":                    renv.Name,
+		}
+		cm.BinaryData = map[string][]byte{
+			SnapshotKey: raw,
+		}
+		return controllerutil.SetControllerReference(snap, cm, r.Scheme)
+	}); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "storing coverages")
+	}
+
+	now := metav1.Now()
+	snap.Status.ConfigMapName = cm.Name
+	snap.Status.CompletionTime = &now
+	setCondition(&snap.Status.Conditions, snap.Generation, v1alpha1.ConditionComplete, metav1.ConditionTrue, "Stored", fmt.Sprintf("Merged coverages stored in ConfigMap %s", cm.Name))
+	return ctrl.Result{}, r.updateStatus(ctx, snap)
+}
+
+// wait reports the snapshot is pending, and checks again later.
+func (r *SnapshotReconciler) wait(ctx context.Context, snap *v1alpha1.CoverageSnapshot, reason, message string) (ctrl.Result, error) {
+	setCondition(&snap.Status.Conditions, snap.Generation, v1alpha1.ConditionComplete, metav1.ConditionUnknown, reason, message)
+	return ctrl.Result{RequeueAfter: snapshotRetry}, r.updateStatus(ctx, snap)
+}
+
+// fail reports the snapshot failed for good.
+func (r *SnapshotReconciler) fail(ctx context.Context, snap *v1alpha1.CoverageSnapshot, reason, message string) (ctrl.Result, error) {
+	setCondition(&snap.Status.Conditions, snap.Generation, v1alpha1.ConditionComplete, metav1.ConditionFalse, reason, message)
+	return ctrl.Result{}, r.updateStatus(ctx, snap)
+}
+
+func (r *SnapshotReconciler) updateStatus(ctx context.Context, snap *v1alpha1.CoverageSnapshot) error {
+	if err := r.Status().Update(ctx, snap); err != nil {
+		return errors.Wrap(err, "updating status")
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package operator_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apiv1 "github.com/ctfer-io/romeo/webserver/api/v1"
+	"github.com/ctfer-io/romeo/webserver/operator"
+	"github.com/ctfer-io/romeo/webserver/operator/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_U_SnapshotReconcile(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Environment    *v1alpha1.CoverageEnvironmentStatus
+		Merged         string
+		ExpectComplete metav1.ConditionStatus
+		ExpectReason   string
+		ExpectRequeue  bool
+		ExpectData     []byte
+	}{
+		"environment-not-found": {
+			Environment:    nil,
+			ExpectComplete: metav1.ConditionUnknown,
+			ExpectReason:   "EnvironmentNotFound",
+			ExpectRequeue:  true,
+		},
+		"environment-not-ready": {
+			Environment: &v1alpha1.CoverageEnvironmentStatus{
+				Conditions: []metav1.Condition{
+					{Type: v1alpha1.ConditionReady, Status: metav1.ConditionFalse, Reason: "Progressing"},
+				},
+			},
+			ExpectComplete: metav1.ConditionUnknown,
+			ExpectReason:   "EnvironmentNotReady",
+			ExpectRequeue:  true,
+		},
+		"stored": {
+			Environment: &v1alpha1.CoverageEnvironmentStatus{
+				Conditions: []metav1.Condition{
+					{Type: v1alpha1.ConditionReady, Status: metav1.ConditionTrue, Reason: "Available"},
+				},
+			},
+			Merged:         "cm9tZW8=",
+			ExpectComplete: metav1.ConditionTrue,
+			ExpectReason:   "Stored",
+			ExpectData:     []byte("romeo"),
+		},
+		"invalid-coverages": {
+			Environment: &v1alpha1.CoverageEnvironmentStatus{
+				Conditions: []metav1.Condition{
+					{Type: v1alpha1.ConditionReady, Status: metav1.ConditionTrue, Reason: "Available"},
+				},
+			},
+			Merged:         "not base 64",
+			ExpectComplete: metav1.ConditionFalse,
+			ExpectReason:   "InvalidCoverages",
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			assert := assert.New(t)
+			require := require.New(t)
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_ = json.NewEncoder(w).Encode(apiv1.CoveroutResponse{Merged: tt.Merged})
+			}))
+			defer srv.Close()
+
+			scheme, err := operator.NewScheme()
+			require.NoError(err)
+
+			objs := []client.Object{
+				&v1alpha1.CoverageSnapshot{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "romeo",
+						Name:      "snap",
+					},
+					Spec: v1alpha1.CoverageSnapshotSpec{
+						Environment: "cov",
+					},
+				},
+			}
+			if tt.Environment != nil {
+				status := *tt.Environment
+				status.URL = srv.URL
+				objs = append(objs, &v1alpha1.CoverageEnvironment{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: "romeo",
+						Name:      "cov",
+					},
+					Status: status,
+				})
+			}
+			c := fake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(objs...).
+				WithStatusSubresource(&v1alpha1.CoverageSnapshot{}).
+				Build()
+
+			r := &operator.SnapshotReconciler{
+				Client:     c,
+				Scheme:     scheme,
+				HTTPClient: srv.Client(),
+			}
+			key := types.NamespacedName{Namespace: "romeo", Name: "snap"}
+			res, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			require.NoError(err)
+			assert.Equal(tt.ExpectRequeue, res.RequeueAfter != 0)
+
+			got := &v1alpha1.CoverageSnapshot{}
+			require.NoError(c.Get(context.Background(), key, got))
+			cond := meta.FindStatusCondition(got.Status.Conditions, v1alpha1.ConditionComplete)
+			require.NotNil(cond)
+			assert.Equal(tt.ExpectComplete, cond.Status)
+			assert.Equal(tt.ExpectReason, cond.Reason)
+
+			cm := &corev1.ConfigMap{}
+			err = c.Get(context.Background(), key, cm)
+			if tt.ExpectData == nil {
+				assert.Error(err)
+				return
+			}
+			require.NoError(err)
+			assert.Equal("snap", got.Status.ConfigMapName)
+			assert.NotNil(got.Status.CompletionTime)
+			assert.Equal(tt.ExpectData, cm.BinaryData[operator.SnapshotKey])
+		})
+	}
+}
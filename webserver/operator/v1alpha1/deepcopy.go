@@ -0,0 +1,149 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto copies the receiver into out.
+func (in *CoverageEnvironment) DeepCopyInto(out *CoverageEnvironment) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy returns a deep copy of the receiver.
+func (in *CoverageEnvironment) DeepCopy() *CoverageEnvironment {
+	if in == nil {
+		return nil
+	}
+	out := new(CoverageEnvironment)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject implements [runtime.Object].
+func (in *CoverageEnvironment) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
+
+// DeepCopyInto copies the receiver into out.
+func (in *CoverageEnvironmentSpec) DeepCopyInto(out *CoverageEnvironmentSpec) {
+	*out = *in
+	if in.Ingress != nil {
+		out.Ingress = new(IngressSpec)
+		*out.Ingress = *in.Ingress
+	}
+	if in.Gateway != nil {
+		out.Gateway = new(GatewaySpec)
+		*out.Gateway = *in.Gateway
+	}
+}
+
+// DeepCopyInto copies the receiver into out.
+func (in *CoverageEnvironmentStatus) DeepCopyInto(out *CoverageEnvironmentStatus) {
+	*out = *in
+	out.Conditions = copyConditions(in.Conditions)
+}
+
+// DeepCopyInto copies the receiver into out.
+func (in *CoverageEnvironmentList) DeepCopyInto(out *CoverageEnvironmentList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		out.Items = make([]CoverageEnvironment, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyInto(&out.Items[i])
+		}
+	}
+}
+
+// DeepCopy returns a deep copy of the receiver.
+func (in *CoverageEnvironmentList) DeepCopy() *CoverageEnvironmentList {
+	if in == nil {
+		return nil
+	}
+	out := new(CoverageEnvironmentList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject implements [runtime.Object].
+func (in *CoverageEnvironmentList) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
+
+// DeepCopyInto copies the receiver into out.
+func (in *CoverageSnapshot) DeepCopyInto(out *CoverageSnapshot) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy returns a deep copy of the receiver.
+func (in *CoverageSnapshot) DeepCopy() *CoverageSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(CoverageSnapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject implements [runtime.Object].
+func (in *CoverageSnapshot) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
+
+// DeepCopyInto copies the receiver into out.
+func (in *CoverageSnapshotStatus) DeepCopyInto(out *CoverageSnapshotStatus) {
+	*out = *in
+	out.Conditions = copyConditions(in.Conditions)
+	if in.CompletionTime != nil {
+		out.CompletionTime = in.CompletionTime.DeepCopy()
+	}
+}
+
+// DeepCopyInto copies the receiver into out.
+func (in *CoverageSnapshotList) DeepCopyInto(out *CoverageSnapshotList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		out.Items = make([]CoverageSnapshot, len(in.Items))
+		for i := range in.Items {
+			in.Items[i].DeepCopyInto(&out.Items[i])
+		}
+	}
+}
+
+// DeepCopy returns a deep copy of the receiver.
+func (in *CoverageSnapshotList) DeepCopy() *CoverageSnapshotList {
+	if in == nil {
+		return nil
+	}
+	out := new(CoverageSnapshotList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject implements [runtime.Object].
+func (in *CoverageSnapshotList) DeepCopyObject() runtime.Object {
+	return in.DeepCopy()
+}
+
+func copyConditions(in []metav1.Condition) []metav1.Condition {
+	if in == nil {
+		return nil
+	}
+	out := make([]metav1.Condition, len(in))
+	for i := range in {
+		in[i].DeepCopyInto(&out[i])
+	}
+	return out
+}
@@ -0,0 +1,26 @@
+// Package v1alpha1 contains the romeo.ctfer.io/v1alpha1 API, reconciled by
+// the Romeo operator.
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/scheme"
+)
+
+var (
+	// GroupVersion is the group and version of the API.
+	GroupVersion = schema.GroupVersion{Group: "romeo.ctfer.io", Version: "v1alpha1"}
+
+	// SchemeBuilder registers the API types in a scheme.
+	SchemeBuilder = &scheme.Builder{GroupVersion: GroupVersion}
+
+	// AddToScheme adds the API types to a scheme.
+	AddToScheme = SchemeBuilder.AddToScheme
+)
+
+func init() {
+	SchemeBuilder.Register(
+		&CoverageEnvironment{}, &CoverageEnvironmentList{},
+		&CoverageSnapshot{}, &CoverageSnapshotList{},
+	)
+}
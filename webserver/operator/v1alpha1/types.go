@@ -0,0 +1,145 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ConditionReady reports whether a CoverageEnvironment webserver is
+	// available.
+	ConditionReady = "Ready"
+
+	// ConditionComplete reports whether a CoverageSnapshot merged and
+	// stored the coverages.
+	ConditionComplete = "Complete"
+)
+
+// CoverageEnvironment is a Romeo environment: the PersistentVolumeClaim
+// to export coverages into, and the webserver to merge them.
+// Its resources are named after it, in its namespace.
+type CoverageEnvironment struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CoverageEnvironmentSpec   `json:"spec,omitempty"`
+	Status CoverageEnvironmentStatus `json:"status,omitempty"`
+}
+
+// CoverageEnvironmentSpec mirrors the configuration of the environment
+// program, but the namespace and instance that are the resource ones.
+type CoverageEnvironmentSpec struct {
+	// Harden grants the webserver access through a NetworkPolicy.
+	Harden bool `json:"harden,omitempty"`
+
+	// Tag of the Romeo webserver Docker image.
+	Tag string `json:"tag,omitempty"`
+
+	// Registry to download the Romeo images from.
+	Registry string `json:"registry,omitempty"`
+
+	// StorageClassName of the PersistentVolumeClaim.
+	StorageClassName string `json:"storageClassName,omitempty"`
+
+	// StorageSize of the PersistentVolumeClaim.
+	StorageSize string `json:"storageSize,omitempty"`
+
+	// ClaimName, if defined, turns on the webserver coverage export in the
+	// given PersistentVolumeClaim.
+	ClaimName string `json:"claimName,omitempty"`
+
+	// PVCAccessMode of the PersistentVolumeClaim.
+	PVCAccessMode string `json:"pvcAccessMode,omitempty"`
+
+	// Expose defines how to expose the webserver, either ClusterIP,
+	// NodePort, LoadBalancer, Ingress or Gateway.
+	Expose string `json:"expose,omitempty"`
+
+	// NodeAddress the Kubernetes nodes are reachable at, when exposed
+	// through a NodePort.
+	NodeAddress string `json:"nodeAddress,omitempty"`
+
+	// Ingress to expose the webserver through.
+	Ingress *IngressSpec `json:"ingress,omitempty"`
+
+	// Gateway to expose the webserver through.
+	Gateway *GatewaySpec `json:"gateway,omitempty"`
+}
+
+// IngressSpec defines the Ingress exposing the webserver.
+type IngressSpec struct {
+	Host          string `json:"host"`
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+	ClassName     string `json:"className,omitempty"`
+}
+
+// GatewaySpec defines the Gateway the webserver HTTPRoute attaches to.
+type GatewaySpec struct {
+	Name        string `json:"name"`
+	Namespace   string `json:"namespace,omitempty"`
+	SectionName string `json:"sectionName,omitempty"`
+	Hostname    string `json:"hostname,omitempty"`
+	PathPrefix  string `json:"pathPrefix,omitempty"`
+	Address     string `json:"address,omitempty"`
+}
+
+// CoverageEnvironmentStatus is the observed state of a CoverageEnvironment.
+type CoverageEnvironmentStatus struct {
+	// ObservedGeneration is the last generation reconciled.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions of the environment, i.e. [ConditionReady].
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// URL to reach the webserver at from within the cluster.
+	URL string `json:"url,omitempty"`
+
+	// ClaimName of the PersistentVolumeClaim to export the coverages into,
+	// i.e. to mount in the workloads.
+	ClaimName string `json:"claimName,omitempty"`
+}
+
+// CoverageEnvironmentList is a list of CoverageEnvironment.
+type CoverageEnvironmentList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []CoverageEnvironment `json:"items"`
+}
+
+// CoverageSnapshot merges the coverages of a CoverageEnvironment, and stores
+// the result in a ConfigMap named after it.
+// It is reconciled once: create another one for a new snapshot.
+type CoverageSnapshot struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CoverageSnapshotSpec   `json:"spec,omitempty"`
+	Status CoverageSnapshotStatus `json:"status,omitempty"`
+}
+
+// CoverageSnapshotSpec defines the environment to snapshot.
+type CoverageSnapshotSpec struct {
+	// Environment is the name of the CoverageEnvironment, in the same
+	// namespace.
+	Environment string `json:"environment"`
+}
+
+// CoverageSnapshotStatus is the observed state of a CoverageSnapshot.
+type CoverageSnapshotStatus struct {
+	// Conditions of the snapshot, i.e. [ConditionComplete].
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// ConfigMapName of the ConfigMap holding the merged coverages.
+	ConfigMapName string `json:"configMapName,omitempty"`
+
+	// CompletionTime is when the coverages were stored.
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// CoverageSnapshotList is a list of CoverageSnapshot.
+type CoverageSnapshotList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []CoverageSnapshot `json:"items"`
+}
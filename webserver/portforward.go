@@ -0,0 +1,304 @@
+package webserver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// DefaultSelector is the label selector of the Romeo environments
+// resources, as set by RomeoEnvironment.
+// It requires the instance label, such that the other Romeo resources of
+// the namespace (e.g. the webhook Service) are not matched.
+const DefaultSelector = "app.kubernetes.io/part-of=romeo,instance"
+
+// PortForwardOptions to reach a Romeo environment through the Kubernetes
+// API server.
+type PortForwardOptions struct {
+	// Kubeconfig is either the path to a kubeconfig file or its content.
+	// If empty, uses the default loading rules (e.g. KUBECONFIG).
+	Kubeconfig string
+
+	// Namespace the Romeo environment lays into.
+	// Defaults to the kubeconfig context namespace.
+	Namespace string
+
+	// Selector to find the Romeo Service with. Defaults to [DefaultSelector].
+	Selector string
+
+	// Instance of the Romeo environment, as labelled by RomeoEnvironment.
+	// Required when many environments live in the namespace.
+	Instance string
+}
+
+// PortForward discovers the Romeo Service, and opens a port-forward to
+// one of its pods through the Kubernetes API server.
+// It returns the local URL to reach the Romeo webserver at, under its base
+// path if any (e.g. when exposed through a Gateway), and a function to
+// close the port-forward once done.
+func PortForward(ctx context.Context, opts *PortForwardOptions) (string, func(), error) {
+	if opts == nil {
+		opts = &PortForwardOptions{}
+	}
+
+	cfg, ns, err := loadKubeconfig(opts.Kubeconfig, opts.Namespace)
+	if err != nil {
+		return "", nil, err
+	}
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "building Kubernetes client")
+	}
+
+	// Discover the Romeo Service, then the pod and port to forward to
+	svc, err := findService(ctx, client, ns, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	pod, podPort, err := findPod(ctx, client, svc)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Open the port-forward on a random local port
+	transport, upgrader, err := spdy.RoundTripperFor(cfg)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "building port-forward transport")
+	}
+	pfURL := client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("portforward").
+		URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, pfURL)
+
+	stop := make(chan struct{})
+	ready := make(chan struct{})
+	fw, err := portforward.NewOnAddresses(
+		dialer,
+		[]string{"127.0.0.1"},
+		[]string{fmt.Sprintf("0:%d", podPort)},
+		stop, ready,
+		io.Discard, io.Discard,
+	)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "creating port-forward")
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- fw.ForwardPorts()
+	}()
+
+	select {
+	case <-ready:
+	case err := <-errs:
+		return "", nil, errors.Wrap(err, "port-forwarding")
+	case <-ctx.Done():
+		close(stop)
+		return "", nil, ctx.Err()
+	}
+
+	ports, err := fw.GetPorts()
+	if err != nil {
+		close(stop)
+		return "", nil, errors.Wrap(err, "getting forwarded port")
+	}
+	if len(ports) != 1 {
+		close(stop)
+		return "", nil, fmt.Errorf("expected a single forwarded port, got %d", len(ports))
+	}
+	Logger.Info("port-forwarding to Romeo",
+		zap.String("namespace", pod.Namespace),
+		zap.String("pod", pod.Name),
+		zap.Uint16("local_port", ports[0].Local),
+	)
+
+	return fmt.Sprintf("http://127.0.0.1:%d%s", ports[0].Local, podBasePath(pod)), func() {
+		close(stop)
+	}, nil
+}
+
+// RESTConfig loads the REST configuration from the kubeconfig (either a
+// path or the content). If empty, follows the Kubernetes loading rules, then
+// falls back to the in-cluster configuration.
+func RESTConfig(kubeconfig string) (*rest.Config, error) {
+	cc, err := clientConfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := cc.ClientConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "building REST configuration")
+	}
+	return cfg, nil
+}
+
+// loadKubeconfig loads the REST configuration and the namespace to use.
+func loadKubeconfig(kubeconfig, namespace string) (*rest.Config, string, error) {
+	cc, err := clientConfig(kubeconfig)
+	if err != nil {
+		return nil, "", err
+	}
+
+	cfg, err := cc.ClientConfig()
+	if err != nil {
+		return nil, "", errors.Wrap(err, "building REST configuration")
+	}
+	if namespace == "" {
+		namespace, _, err = cc.Namespace()
+		if err != nil {
+			return nil, "", errors.Wrap(err, "getting kubeconfig namespace")
+		}
+	}
+	return cfg, namespace, nil
+}
+
+func clientConfig(kubeconfig string) (clientcmd.ClientConfig, error) {
+	if kubeconfig == "" {
+		return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			clientcmd.NewDefaultClientConfigLoadingRules(),
+			&clientcmd.ConfigOverrides{},
+		), nil
+	}
+
+	raw := []byte(kubeconfig)
+	// The kubeconfig is either a path or the content, as for the Actions
+	//nolint:gosec // G304 -- the kubeconfig path is user-provided on purpose
+	if b, err := os.ReadFile(kubeconfig); err == nil {
+		raw = b
+	}
+	cc, err := clientcmd.NewClientConfigFromBytes(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading kubeconfig")
+	}
+	return cc, nil
+}
+
+// findService looks for the single Romeo Service matching the options.
+func findService(
+	ctx context.Context,
+	client kubernetes.Interface,
+	ns string,
+	opts *PortForwardOptions,
+) (*corev1.Service, error) {
+	selector := opts.Selector
+	if selector == "" {
+		selector = DefaultSelector
+	}
+	if opts.Instance != "" {
+		selector += ",instance=" + opts.Instance
+	}
+
+	svcs, err := client.CoreV1().Services(ns).List(ctx, metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "listing services in namespace %s", ns)
+	}
+	switch len(svcs.Items) {
+	case 0:
+		return nil, fmt.Errorf("no Romeo service found in namespace %s matching %s", ns, selector)
+	case 1:
+		return &svcs.Items[0], nil
+	default:
+		names := make([]string, 0, len(svcs.Items))
+		for _, svc := range svcs.Items {
+			names = append(names, fmt.Sprintf("%s (instance=%s)", svc.Name, svc.Labels["instance"]))
+		}
+		return nil, fmt.Errorf(
+			"many Romeo services found in namespace %s matching %s, please specify an instance among: %s",
+			ns, selector, strings.Join(names, ", "),
+		)
+	}
+}
+
+// findPod looks for a ready pod behind the Service, and the pod port its
+// "api" port targets.
+func findPod(ctx context.Context, client kubernetes.Interface, svc *corev1.Service) (*corev1.Pod, int, error) {
+	if len(svc.Spec.Ports) == 0 {
+		return nil, 0, fmt.Errorf("service %s has no port", svc.Name)
+	}
+	sp := svc.Spec.Ports[0]
+	for _, p := range svc.Spec.Ports {
+		if p.Name == "api" {
+			sp = p
+		}
+	}
+
+	pods, err := client.CoreV1().Pods(svc.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(svc.Spec.Selector).String(),
+	})
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "listing pods of service %s", svc.Name)
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != corev1.PodRunning || !podReady(pod) {
+			continue
+		}
+		port, err := targetPort(pod, sp)
+		if err != nil {
+			return nil, 0, err
+		}
+		return pod, port, nil
+	}
+	return nil, 0, fmt.Errorf("no ready pod found for service %s", svc.Name)
+}
+
+// podBasePath returns the path the webserver of the pod serves under, as
+// configured by its BASE_PATH environment variable.
+func podBasePath(pod *corev1.Pod) string {
+	for _, c := range pod.Spec.Containers {
+		for _, env := range c.Env {
+			if env.Name == "BASE_PATH" {
+				return CleanBasePath(env.Value)
+			}
+		}
+	}
+	return ""
+}
+
+func podReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// targetPort resolves a Service port to the pod container port.
+// An unset target port defaults to the Service port, as Kubernetes does.
+func targetPort(pod *corev1.Pod, sp corev1.ServicePort) (int, error) {
+	tp := sp.TargetPort
+	if tp.Type == intstr.Int {
+		if tp.IntVal == 0 {
+			return int(sp.Port), nil
+		}
+		return tp.IntValue(), nil
+	}
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.Name == tp.StrVal {
+				return int(p.ContainerPort), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("port %s not found in pod %s", tp.StrVal, pod.Name)
+}
@@ -0,0 +1,222 @@
+package webserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func romeoObjects(ns, instance string, ready bool) []runtime.Object {
+	labels := map[string]string{
+		"app.kubernetes.io/part-of": "romeo",
+		"instance":                  instance,
+	}
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return []runtime.Object{
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "romeo-" + instance,
+				Namespace: ns,
+				Labels:    labels,
+			},
+			Spec: corev1.ServiceSpec{
+				Selector: labels,
+				Ports: []corev1.ServicePort{
+					{
+						Name:       "api",
+						Port:       8080,
+						TargetPort: intstr.FromString("api"),
+					},
+				},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "romeo-" + instance + "-pod",
+				Namespace: ns,
+				Labels:    labels,
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name: "romeo",
+						Ports: []corev1.ContainerPort{
+							{
+								Name:          "api",
+								ContainerPort: 8081,
+							},
+						},
+					},
+				},
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				Conditions: []corev1.PodCondition{
+					{
+						Type:   corev1.PodReady,
+						Status: status,
+					},
+				},
+			},
+		},
+	}
+}
+
+// webhookObjects returns the Service of a RomeoWebhook, that has no
+// instance label.
+func webhookObjects(ns string) []runtime.Object {
+	labels := map[string]string{
+		"app.kubernetes.io/name":    "romeo-webhook",
+		"app.kubernetes.io/part-of": "romeo",
+	}
+	return []runtime.Object{
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "romeo-webhook",
+				Namespace: ns,
+				Labels:    labels,
+			},
+			Spec: corev1.ServiceSpec{
+				Selector: labels,
+				Ports: []corev1.ServicePort{
+					{
+						Name:       "webhook",
+						Port:       443,
+						TargetPort: intstr.FromInt(8443),
+					},
+				},
+			},
+		},
+	}
+}
+
+func Test_U_FindRomeo(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Objects    []runtime.Object
+		Instance   string
+		ExpectPod  string
+		ExpectPort int
+		ExpectErr  bool
+	}{
+		"none": {
+			Objects:   nil,
+			ExpectErr: true,
+		},
+		"single": {
+			Objects:    romeoObjects("ns", "abcdefgh", true),
+			ExpectPod:  "romeo-abcdefgh-pod",
+			ExpectPort: 8081,
+		},
+		"not-ready": {
+			Objects:   romeoObjects("ns", "abcdefgh", false),
+			ExpectErr: true,
+		},
+		"many": {
+			Objects:   append(romeoObjects("ns", "abcdefgh", true), romeoObjects("ns", "ijklmnop", true)...),
+			ExpectErr: true,
+		},
+		"many-with-instance": {
+			Objects:    append(romeoObjects("ns", "abcdefgh", true), romeoObjects("ns", "ijklmnop", true)...),
+			Instance:   "ijklmnop",
+			ExpectPod:  "romeo-ijklmnop-pod",
+			ExpectPort: 8081,
+		},
+		"with-webhook": {
+			Objects:    append(romeoObjects("ns", "abcdefgh", true), webhookObjects("ns")...),
+			ExpectPod:  "romeo-abcdefgh-pod",
+			ExpectPort: 8081,
+		},
+		"other-namespace": {
+			Objects:   romeoObjects("other", "abcdefgh", true),
+			ExpectErr: true,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			client := fake.NewClientset(tt.Objects...)
+			pod, port, err := func() (*corev1.Pod, int, error) {
+				svc, err := findService(context.Background(), client, "ns", &PortForwardOptions{
+					Instance: tt.Instance,
+				})
+				if err != nil {
+					return nil, 0, err
+				}
+				return findPod(context.Background(), client, svc)
+			}()
+			if tt.ExpectErr {
+				require.Error(err)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(tt.ExpectPod, pod.Name)
+			assert.Equal(tt.ExpectPort, port)
+		})
+	}
+}
+
+func Test_U_PodBasePath(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Env    []corev1.EnvVar
+		Expect string
+	}{
+		"none": {
+			Env:    nil,
+			Expect: "",
+		},
+		"empty": {
+			Env: []corev1.EnvVar{
+				{Name: "BASE_PATH", Value: ""},
+			},
+			Expect: "",
+		},
+		"gateway": {
+			Env: []corev1.EnvVar{
+				{Name: "COVERDIR", Value: "/etc/coverout"},
+				{Name: "BASE_PATH", Value: "/romeo-abcdefgh"},
+			},
+			Expect: "/romeo-abcdefgh",
+		},
+		"unclean": {
+			Env: []corev1.EnvVar{
+				{Name: "BASE_PATH", Value: "romeo/env-1/"},
+			},
+			Expect: "/romeo/env-1",
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			pod := &corev1.Pod{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name: "romeo",
+							Env:  tt.Env,
+						},
+					},
+				},
+			}
+			assert.Equal(t, tt.Expect, podBasePath(pod))
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Package sink writes the Romeo coverages bundles out of an environment,
+// such that they outlive it, e.g. when it is destroyed before they are
+// downloaded.
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ctfer-io/romeo/webserver/storage"
+	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+)
+
+// Sink writes a coverages bundle (the zip archive served by the coverout
+// API) somewhere.
+type Sink interface {
+	Write(ctx context.Context, bundle []byte) error
+}
+
+// S3Options configure the S3-compatible sinks.
+type S3Options = storage.S3Options
+
+// New returns the sink of the URL, either a file path (optionally
+// prefixed by "file://"), or an S3-compatible object ("s3://bucket/key").
+func New(raw string, opts *S3Options) (Sink, error) {
+	if raw == "" {
+		return nil, errors.New("no sink defined")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing sink")
+	}
+	switch u.Scheme {
+	case "", "file":
+		path := raw
+		if u.Scheme == "file" {
+			path = u.Host + u.Path
+		}
+		return &File{Path: path}, nil
+
+	case "s3":
+		key := strings.TrimPrefix(u.Path, "/")
+		if u.Host == "" || key == "" {
+			return nil, fmt.Errorf("S3 sink %s must be of the form s3://bucket/key", raw)
+		}
+		if opts == nil {
+			opts = &S3Options{}
+		}
+		return NewS3(u.Host, key, opts)
+
+	default:
+		return nil, fmt.Errorf("unsupported sink scheme %s, only file and s3 are", u.Scheme)
+	}
+}
+
+// File writes the bundle to a file, e.g. on a PersistentVolumeClaim
+// different from the environment one.
+type File struct {
+	Path string
+}
+
+var _ Sink = (*File)(nil)
+
+func (f *File) Write(_ context.Context, bundle []byte) error {
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0o750); err != nil {
+		return errors.Wrapf(err, "creating %s parent directory", f.Path)
+	}
+	if err := os.WriteFile(f.Path, bundle, 0o600); err != nil {
+		return errors.Wrapf(err, "writing %s", f.Path)
+	}
+	return nil
+}
+
+// S3 writes the bundle as an object of an S3-compatible bucket.
+type S3 struct {
+	Bucket string
+	Key    string
+
+	client *minio.Client
+}
+
+var _ Sink = (*S3)(nil)
+
+// NewS3 returns the S3-compatible sink of the bucket object.
+func NewS3(bucket, key string, opts *S3Options) (*S3, error) {
+	client, err := storage.NewS3Client(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &S3{
+		Bucket: bucket,
+		Key:    key,
+		client: client,
+	}, nil
+}
+
+func (s *S3) Write(ctx context.Context, bundle []byte) error {
+	if _, err := s.client.PutObject(ctx, s.Bucket, s.Key, bytes.NewReader(bundle), int64(len(bundle)), minio.PutObjectOptions{
+		ContentType: "application/zip",
+	}); err != nil {
+		return errors.Wrapf(err, "putting s3://%s/%s", s.Bucket, s.Key)
+	}
+	return nil
+}
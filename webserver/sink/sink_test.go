@@ -0,0 +1,130 @@
+package sink_test
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ctfer-io/romeo/webserver/sink"
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_U_New(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Sink       string
+		ExpectFile string
+		ExpectErr  bool
+	}{
+		"path": {
+			Sink:       "/preserve/coverout.zip",
+			ExpectFile: "/preserve/coverout.zip",
+		},
+		"file": {
+			Sink:       "file:///preserve/coverout.zip",
+			ExpectFile: "/preserve/coverout.zip",
+		},
+		"s3": {
+			Sink: "s3://bucket/romeo/coverout.zip",
+		},
+		"s3-no-key": {
+			Sink:      "s3://bucket",
+			ExpectErr: true,
+		},
+		"unsupported": {
+			Sink:      "gs://bucket/coverout.zip",
+			ExpectErr: true,
+		},
+		"empty": {
+			Sink:      "",
+			ExpectErr: true,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			assert := assert.New(t)
+
+			s, err := sink.New(tt.Sink, nil)
+			if tt.ExpectErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			if tt.ExpectFile != "" {
+				assert.Equal(&sink.File{Path: tt.ExpectFile}, s)
+			}
+		})
+	}
+}
+
+func Test_U_Write(t *testing.T) {
+	t.Parallel()
+
+	// Fake S3-compatible server
+	backend := s3mem.New()
+	require.NoError(t, backend.CreateBucket("bucket"))
+	srv := httptest.NewServer(gofakes3.New(backend).Server())
+	t.Cleanup(srv.Close)
+	endpoint := strings.TrimPrefix(srv.URL, "http://")
+
+	var tests = map[string]struct {
+		Sink string
+		Read func(t *testing.T, sinkURL string) []byte
+	}{
+		"file": {
+			Sink: filepath.Join(t.TempDir(), "sub", "coverout.zip"),
+			Read: func(t *testing.T, sinkURL string) []byte {
+				b, err := os.ReadFile(sinkURL)
+				require.NoError(t, err)
+				return b
+			},
+		},
+		"s3": {
+			Sink: "s3://bucket/romeo/coverout.zip",
+			Read: func(t *testing.T, _ string) []byte {
+				client, err := minio.New(endpoint, &minio.Options{
+					Creds: credentials.NewStaticV4("key", "secret", ""),
+				})
+				require.NoError(t, err)
+				obj, err := client.GetObject(context.Background(), "bucket", "romeo/coverout.zip", minio.GetObjectOptions{})
+				require.NoError(t, err)
+				b, err := io.ReadAll(obj)
+				require.NoError(t, err)
+				return b
+			},
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			require := require.New(t)
+
+			s, err := sink.New(tt.Sink, &sink.S3Options{
+				Endpoint:        endpoint,
+				AccessKeyID:     "key",
+				SecretAccessKey: "secret",
+				Insecure:        true,
+			})
+			require.NoError(err)
+
+			bundle := []byte("PK\x03\x04 coverages")
+			require.NoError(s.Write(context.Background(), bundle))
+			assert.Equal(t, bundle, tt.Read(t, tt.Sink))
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// Filesystem stores the coverage data in a local directory, e.g. the
+// environment PersistentVolumeClaim.
+type Filesystem struct {
+	Root string
+}
+
+var (
+	_ Storage = (*Filesystem)(nil)
+	_ Local   = (*Filesystem)(nil)
+)
+
+// NewFilesystem returns the storage of the directory.
+func NewFilesystem(root string) *Filesystem {
+	return &Filesystem{
+		Root: root,
+	}
+}
+
+func (fsys *Filesystem) Dir() string {
+	return fsys.Root
+}
+
+func (fsys *Filesystem) List(_ context.Context) ([]Object, error) {
+	objs := []Object{}
+	if err := filepath.WalkDir(fsys.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		name, _ := filepath.Rel(fsys.Root, path)
+		objs = append(objs, Object{
+			Name: filepath.ToSlash(name),
+			Size: info.Size(),
+		})
+		return nil
+	}); err != nil {
+		return nil, errors.Wrapf(err, "walking %s", fsys.Root)
+	}
+	return objs, nil
+}
+
+func (fsys *Filesystem) Open(_ context.Context, name string) (io.ReadCloser, error) {
+	path, err := fsys.path(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path) //nolint:gosec //#gosec G304 -- FP, the path is checked to be under the root
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening %s", name)
+	}
+	return f, nil
+}
+
+func (fsys *Filesystem) Put(_ context.Context, name string, r io.Reader, _ int64) error {
+	path, err := fsys.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return errors.Wrapf(err, "creating %s parent directory", name)
+	}
+
+	f, err := os.Create(path) //nolint:gosec //#gosec G304 -- FP, the path is checked to be under the root
+	if err != nil {
+		return errors.Wrapf(err, "creating %s", name)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return errors.Wrapf(err, "writing %s", name)
+	}
+	return nil
+}
+
+func (fsys *Filesystem) Ready(_ context.Context) error {
+	if _, err := os.ReadDir(fsys.Root); err != nil {
+		return errors.Wrapf(err, "reading %s", fsys.Root)
+	}
+	return nil
+}
+
+// path returns the path of the object, if it is under the root.
+func (fsys *Filesystem) path(name string) (string, error) {
+	rel := filepath.FromSlash(name)
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("object %s escapes the storage root", name)
+	}
+	return filepath.Join(fsys.Root, rel), nil
+}
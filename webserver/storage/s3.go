@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/pkg/errors"
+)
+
+// S3Options configure the S3-compatible storages.
+type S3Options struct {
+	// Endpoint of the S3-compatible API, e.g. "minio.minio:9000".
+	// Defaults to "s3.amazonaws.com".
+	Endpoint string
+
+	// AccessKeyID and SecretAccessKey to authenticate with.
+	// If empty, the AWS environment variables (e.g. AWS_ACCESS_KEY_ID)
+	// are used.
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// Region of the bucket, if required by the endpoint.
+	Region string
+
+	// Insecure reaches the endpoint over plain HTTP.
+	Insecure bool
+}
+
+// NewS3Client returns the client of the S3-compatible API the options
+// define.
+func NewS3Client(opts *S3Options) (*minio.Client, error) {
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = "s3.amazonaws.com"
+	}
+	creds := credentials.NewEnvAWS()
+	if opts.AccessKeyID != "" {
+		creds = credentials.NewStaticV4(opts.AccessKeyID, opts.SecretAccessKey, "")
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: !opts.Insecure,
+		Region: opts.Region,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "building S3 client")
+	}
+	return client, nil
+}
+
+// S3 stores the coverage data as the objects of an S3-compatible bucket,
+// under a prefix.
+type S3 struct {
+	Bucket string
+	Prefix string
+
+	client *minio.Client
+}
+
+var _ Storage = (*S3)(nil)
+
+// NewS3 returns the S3-compatible storage of the bucket prefix.
+func NewS3(bucket, prefix string, opts *S3Options) (*S3, error) {
+	client, err := NewS3Client(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &S3{
+		Bucket: bucket,
+		Prefix: strings.Trim(prefix, "/"),
+		client: client,
+	}, nil
+}
+
+func (s *S3) List(ctx context.Context) ([]Object, error) {
+	prefix := s.Prefix
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	objs := []Object{}
+	if err := s.list(ctx, prefix, prefix, &objs); err != nil {
+		return nil, err
+	}
+	return objs, nil
+}
+
+// list walks the common prefixes under dir rather than listing recursively,
+// as not all S3-compatible APIs support listing without a delimiter.
+func (s *S3) list(ctx context.Context, prefix, dir string, objs *[]Object) error {
+	for info := range s.client.ListObjects(ctx, s.Bucket, minio.ListObjectsOptions{
+		Prefix: dir,
+	}) {
+		if info.Err != nil {
+			return errors.Wrapf(info.Err, "listing s3://%s/%s", s.Bucket, dir)
+		}
+		if strings.HasSuffix(info.Key, "/") {
+			if err := s.list(ctx, prefix, info.Key, objs); err != nil {
+				return err
+			}
+			continue
+		}
+		*objs = append(*objs, Object{
+			Name: strings.TrimPrefix(info.Key, prefix),
+			Size: info.Size,
+		})
+	}
+	return nil
+}
+
+func (s *S3) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	obj, err := s.client.GetObject(ctx, s.Bucket, s.key(name), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting s3://%s/%s", s.Bucket, s.key(name))
+	}
+	return obj, nil
+}
+
+func (s *S3) Put(ctx context.Context, name string, r io.Reader, size int64) error {
+	if _, err := s.client.PutObject(ctx, s.Bucket, s.key(name), r, size, minio.PutObjectOptions{}); err != nil {
+		return errors.Wrapf(err, "putting s3://%s/%s", s.Bucket, s.key(name))
+	}
+	return nil
+}
+
+func (s *S3) Ready(ctx context.Context) error {
+	ok, err := s.client.BucketExists(ctx, s.Bucket)
+	if err != nil {
+		return errors.Wrapf(err, "checking bucket %s", s.Bucket)
+	}
+	if !ok {
+		return errors.Errorf("bucket %s does not exist", s.Bucket)
+	}
+	return nil
+}
+
+func (s *S3) key(name string) string {
+	return path.Join(s.Prefix, name)
+}
@@ -0,0 +1,125 @@
+// Package storage abstracts where the coverage data live, such that the
+// Romeo webserver is not tied to the lifetime of a single
+// PersistentVolumeClaim: either a local directory, or an S3-compatible
+// bucket (e.g. MinIO) for long-running coverages.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Object is a coverage data file of a [Storage].
+type Object struct {
+	// Name of the object, relative to the storage root, with "/"
+	// separators (e.g. "pod-a/covmeta.0123abcd").
+	Name string
+
+	// Size of the object in bytes.
+	Size int64
+}
+
+// Storage of the coverage data files.
+type Storage interface {
+	// List the objects of the storage.
+	List(ctx context.Context) ([]Object, error)
+
+	// Open the object of the given name for reading.
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+
+	// Put writes the object of the given name, e.g. for instrumented
+	// applications to push their coverages.
+	Put(ctx context.Context, name string, r io.Reader, size int64) error
+
+	// Ready checks the storage could be read.
+	Ready(ctx context.Context) error
+}
+
+// Local is implemented by the storages backed by a local directory, that
+// the merge engine could read as is.
+type Local interface {
+	Dir() string
+}
+
+// New returns the storage of the URL, either a directory (optionally
+// prefixed by "file://"), or an S3-compatible prefix ("s3://bucket/prefix").
+func New(raw string, opts *S3Options) (Storage, error) {
+	if raw == "" {
+		return nil, errors.New("no storage defined")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing storage")
+	}
+	switch u.Scheme {
+	case "", "file":
+		dir := raw
+		if u.Scheme == "file" {
+			dir = u.Host + u.Path
+		}
+		return NewFilesystem(dir), nil
+
+	case "s3":
+		if u.Host == "" {
+			return nil, fmt.Errorf("S3 storage %s must be of the form s3://bucket/prefix", raw)
+		}
+		if opts == nil {
+			opts = &S3Options{}
+		}
+		return NewS3(u.Host, strings.Trim(u.Path, "/"), opts)
+
+	default:
+		return nil, fmt.Errorf("unsupported storage scheme %s, only file and s3 are", u.Scheme)
+	}
+}
+
+// Fetch copies the objects of the storage into the directory, keeping
+// their relative paths.
+func Fetch(ctx context.Context, st Storage, dir string) error {
+	objs, err := st.List(ctx)
+	if err != nil {
+		return errors.Wrap(err, "listing objects")
+	}
+	for _, obj := range objs {
+		if err := fetch(ctx, st, obj.Name, dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fetch(ctx context.Context, st Storage, name, dir string) error {
+	rel := filepath.FromSlash(name)
+	if !filepath.IsLocal(rel) {
+		return fmt.Errorf("object %s escapes the directory", name)
+	}
+	path := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return errors.Wrapf(err, "creating %s parent directory", path)
+	}
+
+	r, err := st.Open(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	f, err := os.Create(path) //nolint:gosec //#gosec G304 -- FP, the path is checked to be under the directory
+	if err != nil {
+		return errors.Wrapf(err, "creating %s", path)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return errors.Wrapf(err, "fetching %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ctfer-io/romeo/webserver/storage"
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_U_New(t *testing.T) {
+	t.Parallel()
+
+	var tests = map[string]struct {
+		Storage     string
+		ExpectLocal string
+		ExpectErr   bool
+	}{
+		"path": {
+			Storage:     "/etc/coverout",
+			ExpectLocal: "/etc/coverout",
+		},
+		"file": {
+			Storage:     "file:///etc/coverout",
+			ExpectLocal: "/etc/coverout",
+		},
+		"s3": {
+			Storage: "s3://bucket/romeo",
+		},
+		"s3-no-prefix": {
+			Storage: "s3://bucket",
+		},
+		"s3-no-bucket": {
+			Storage:   "s3:///romeo",
+			ExpectErr: true,
+		},
+		"unsupported": {
+			Storage:   "gs://bucket/romeo",
+			ExpectErr: true,
+		},
+		"empty": {
+			Storage:   "",
+			ExpectErr: true,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			assert := assert.New(t)
+
+			st, err := storage.New(tt.Storage, nil)
+			if tt.ExpectErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+
+			local, ok := st.(storage.Local)
+			assert.Equal(tt.ExpectLocal != "", ok)
+			if ok {
+				assert.Equal(tt.ExpectLocal, local.Dir())
+			}
+		})
+	}
+}
+
+func Test_U_Storage(t *testing.T) {
+	t.Parallel()
+
+	// Fake S3-compatible server
+	backend := s3mem.New()
+	require.NoError(t, backend.CreateBucket("bucket"))
+	srv := httptest.NewServer(gofakes3.New(backend).Server())
+	t.Cleanup(srv.Close)
+	opts := &storage.S3Options{
+		Endpoint:        strings.TrimPrefix(srv.URL, "http://"),
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+		Insecure:        true,
+	}
+
+	var tests = map[string]struct {
+		Storage        string
+		ExpectReadyErr bool
+	}{
+		"filesystem": {
+			Storage: t.TempDir(),
+		},
+		"s3": {
+			Storage: "s3://bucket/romeo",
+		},
+		"s3-missing-bucket": {
+			Storage:        "s3://missing/romeo",
+			ExpectReadyErr: true,
+		},
+	}
+
+	for testname, tt := range tests {
+		t.Run(testname, func(t *testing.T) {
+			t.Parallel()
+
+			assert := assert.New(t)
+			require := require.New(t)
+			ctx := context.Background()
+
+			st, err := storage.New(tt.Storage, opts)
+			require.NoError(err)
+
+			err = st.Ready(ctx)
+			if tt.ExpectReadyErr {
+				assert.Error(err)
+				return
+			}
+			require.NoError(err)
+
+			// Push the coverages of two pods
+			files := map[string][]byte{
+				"pod-a/covmeta.0123abcd":             {0x00, 0x63, 0x76, 0x6d},
+				"pod-b/covcounters.0123abcd.42.1234": {0x00, 0x63, 0x77, 0x6d, 0x01},
+			}
+			for name, content := range files {
+				require.NoError(st.Put(ctx, name, bytes.NewReader(content), int64(len(content))))
+			}
+
+			objs, err := st.List(ctx)
+			require.NoError(err)
+			assert.ElementsMatch([]storage.Object{
+				{Name: "pod-a/covmeta.0123abcd", Size: 4},
+				{Name: "pod-b/covcounters.0123abcd.42.1234", Size: 5},
+			}, objs)
+
+			r, err := st.Open(ctx, "pod-a/covmeta.0123abcd")
+			require.NoError(err)
+			b, err := io.ReadAll(r)
+			require.NoError(err)
+			require.NoError(r.Close())
+			assert.Equal(files["pod-a/covmeta.0123abcd"], b)
+
+			// Fetch them locally, keeping their relative paths
+			dir := t.TempDir()
+			require.NoError(storage.Fetch(ctx, st, dir))
+			for name, content := range files {
+				b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+				require.NoError(err)
+				assert.Equal(content, b)
+			}
+		})
+	}
+}
+
+func Test_U_FilesystemEscape(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	st := storage.NewFilesystem(t.TempDir())
+	assert.Error(st.Put(context.Background(), "../escape", strings.NewReader("x"), 1))
+	_, err := st.Open(context.Background(), "../../etc/passwd")
+	assert.Error(err)
+}